@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	ratelimitv1 "github.com/mrangelba/go-exp-rate-limiter/api/ratelimit/v1"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/logger"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/server"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/adaptive"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/events"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/grpc"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/handlers"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/usage"
+)
+
+// limiter exposes the rate limit use case as a decision service, over
+// HTTP (POST /v1/check and GET /v1/usage) and gRPC (ratelimit.v1.RateLimitService), and as
+// the external rate limit service of envoy (envoy.service.ratelimit.v3)
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config := config.GetConfig()
+
+	log := logger.NewLogger(config.Logger, os.Stdout)
+
+	log.Info("starting rate limit service")
+	log.Debug("config loaded", "config", config)
+
+	if err := config.RateLimiter.Validate(); err != nil {
+		log.Error("invalid rate limit config", "error", err)
+		os.Exit(1)
+	}
+
+	cache, err := strategies.GetCacheStrategy(config, log)
+	if err != nil {
+		log.Error("invalid cache config", "error", err)
+		os.Exit(1)
+	}
+
+	if janitor, ok := cache.(strategies.Janitor); ok && config.CacheCleanup > 0 {
+		janitor.StartJanitor(time.Duration(config.CacheCleanup) * time.Second)
+	}
+
+	exporter, err := usage.GetUsageExporter(config, log)
+	if err != nil {
+		log.Error("invalid usage config", "error", err)
+		os.Exit(1)
+	}
+
+	bus, err := events.GetEventBus(config, log)
+	if err != nil {
+		log.Error("invalid events config", "error", err)
+		os.Exit(1)
+	}
+
+	if config.Adaptive.Enabled && (config.Adaptive.LatencyMs > 0 || config.Adaptive.ErrorRate > 0) {
+		// the limiter does not see the responses of the applications
+		log.Error("invalid adaptive config", "error", "the limiter only supports the cpu load signal")
+		os.Exit(1)
+	}
+
+	controller, err := adaptive.GetController(config, log)
+	if err != nil {
+		log.Error("invalid adaptive config", "error", err)
+		os.Exit(1)
+	}
+
+	ucOpts := []usecases.Option{
+		usecases.WithLogger(log),
+		usecases.WithNearlyExhaustedPercent(config.Events.NearlyExhaustedPercent),
+	}
+
+	if exporter != nil {
+		ucOpts = append(ucOpts, usecases.WithUsageRecorder(exporter))
+	}
+
+	if bus != nil {
+		ucOpts = append(ucOpts, usecases.WithEventPublisher(bus))
+	}
+
+	if controller != nil {
+		ucOpts = append(ucOpts, usecases.WithLimitScaler(controller))
+	}
+
+	uc := usecases.NewRateLimitUseCase(config, cache, ucOpts...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/check", handlers.NewCheckHandler(uc))
+	mux.Handle("/v1/usage", handlers.NewUsageHandler(uc, config.RateLimiter.Token))
+
+	httpServer, err := server.NewServer(config.Server, mux, log)
+	if err != nil {
+		log.Error("invalid server config", "error", err)
+		os.Exit(1)
+	}
+
+	grpcServer, err := server.NewGRPCServer(config.Server, log)
+	if err != nil {
+		log.Error("invalid server config", "error", err)
+		os.Exit(1)
+	}
+
+	ratelimitv1.RegisterRateLimitServiceServer(grpcServer.Server(), grpc.NewRateLimitService(uc))
+	rlsv3.RegisterRateLimitServiceServer(grpcServer.Server(), grpc.NewEnvoyRateLimitService(uc, log))
+
+	ctx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 2)
+
+	go func() {
+		errs <- httpServer.Run(ctx)
+		cancel()
+	}()
+
+	go func() {
+		errs <- grpcServer.Run(ctx)
+		cancel()
+	}()
+
+	err = errors.Join(<-errs, <-errs)
+
+	if exporter != nil {
+		err = errors.Join(err, exporter.Close())
+	}
+
+	if bus != nil {
+		err = errors.Join(err, bus.Close())
+	}
+
+	if controller != nil {
+		err = errors.Join(err, controller.Close())
+	}
+
+	if closer, ok := cache.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+
+	if err != nil {
+		log.Error("rate limit service stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
@@ -1,30 +1,115 @@
 package main
 
 import (
-	"log"
+	"context"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/logger"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/server"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/adaptive"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/events"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/middlewares"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/usage"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/utils"
 )
 
 func main() {
-	log.Println("Starting server...")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	config := config.GetConfig()
 
-	uc := usecases.NewRateLimitUseCase(
-		config,
-		strategies.GetCacheStrategy(config.Cache),
-	)
+	log := logger.NewLogger(config.Logger, os.Stdout)
+
+	log.Info("starting server")
+	log.Debug("config loaded", "config", config)
+
+	if err := config.RateLimiter.Validate(); err != nil {
+		log.Error("invalid rate limit config", "error", err)
+		os.Exit(1)
+	}
+
+	cache, err := strategies.GetCacheStrategy(config, log)
+	if err != nil {
+		log.Error("invalid cache config", "error", err)
+		os.Exit(1)
+	}
+
+	if janitor, ok := cache.(strategies.Janitor); ok && config.CacheCleanup > 0 {
+		janitor.StartJanitor(time.Duration(config.CacheCleanup) * time.Second)
+	}
+
+	exporter, err := usage.GetUsageExporter(config, log)
+	if err != nil {
+		log.Error("invalid usage config", "error", err)
+		os.Exit(1)
+	}
+
+	bus, err := events.GetEventBus(config, log)
+	if err != nil {
+		log.Error("invalid events config", "error", err)
+		os.Exit(1)
+	}
+
+	controller, err := adaptive.GetController(config, log)
+	if err != nil {
+		log.Error("invalid adaptive config", "error", err)
+		os.Exit(1)
+	}
+
+	ucOpts := []usecases.Option{
+		usecases.WithLogger(log),
+		usecases.WithNearlyExhaustedPercent(config.Events.NearlyExhaustedPercent),
+	}
+
+	if exporter != nil {
+		ucOpts = append(ucOpts, usecases.WithUsageRecorder(exporter))
+	}
+
+	if bus != nil {
+		ucOpts = append(ucOpts, usecases.WithEventPublisher(bus))
+	}
+
+	if controller != nil {
+		ucOpts = append(ucOpts, usecases.WithLimitScaler(controller))
+	}
+
+	uc := usecases.NewRateLimitUseCase(config, cache, ucOpts...)
 
-	rateLimit := middlewares.NewRateLimiter(uc)
+	var mwOpts []middlewares.Option
+
+	if controller != nil {
+		mwOpts = append(mwOpts, middlewares.WithObserver(controller))
+	}
+
+	if len(config.Delay.Keys) > 0 {
+		mwOpts = append(mwOpts, middlewares.WithDelay(
+			time.Duration(config.Delay.MaxWait)*time.Millisecond, config.Delay.QueueSize, config.Delay.Keys...))
+	}
+
+	rateLimit := middlewares.NewRateLimiter(uc, mwOpts...)
 
 	router := gin.Default()
+
+	if config.Shedding.Enabled {
+		shedder, err := middlewares.NewLoadShedder(config.Shedding, config.RateLimiter.Token, log)
+		if err != nil {
+			log.Error("invalid shedding config", "error", err)
+			os.Exit(1)
+		}
+
+		router.Use(utils.MiddlewareToGin(shedder.Handler))
+	}
+
 	router.Use(
 		utils.MiddlewareToGin(rateLimit.Handler),
 	)
@@ -33,5 +118,32 @@ func main() {
 		c.String(http.StatusOK, "Hello, Go Expert!")
 	})
 
-	router.Run(":8080")
+	srv, err := server.NewServer(config.Server, router, log)
+	if err != nil {
+		log.Error("invalid server config", "error", err)
+		os.Exit(1)
+	}
+
+	var closers []io.Closer
+
+	if exporter != nil {
+		closers = append(closers, exporter)
+	}
+
+	if bus != nil {
+		closers = append(closers, bus)
+	}
+
+	if controller != nil {
+		closers = append(closers, controller)
+	}
+
+	if closer, ok := cache.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+
+	if err := srv.Run(ctx, closers...); err != nil {
+		log.Error("server stopped with error", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// Clock returns the current time of the rate limit windows
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the clock of the operating system
+var SystemClock Clock = systemClock{}
@@ -2,12 +2,41 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 )
 
+// ErrWindowNotFound is returned by the RateLimitCache when the window of the
+// key does not exist or is expired, any other error is a failure of the cache
+var ErrWindowNotFound = errors.New("rate limit window not found")
+
 type RateLimitCache interface {
 	Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error
 	Get(ctx context.Context, key string) (*entities.RateLimiter, error)
 }
+
+// UsageRecorder receives the requests counted in each rate limit window, to
+// aggregate and export them
+type UsageRecorder interface {
+	Record(ctx context.Context, record entities.UsageRecord)
+}
+
+// EventPublisher receives the rate limit events, it must not block the check
+// that emitted them
+type EventPublisher interface {
+	Publish(ctx context.Context, event entities.Event)
+}
+
+// LimitScaler returns the factor applied to the limits of the rules, to
+// adapt them to the load of the system
+type LimitScaler interface {
+	Factor() float64
+}
+
+// OrgResolver returns the organization of the key, an empty name when the
+// key belongs to no organization
+type OrgResolver interface {
+	Org(ctx context.Context, key string) (string, error)
+}
@@ -0,0 +1,74 @@
+package entities
+
+// CheckRequest asks if Cost requests of Key are allowed under Policy, the
+// rule configured for the key is used when Policy is empty. The requests are
+// also checked against the global rules when Route, the path requested, is
+// informed. The windows of the key are counted apart in each Domain. Retry
+// marks the checks repeated by a request held in delay mode, whose
+// rejections are not recorded
+type CheckRequest struct {
+	Key    string `json:"key"`
+	Cost   int    `json:"cost,omitempty"`
+	Policy string `json:"policy,omitempty"`
+	Route  string `json:"route,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Retry  bool   `json:"-"`
+}
+
+// Decision is the result of a rate limit check. Limit, Every and Period are
+// of the rule of the window with the fewest requests remaining
+type Decision struct {
+	Allowed   bool              `json:"allowed"`
+	Remaining int               `json:"remaining"`
+	Reset     int64             `json:"reset"`
+	Limit     int               `json:"limit,omitempty"`
+	Every     int               `json:"every,omitempty"`
+	Period    string            `json:"period,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// Usage is the consumption of Key under Policy in the current window, Period
+// is set for the calendar quotas. Reading the usage does not consume requests
+type Usage struct {
+	Key       string `json:"key"`
+	Policy    string `json:"policy,omitempty"`
+	Rule      string `json:"rule"`
+	Period    string `json:"period,omitempty"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset"`
+}
+
+// UsageRecord is the number of requests of Key counted by Rule in the window
+// ending at Reset, allowed and rejected. Records of the same key, rule and
+// window are added up by the usage export
+type UsageRecord struct {
+	Key      string `json:"key"`
+	Rule     string `json:"rule"`
+	Period   string `json:"period,omitempty"`
+	Reset    int64  `json:"reset"`
+	Requests int    `json:"requests"`
+	Rejected int    `json:"rejected"`
+}
+
+// Types of the rate limit events
+const (
+	EventLimitExceeded        = "limit_exceeded"
+	EventQuotaNearlyExhausted = "quota_nearly_exhausted"
+	EventKeyBanned            = "key_banned"
+)
+
+// Event reports a change of the window of Key under Rule, ending at Reset.
+// Time is when it happened, in unix seconds, and DryRun is set for the rules
+// in dry run, whose exceeded requests are still allowed
+type Event struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	Rule      string `json:"rule"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset"`
+	Time      int64  `json:"time"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
@@ -8,6 +8,11 @@ type RateLimiter struct {
 	Every     int    `json:"every"`
 	Remaining int    `json:"remaining"`
 	Reset     int64  `json:"reset"`
+	// Limited reports that the window was exceeded under a dry-run rule
+	Limited bool `json:"limited,omitempty"`
+	// Counted is the number of requests stored when the window was read, stores
+	// that count atomically add only the requests counted since then
+	Counted int `json:"-"`
 }
 
 func (r RateLimiter) MarshalBinary() ([]byte, error) {
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchRoute reports if the cleaned path is the route or is under it, so
+// /admin/ matches /admin and /admin/users but not /administrator, and
+// /checkout/../search does not match /checkout
+func MatchRoute(requestPath, route string) bool {
+	requestPath = path.Clean("/" + requestPath)
+	route = strings.TrimSuffix(path.Clean("/"+route), "/")
+
+	return requestPath == route || strings.HasPrefix(requestPath, route+"/")
+}
@@ -2,116 +2,958 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
+	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	meterName  = tracerName
+
+	// reservedPrefix starts the cache keys of the windows shared by the
+	// clients, such as the global ones, the keys of the clients starting with
+	// it are escaped by clientKey
+	reservedPrefix = "~"
+)
+
+var (
+	ErrInvalidKey     = errors.New("rate limit key is required")
+	ErrInvalidCost    = errors.New("rate limit cost must not be negative")
+	ErrPolicyNotFound = errors.New("rate limit policy not found")
+	ErrMissingCache   = errors.New("rate limit cache is required")
 )
 
 type RateLimitUseCase interface {
 	GetHttpHeaders(ctx context.Context, key string) map[string]string
 	VerifyLimit(ctx context.Context, key string) bool
+	Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error)
+	Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error)
 }
 
 type rateLimitUseCase struct {
-	config config.Config
+	mutex  sync.Mutex
+	limits rate_limiter.RateLimiterConfig
 	cache  domain.RateLimitCache
+	clock  domain.Clock
+	tracer trace.Tracer
+	logger *slog.Logger
+	usage  domain.UsageRecorder
+	events domain.EventPublisher
+	scaler domain.LimitScaler
+	orgs   domain.OrgResolver
+
+	meterProvider  metric.MeterProvider
+	globalRequests metric.Int64Counter
+
+	// nearlyExhausted is the percentage of the limit used that emits the
+	// QuotaNearlyExhausted event
+	nearlyExhausted int
+
+	// locations caches the time zones of the quota rules by name
+	locations sync.Map
 }
 
-func NewRateLimitUseCase(config config.Config, cache domain.RateLimitCache) RateLimitUseCase {
-	return &rateLimitUseCase{
-		config: config,
-		cache:  cache,
+// Option configures optional dependencies of the rate limit use case
+type Option func(*rateLimitUseCase)
+
+// WithLimits sets the default, IP, token and policy rules
+func WithLimits(limits rate_limiter.RateLimiterConfig) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.limits = limits
 	}
 }
 
-func (uc *rateLimitUseCase) VerifyLimit(ctx context.Context, key string) bool {
-	rate, err := uc.cache.Get(ctx, key)
+// WithCache sets the cache that stores the rate limit windows
+func WithCache(cache domain.RateLimitCache) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.cache = cache
+	}
+}
+
+// WithClock sets the clock of the rate limit windows, the system clock is
+// used when it is not informed
+func WithClock(clock domain.Clock) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.clock = clock
+	}
+}
+
+// WithTracerProvider sets the provider used to trace limit checks,
+// the global provider is used when it is not informed
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithLogger sets the logger used to report rejections and cache errors,
+// the default slog logger is used when it is not informed
+func WithLogger(logger *slog.Logger) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.logger = logger
+	}
+}
+
+// WithUsageRecorder sets the recorder of the requests counted in each window,
+// the usage is not recorded when it is not informed
+func WithUsageRecorder(recorder domain.UsageRecorder) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.usage = recorder
+	}
+}
+
+// WithEventPublisher sets the publisher of the LimitExceeded and
+// QuotaNearlyExhausted events, no event is emitted when it is not informed
+func WithEventPublisher(publisher domain.EventPublisher) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.events = publisher
+	}
+}
+
+// WithNearlyExhaustedPercent sets the percentage of the limit used in a
+// window that emits the QuotaNearlyExhausted event, 80 when it is not
+// informed. Zero disables the event
+func WithNearlyExhaustedPercent(percent int) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.nearlyExhausted = percent
+	}
+}
+
+// WithLimitScaler scales the limits of the rules by the factor of scaler,
+// read on every decision, so a change of the factor applies to the windows
+// already started. The quotas of the rules with a period are not scaled
+func WithLimitScaler(scaler domain.LimitScaler) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.scaler = scaler
+	}
+}
+
+// WithOrgResolver sets the resolver of the organizations of the keys, the
+// tokens of the org rules are used when it is not informed
+func WithOrgResolver(resolver domain.OrgResolver) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.orgs = resolver
+	}
+}
+
+// WithMeterProvider sets the provider of the global rules metrics, the
+// global provider is used when it is not informed
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(uc *rateLimitUseCase) {
+		uc.meterProvider = provider
+	}
+}
+
+// New returns the use case configured by the options, the cache is required
+func New(opts ...Option) (RateLimitUseCase, error) {
+	uc := newRateLimitUseCase(opts)
+
+	if uc.cache == nil {
+		return nil, ErrMissingCache
+	}
 
+	return uc, nil
+}
+
+// NewRateLimitUseCase returns the use case with the rules of the config
+func NewRateLimitUseCase(config config.Config, cache domain.RateLimitCache, opts ...Option) RateLimitUseCase {
+	return newRateLimitUseCase(append([]Option{WithLimits(config.RateLimiter), WithCache(cache)}, opts...))
+}
+
+func newRateLimitUseCase(opts []Option) *rateLimitUseCase {
+	uc := &rateLimitUseCase{
+		clock:           domain.SystemClock,
+		tracer:          otel.GetTracerProvider().Tracer(tracerName),
+		logger:          slog.Default(),
+		nearlyExhausted: 80,
+		meterProvider:   otel.GetMeterProvider(),
+	}
+
+	for _, opt := range opts {
+		opt(uc)
+	}
+
+	counter, err := uc.meterProvider.Meter(meterName).Int64Counter("rate_limit.global.requests",
+		metric.WithDescription("Requests checked against the global and route rules"))
 	if err != nil {
-		every := uc.config.RateLimiter.Default.Every
-		requests := uc.config.RateLimiter.Default.Requests
-
-		if slices.ContainsFunc(uc.config.RateLimiter.Token, func(s rate_limiter.Token) bool {
-			return s.Token == key
-		}) {
-			index := slices.IndexFunc(uc.config.RateLimiter.Token, func(s rate_limiter.Token) bool {
-				return s.Token == key
-			})
-
-			every = uc.config.RateLimiter.Token[index].Every
-			requests = uc.config.RateLimiter.Token[index].Requests
-		} else if slices.ContainsFunc(uc.config.RateLimiter.IP, func(s rate_limiter.IP) bool {
-			return s.IP == key
-		}) {
-			index := slices.IndexFunc(uc.config.RateLimiter.IP, func(s rate_limiter.IP) bool {
-				return s.IP == key
-			})
-
-			every = uc.config.RateLimiter.IP[index].Every
-			requests = uc.config.RateLimiter.IP[index].Requests
+		uc.logger.Warn("failed to create the global rules metrics", "error", err)
+		counter = noop.Int64Counter{}
+	}
+
+	uc.globalRequests = counter
+
+	if uc.orgs == nil {
+		uc.orgs = newOrgMembers(uc.limits.Org)
+	}
+
+	return uc
+}
+
+// orgMembers resolves the organizations of the tokens listed in the org rules
+type orgMembers map[string]string
+
+func newOrgMembers(orgs []rate_limiter.Org) orgMembers {
+	members := orgMembers{}
+
+	for _, org := range orgs {
+		for _, token := range org.Tokens {
+			members[token] = org.Name
+		}
+	}
+
+	return members
+}
+
+func (m orgMembers) Org(ctx context.Context, key string) (string, error) {
+	return m[key], nil
+}
+
+// rule is a configured rule with the limit of the decision in requests, base
+// is the configured limit that the windows count down from
+type rule struct {
+	name     string
+	requests int
+	base     int
+	every    int
+	dryRun   bool
+	period   string
+	location *time.Location
+}
+
+// level is a window checked in a decision, the window of the key of the
+// request, of its org or endpoint, or of a global rule, counted in the global
+// metrics. The headers of the window start with header
+type level struct {
+	key    string
+	rule   rule
+	header string
+	global bool
+}
+
+// newWindow returns the empty window of the key under the rule. The window
+// of a quota rule ends at the next boundary of its period, the window of the
+// other rules ends every seconds after now
+func (uc *rateLimitUseCase) newWindow(key string, rule rule) *entities.RateLimiter {
+	now := uc.clock.Now()
+	reset := now.Add(time.Duration(rule.every) * time.Second)
+	every := rule.every
+
+	if rule.period != "" {
+		reset = periodReset(now, rule.period, rule.location)
+		every = int(reset.Unix() - now.Unix())
+	}
+
+	return &entities.RateLimiter{
+		Key:       key,
+		Every:     every,
+		Remaining: rule.base,
+		Requests:  0,
+		Reset:     reset.Unix(),
+	}
+}
+
+// periodReset returns the start of the day, week or month after now in the
+// location, weeks start on monday
+func periodReset(now time.Time, period string, location *time.Location) time.Time {
+	now = now.In(location)
+	year, month, day := now.Date()
+
+	switch period {
+	case rate_limiter.PeriodMonth:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+	case rate_limiter.PeriodWeek:
+		days := (8 - int(now.Weekday())) % 7
+		if days == 0 {
+			days = 7
 		}
 
-		uc.cache.Set(ctx,
-			entities.RateLimiter{
-				Key:       key,
-				Every:     every,
-				Remaining: requests,
-				Requests:  0,
-				Reset:     time.Now().Add(time.Duration(every) * time.Second).Unix(),
-			}, time.Duration(every)*time.Second)
+		return time.Date(year, month, day+days, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	}
+}
+
+// newRule returns the rule with the location of its time zone and, unless
+// it has a period, its limit scaled by the current factor of the limit
+// scaler, at least one request. An unknown time zone is logged and replaced
+// by UTC
+func (uc *rateLimitUseCase) newRule(name string, requests, every int, dryRun bool, period, timeZone string) rule {
+	r := rule{
+		name:     name,
+		requests: requests,
+		base:     requests,
+		every:    every,
+		dryRun:   dryRun,
+		period:   period,
+	}
+
+	if period == "" {
+		if uc.scaler != nil && requests > 0 {
+			r.requests = max(int(float64(requests)*uc.scaler.Factor()), 1)
+		}
 
-		return uc.VerifyLimit(ctx, key)
+		return r
 	}
 
-	limit, err := uc.validateCacheLimit(ctx, rate)
+	if location, ok := uc.locations.Load(timeZone); ok {
+		r.location = location.(*time.Location)
+		return r
+	}
 
+	location, err := time.LoadLocation(timeZone)
 	if err != nil {
-		log.Println(err)
-		return false
+		uc.logger.Warn("invalid rate limit time zone, using UTC", "rule", name, "time_zone", timeZone, "error", err)
+		location = time.UTC
 	}
 
+	uc.locations.Store(timeZone, location)
+	r.location = location
+
+	return r
+}
+
+func (uc *rateLimitUseCase) VerifyLimit(ctx context.Context, key string) bool {
+	limit, _ := uc.verify(ctx, 1, []level{{key: clientKey(key), rule: uc.findRule(key), header: "Ratelimit"}}, false)
+
 	return limit
 }
 
-func (uc *rateLimitUseCase) validateCacheLimit(ctx context.Context, rate *entities.RateLimiter) (bool, error) {
-	if rate.Remaining <= 0 && rate.Every > 0 {
-		return false, nil
+// Check verifies the cost of the request against its policy, the rule of
+// the organization of the key and, when the request has a route, the
+// endpoint and global rules, and returns the decision with the rate limit
+// headers. The remaining and reset of the decision are of the window with
+// the fewest requests remaining
+func (uc *rateLimitUseCase) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	if request.Key == "" {
+		return entities.Decision{}, ErrInvalidKey
+	}
+
+	if request.Cost < 0 {
+		return entities.Decision{}, ErrInvalidCost
+	}
+
+	if request.Cost == 0 {
+		request.Cost = 1
+	}
+
+	key, rule, err := uc.resolve(request)
+	if err != nil {
+		return entities.Decision{}, err
+	}
+
+	levels, err := uc.hierarchyLevels(ctx, request.Key, key, request.Route)
+	if err != nil {
+		return entities.Decision{}, err
+	}
+
+	levels = append([]level{{key: key, rule: rule, header: "Ratelimit"}}, levels...)
+	levels = append(levels, uc.globalLevels(request.Route)...)
+
+	limit, rates := uc.verify(ctx, request.Cost, levels, request.Retry)
+
+	headers := map[string]string{}
+	closest := 0
+	remaining := make([]int, len(rates))
+
+	for i, rate := range rates {
+		remaining[i] = uc.remaining(rate, levels[i].rule)
+		uc.addHttpHeaders(headers, levels[i].header, rate, remaining[i])
+
+		if rate.Every > 0 && remaining[i] < remaining[closest] {
+			closest = i
+		}
+	}
+
+	return entities.Decision{
+		Allowed:   limit,
+		Remaining: remaining[closest],
+		Reset:     rates[closest].Reset,
+		Limit:     levels[closest].rule.requests,
+		Every:     levels[closest].rule.every,
+		Period:    levels[closest].rule.period,
+		Headers:   headers,
+	}, nil
+}
+
+// hierarchyLevels returns the levels of the org rule of the organization of
+// the key and of the first endpoint rule matching route, both
+// above and below the rule of the key. The endpoint windows are counted per
+// cache key of the request
+func (uc *rateLimitUseCase) hierarchyLevels(ctx context.Context, key, cacheKey, route string) ([]level, error) {
+	var levels []level
+
+	name, err := uc.orgs.Org(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("resolve the organization of the key: %w", err)
+	}
+
+	if index := slices.IndexFunc(uc.limits.Org, func(s rate_limiter.Org) bool {
+		return name != "" && s.Name == name
+	}); index >= 0 {
+		org := uc.limits.Org[index]
+
+		levels = append(levels, level{
+			key:    reservedPrefix + "org:" + org.Name,
+			rule:   uc.newRule("org_"+org.Name, org.Requests, org.Every, org.DryRun, org.Period, org.TimeZone),
+			header: "Ratelimit-Org",
+		})
+	}
+
+	if index := slices.IndexFunc(uc.limits.Endpoint, func(s rate_limiter.Endpoint) bool {
+		return route != "" && domain.MatchRoute(route, s.Path)
+	}); index >= 0 {
+		endpoint := uc.limits.Endpoint[index]
+
+		levels = append(levels, level{
+			key:    reservedPrefix + "endpoint:" + endpoint.Path + ":" + cacheKey,
+			rule:   uc.newRule(fmt.Sprintf("endpoint_%d", index), endpoint.Requests, endpoint.Every, endpoint.DryRun, "", ""),
+			header: "Ratelimit-Endpoint",
+		})
+	}
+
+	return levels, nil
+}
+
+// globalLevels returns the levels of the global rule and of the first route
+// rule matching route, none when route is empty
+func (uc *rateLimitUseCase) globalLevels(route string) []level {
+	if route == "" {
+		return nil
+	}
+
+	var levels []level
+
+	if global := uc.limits.Global; global != nil && global.Requests > 0 {
+		levels = append(levels, level{
+			key:    reservedPrefix + "global",
+			rule:   uc.newRule("global", global.Requests, global.Every, global.DryRun, "", ""),
+			header: "Ratelimit-Global",
+			global: true,
+		})
+	}
+
+	if index := slices.IndexFunc(uc.limits.Route, func(s rate_limiter.Route) bool {
+		return domain.MatchRoute(route, s.Path)
+	}); index >= 0 {
+		r := uc.limits.Route[index]
+
+		levels = append(levels, level{
+			key:    reservedPrefix + "route:" + r.Path,
+			rule:   uc.newRule(fmt.Sprintf("route_%d", index), r.Requests, r.Every, r.DryRun, "", ""),
+			header: "Ratelimit-Route",
+			global: true,
+		})
+	}
+
+	return levels
+}
+
+// Usage returns the consumption of the key under its policy in the current
+// window without counting a request, a key without a window has used nothing
+func (uc *rateLimitUseCase) Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error) {
+	if request.Key == "" {
+		return entities.Usage{}, ErrInvalidKey
+	}
+
+	key, rule, err := uc.resolve(request)
+	if err != nil {
+		return entities.Usage{}, err
+	}
+
+	uc.mutex.Lock()
+	defer uc.mutex.Unlock()
+
+	rate, err := uc.getCache(ctx, key)
+	if err != nil {
+		rate = uc.newWindow(key, rule)
+	}
+
+	return entities.Usage{
+		Key:       request.Key,
+		Policy:    request.Policy,
+		Rule:      rule.name,
+		Period:    rule.period,
+		Limit:     rule.requests,
+		Used:      rate.Requests,
+		Remaining: uc.remaining(rate, rule),
+		Reset:     rate.Reset,
+	}, nil
+}
+
+// resolve returns the cache key and the rule of the request, the rule of the
+// policy when it is informed. The cache key starts with the domain of the
+// request when it is informed
+func (uc *rateLimitUseCase) resolve(request entities.CheckRequest) (string, rule, error) {
+	prefix := ""
+
+	if request.Domain != "" {
+		prefix = request.Domain + "|"
+	}
+
+	if request.Policy == "" {
+		return clientKey(prefix + request.Key), uc.findRule(request.Key), nil
+	}
+
+	rule, ok := uc.findPolicy(request.Policy)
+	if !ok {
+		return "", rule, fmt.Errorf("%w: %s", ErrPolicyNotFound, request.Policy)
+	}
+
+	return clientKey(prefix + request.Policy + ":" + request.Key), rule, nil
+}
+
+// clientKey returns the cache key of the window of a client, escaping a key
+// starting with the reserved prefix with another one, so no client shares
+// the window of a global rule
+func clientKey(key string) string {
+	if strings.HasPrefix(key, reservedPrefix) {
+		return reservedPrefix + key
+	}
+
+	return key
+}
+
+// verify consumes cost requests of the window of every level when all of
+// them allow it, and of none otherwise, and returns if they are allowed with
+// the resulting rate limit windows. The first level is the key of the request.
+// The windows are written one at a time, when a write fails the windows
+// already written are rolled back and the request is not allowed. The
+// rejection of a retry is not sent to the usage recorder, the event publisher
+// nor the global metrics
+func (uc *rateLimitUseCase) verify(ctx context.Context, cost int, levels []level, retry bool) (bool, []*entities.RateLimiter) {
+	uc.mutex.Lock()
+	defer uc.mutex.Unlock()
+
+	ctx, span := uc.tracer.Start(ctx, "rate_limit.verify")
+	defer span.End()
+
+	rule := levels[0].rule
+
+	span.SetAttributes(
+		attribute.String("rate_limit.rule", rule.name),
+		attribute.Int("rate_limit.cost", cost),
+	)
+
+	rates := make([]*entities.RateLimiter, len(levels))
+
+	for i, level := range levels {
+		rate, err := uc.getCache(ctx, level.key)
+
+		if err != nil {
+			uc.logger.DebugContext(ctx, "starting rate limit window", "key", level.key, "rule", level.rule.name, "reason", err)
+
+			rate = uc.newWindow(level.key, level.rule)
+		}
+
+		rates[i] = rate
+	}
+
+	if banned, ok := uc.banned(ctx, rates[0], rule); ok {
+		rates[0] = banned
+
+		if !retry {
+			uc.recordUsage(ctx, banned, rule, 0, cost)
+		}
+
+		uc.logger.DebugContext(ctx, "rate limit key banned", "key", banned.Key, "reset", banned.Reset)
+		span.SetAttributes(attribute.String("rate_limit.outcome", "banned"))
+
+		return false, rates
+	}
+
+	previous := make([]entities.RateLimiter, len(rates))
+
+	for i, rate := range rates {
+		previous[i] = *rate
+	}
+
+	rejected := -1
+
+	for i := len(levels) - 1; i >= 0; i-- {
+		if uc.exceeded(rates[i], cost, levels[i].rule) {
+			rejected = i
+		}
+	}
+
+	var err error
+
+	limit := rejected < 0
+
+	for i, level := range levels {
+		if rejected >= 0 && (retry || !uc.exceeded(rates[i], cost, level.rule)) {
+			continue
+		}
+
+		allowed, levelErr := uc.validateCacheLimit(ctx, rates[i], cost, level.rule)
+		limit = limit && allowed
+
+		if level.global {
+			uc.countGlobal(ctx, rates[i], level.rule, allowed)
+		}
+
+		if levelErr != nil {
+			*rates[i] = previous[i]
+			err = errors.Join(levelErr, uc.rollback(ctx, rates[:i], previous[:i]))
+
+			break
+		}
+	}
+
+	if rejected == 0 && err == nil && !retry && uc.limits.Ban != nil {
+		uc.strike(ctx, rates[0], rule)
+	}
+
+	rate, outcome := rates[0], "allowed"
+	limited := slices.ContainsFunc(rates, func(r *entities.RateLimiter) bool { return r.Limited })
+
+	switch {
+	case err != nil:
+		outcome = "limited"
+		uc.logger.ErrorContext(ctx, "failed to update rate limit", "key", rate.Key, "rule", rule.name, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case !limit && retry:
+		outcome = "limited"
+	case !limit:
+		outcome = "limited"
+		uc.logger.InfoContext(ctx, "rate limit exceeded", "key", rate.Key, "rule", levels[rejected].rule.name, "reset", rates[rejected].Reset)
+	case limited:
+		outcome = "dry_run_limited"
+		uc.logger.InfoContext(ctx, "rate limit exceeded in dry run", "key", rate.Key, "rule", rule.name, "reset", rate.Reset)
+	}
+
+	span.SetAttributes(
+		attribute.Int("rate_limit.remaining", uc.remaining(rate, levels[0].rule)),
+		attribute.String("rate_limit.outcome", outcome),
+		attribute.Bool("rate_limit.dry_run", rule.dryRun),
+	)
+
+	return limit, rates
+}
+
+// banned returns the window of the key while it is banned, with no
+// requests remaining until the end of the ban
+func (uc *rateLimitUseCase) banned(ctx context.Context, rate *entities.RateLimiter, rule rule) (*entities.RateLimiter, bool) {
+	if uc.limits.Ban == nil {
+		return nil, false
+	}
+
+	ban, err := uc.getCache(ctx, reservedPrefix+"ban:"+rate.Key)
+	if err != nil || ban.Reset <= uc.clock.Now().Unix() {
+		return nil, false
+	}
+
+	return &entities.RateLimiter{
+		Key:      rate.Key,
+		Every:    rate.Every,
+		Requests: max(rate.Requests, rule.requests),
+		Reset:    ban.Reset,
+	}, true
+}
+
+// strike counts a rejection of the key by its own rule and bans the key when
+// it was rejected After times within the ban window. The strikes are kept
+// until the window ends, so a key rejected again right after its ban ends is
+// banned again
+func (uc *rateLimitUseCase) strike(ctx context.Context, rate *entities.RateLimiter, keyRule rule) {
+	ban := uc.limits.Ban
+	key := reservedPrefix + "strikes:" + rate.Key
+	now := uc.clock.Now().Unix()
+
+	strikes, err := uc.getCache(ctx, key)
+	if err != nil {
+		strikes = uc.newWindow(key, rule{requests: ban.After, base: ban.After, every: ban.Window})
+	}
+
+	strikes.Requests++
+	strikes.Remaining = max(strikes.Remaining-1, 0)
+
+	if err := uc.setCache(ctx, *strikes, time.Duration(strikes.Reset-now)*time.Second); err != nil {
+		uc.logger.WarnContext(ctx, "failed to count the rate limit strike", "key", rate.Key, "error", err)
+
+		return
+	}
+
+	if strikes.Requests < ban.After {
+		return
+	}
+
+	banned := entities.RateLimiter{
+		Key:      rate.Key,
+		Requests: strikes.Requests,
+		Reset:    now + int64(ban.Duration),
+	}
+
+	stored := banned
+	stored.Key = reservedPrefix + "ban:" + rate.Key
+
+	if err := uc.setCache(ctx, stored, time.Duration(ban.Duration)*time.Second); err != nil {
+		uc.logger.WarnContext(ctx, "failed to ban the rate limit key", "key", rate.Key, "error", err)
+
+		return
+	}
+
+	uc.logger.WarnContext(ctx, "rate limit key banned", "key", rate.Key, "rule", keyRule.name, "reset", banned.Reset)
+	uc.publish(ctx, entities.EventKeyBanned, &banned, keyRule)
+}
+
+// rollback undoes the requests counted in the decision before a write
+// failed, writing the window read with the requests written as counted, so
+// the stores that add the requests counted since the window was read remove
+// them. The request of another instance counted in between is kept by those
+// stores, but rolled back too by the stores that replace the window
+func (uc *rateLimitUseCase) rollback(ctx context.Context, rates []*entities.RateLimiter, previous []entities.RateLimiter) error {
+	var err error
+
+	for i, rate := range rates {
+		if rate.Requests == previous[i].Requests {
+			continue
+		}
+
+		written := rate.Requests
+		*rate = previous[i]
+
+		compensation := previous[i]
+		compensation.Counted = written
+		every := (time.Duration(rate.Reset) - time.Duration(uc.clock.Now().Unix())) * time.Second
+
+		err = errors.Join(err, uc.setCache(ctx, compensation, every))
+	}
+
+	return err
+}
+
+// exceeded reports if the window has fewer than cost requests remaining
+// under a rule that is not in dry run
+func (uc *rateLimitUseCase) exceeded(rate *entities.RateLimiter, cost int, rule rule) bool {
+	return uc.remaining(rate, rule) < cost && rate.Every > 0 && !rule.dryRun
+}
+
+// remaining returns the requests remaining in the window under the limit of
+// the rule. The window counts down from the configured limit, so a scaled
+// limit moves the requests remaining by its difference to it, and never
+// leaves more than the limit minus the requests of the window
+func (uc *rateLimitUseCase) remaining(rate *entities.RateLimiter, rule rule) int {
+	if rule.requests == rule.base || rate.Every <= 0 {
+		return rate.Remaining
+	}
+
+	return max(min(rate.Remaining+rule.requests-rule.base, rule.requests-rate.Requests), 0)
+}
+
+// countGlobal adds the request checked against a global rule to the metrics
+func (uc *rateLimitUseCase) countGlobal(ctx context.Context, rate *entities.RateLimiter, rule rule, allowed bool) {
+	outcome := "allowed"
+
+	switch {
+	case !allowed:
+		outcome = "limited"
+	case rate.Limited:
+		outcome = "dry_run_limited"
+	}
+
+	uc.globalRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("rate_limit.rule", rule.name),
+		attribute.String("rate_limit.outcome", outcome),
+	))
+}
+
+// findRule returns the configured rule for the token or IP informed,
+// falling back to the default rule
+func (uc *rateLimitUseCase) findRule(key string) rule {
+	if index := slices.IndexFunc(uc.limits.Token, func(s rate_limiter.Token) bool {
+		return s.Token == key
+	}); index >= 0 {
+		token := uc.limits.Token[index]
+
+		return uc.newRule(fmt.Sprintf("token_%d", index), token.Requests, token.Every, token.DryRun, token.Period, token.TimeZone)
+	}
+
+	if index := slices.IndexFunc(uc.limits.IP, func(s rate_limiter.IP) bool {
+		return s.IP == key
+	}); index >= 0 {
+		ip := uc.limits.IP[index]
+
+		return uc.newRule(fmt.Sprintf("ip_%d", index), ip.Requests, ip.Every, ip.DryRun, ip.Period, ip.TimeZone)
+	}
+
+	defaults := uc.limits.Default
+
+	return uc.newRule("default", defaults.Requests, defaults.Every, defaults.DryRun, defaults.Period, defaults.TimeZone)
+}
+
+// findPolicy returns the rule of the named policy
+func (uc *rateLimitUseCase) findPolicy(name string) (rule, bool) {
+	index := slices.IndexFunc(uc.limits.Policy, func(s rate_limiter.Policy) bool {
+		return s.Name == name
+	})
+
+	if index < 0 {
+		return rule{}, false
 	}
 
-	rate.Requests++
+	policy := uc.limits.Policy[index]
+
+	return uc.newRule("policy_"+name, policy.Requests, policy.Every, policy.DryRun, policy.Period, policy.TimeZone), true
+}
 
-	if rate.Every > 0 && rate.Remaining > 0 {
-		rate.Remaining--
+// validateCacheLimit counts the request in the rate limit window, under a
+// dry-run rule an exceeded window is marked as limited but still allowed.
+// The requests counted and rejected are sent to the usage recorder, and the
+// exceeded and nearly exhausted windows to the event publisher
+func (uc *rateLimitUseCase) validateCacheLimit(ctx context.Context, rate *entities.RateLimiter, cost int, rule rule) (bool, error) {
+	if uc.remaining(rate, rule) < cost && rate.Every > 0 {
+		if !rule.dryRun {
+			uc.recordUsage(ctx, rate, rule, 0, cost)
+			uc.publish(ctx, entities.EventLimitExceeded, rate, rule)
+
+			return false, nil
+		}
+
+		rate.Limited = true
+		uc.publish(ctx, entities.EventLimitExceeded, rate, rule)
 	}
 
-	every := (time.Duration(rate.Reset) - time.Duration(time.Now().Unix())) * time.Second
+	rate.Requests += cost
+
+	if rate.Every > 0 {
+		rate.Remaining = max(rate.Remaining-cost, 0)
+	}
 
-	if err := uc.cache.Set(ctx, *rate, every); err != nil {
+	every := (time.Duration(rate.Reset) - time.Duration(uc.clock.Now().Unix())) * time.Second
+
+	if err := uc.setCache(ctx, *rate, every); err != nil {
 		return false, err
 	}
 
+	uc.recordUsage(ctx, rate, rule, cost, 0)
+
+	if uc.crossedNearlyExhausted(rate, cost, rule) {
+		uc.publish(ctx, entities.EventQuotaNearlyExhausted, rate, rule)
+	}
+
 	return true, nil
 }
 
+// crossedNearlyExhausted reports if the cost counted took the requests of
+// the window to the nearly exhausted percentage of the limit
+func (uc *rateLimitUseCase) crossedNearlyExhausted(rate *entities.RateLimiter, cost int, rule rule) bool {
+	if uc.nearlyExhausted <= 0 || rule.requests <= 0 || rate.Every <= 0 {
+		return false
+	}
+
+	threshold := rule.requests * uc.nearlyExhausted
+
+	return (rate.Requests-cost)*100 < threshold && rate.Requests*100 >= threshold
+}
+
+func (uc *rateLimitUseCase) publish(ctx context.Context, eventType string, rate *entities.RateLimiter, rule rule) {
+	if uc.events == nil {
+		return
+	}
+
+	uc.events.Publish(ctx, entities.Event{
+		Type:      eventType,
+		Key:       rate.Key,
+		Rule:      rule.name,
+		Limit:     rule.requests,
+		Remaining: uc.remaining(rate, rule),
+		Reset:     rate.Reset,
+		Time:      uc.clock.Now().Unix(),
+		DryRun:    rule.dryRun,
+	})
+}
+
+func (uc *rateLimitUseCase) recordUsage(ctx context.Context, rate *entities.RateLimiter, rule rule, requests, rejected int) {
+	if uc.usage == nil {
+		return
+	}
+
+	uc.usage.Record(ctx, entities.UsageRecord{
+		Key:      rate.Key,
+		Rule:     rule.name,
+		Period:   rule.period,
+		Reset:    rate.Reset,
+		Requests: requests,
+		Rejected: rejected,
+	})
+}
+
 func (uc *rateLimitUseCase) GetHttpHeaders(ctx context.Context, key string) map[string]string {
-	rate, err := uc.cache.Get(ctx, key)
+	rate, err := uc.getCache(ctx, clientKey(key))
 	if err != nil {
 		return map[string]string{}
 	}
 
-	every := (time.Duration(rate.Reset) - time.Duration(time.Now().Unix())) * time.Second
+	return uc.httpHeaders(rate, uc.findRule(key))
+}
 
-	headers := map[string]string{
-		"Ratelimit-Limit":     fmt.Sprintf("%v", rate.Requests),
-		"Ratelimit-Remaining": fmt.Sprintf("%v", rate.Remaining),
-		"Ratelimit-Reset":     fmt.Sprintf("%v", every),
-	}
+func (uc *rateLimitUseCase) httpHeaders(rate *entities.RateLimiter, rule rule) map[string]string {
+	headers := map[string]string{}
+
+	uc.addHttpHeaders(headers, "Ratelimit", rate, uc.remaining(rate, rule))
 
 	return headers
 }
+
+// addHttpHeaders adds the headers of the window with the requests remaining
+// under its rule, starting with prefix
+func (uc *rateLimitUseCase) addHttpHeaders(headers map[string]string, prefix string, rate *entities.RateLimiter, remaining int) {
+	every := (time.Duration(rate.Reset) - time.Duration(uc.clock.Now().Unix())) * time.Second
+
+	headers[prefix+"-Limit"] = fmt.Sprintf("%v", rate.Requests)
+	headers[prefix+"-Remaining"] = fmt.Sprintf("%v", remaining)
+	headers[prefix+"-Reset"] = fmt.Sprintf("%v", every)
+
+	if rate.Limited && uc.limits.DryRunHeader {
+		headers[prefix+"-Dry-Run"] = "limited"
+	}
+}
+
+func (uc *rateLimitUseCase) getCache(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	ctx, span := uc.tracer.Start(ctx, "rate_limit.cache.get")
+	defer span.End()
+
+	rate, err := uc.cache.Get(ctx, key)
+
+	span.SetAttributes(attribute.Bool("rate_limit.cache.hit", err == nil))
+
+	if err != nil && !errors.Is(err, domain.ErrWindowNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		uc.logger.WarnContext(ctx, "failed to get the rate limit window", "key", key, "error", err)
+	}
+
+	return rate, err
+}
+
+func (uc *rateLimitUseCase) setCache(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	ctx, span := uc.tracer.Start(ctx, "rate_limit.cache.set")
+	defer span.End()
+
+	err := uc.cache.Set(ctx, rate, every)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
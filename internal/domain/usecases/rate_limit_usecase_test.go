@@ -1,19 +1,36 @@
 package usecases_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	_ "modernc.org/sqlite"
 )
 
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
 type mockRateLimitCache struct {
 	mock.Mock
 }
@@ -84,16 +101,16 @@ func TestRateLimitUseCase_VerifyLimit(t *testing.T) {
 		key := "token1"
 		cache := new(mockRateLimitCache)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       "token1",
 			Every:     30,
 			Remaining: 10,
 			Requests:  40,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, nil)
-		cache.On("Set", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 
 		result := useCase.VerifyLimit(ctx, key)
 
@@ -105,16 +122,16 @@ func TestRateLimitUseCase_VerifyLimit(t *testing.T) {
 		key := "token1"
 		cache := new(mockRateLimitCache)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       "token1",
 			Every:     30,
 			Remaining: 10,
 			Requests:  40,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, nil)
-		cache.On("Set", ctx, mock.Anything, mock.Anything).Return(errors.New("error")).Once()
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("error")).Once()
 
 		result := useCase.VerifyLimit(ctx, key)
 
@@ -127,14 +144,14 @@ func TestRateLimitUseCase_VerifyLimit(t *testing.T) {
 
 		cache := new(mockRateLimitCache)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       key,
 			Every:     30,
 			Remaining: 0,
 			Requests:  50,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, nil)
 
 		result := useCase.VerifyLimit(ctx, key)
@@ -172,15 +189,15 @@ func TestRateLimitUseCase_VerifyLimit_ReadConfig(t *testing.T) {
 		key := "token1"
 		cache := new(mockRateLimitCache2)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       "token1",
 			Every:     30,
 			Remaining: 10,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, errors.New("not found")).Times(3)
-		cache.On("Set", ctx, mock.Anything, mock.Anything).Return(nil)
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 		result := useCase.VerifyLimit(ctx, key)
 
@@ -192,15 +209,15 @@ func TestRateLimitUseCase_VerifyLimit_ReadConfig(t *testing.T) {
 		key := "127.0.0.1"
 		cache := new(mockRateLimitCache2)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       "127.0.0.1",
 			Every:     30,
 			Remaining: 10,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, errors.New("not found")).Times(3)
-		cache.On("Set", ctx, mock.Anything, mock.Anything).Return(nil)
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 		result := useCase.VerifyLimit(ctx, key)
 
@@ -238,14 +255,14 @@ func TestRateLimitUseCase_GetHttpHeaders(t *testing.T) {
 
 		cache := new(mockRateLimitCache)
 
-		useCase := usecases.NewRateLimitUseCase(config, cache)
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithClock(ratelimittest.NewClock(now)))
 
-		cache.On("Get", ctx, key).Return(&entities.RateLimiter{
+		cache.On("Get", mock.Anything, key).Return(&entities.RateLimiter{
 			Key:       key,
 			Every:     30,
 			Remaining: 10,
 			Requests:  40,
-			Reset:     time.Now().Add(30 * time.Second).Unix(),
+			Reset:     now.Add(30 * time.Second).Unix(),
 		}, nil)
 
 		headers := useCase.GetHttpHeaders(ctx, key)
@@ -263,10 +280,1188 @@ func TestRateLimitUseCase_GetHttpHeaders(t *testing.T) {
 
 		useCase := usecases.NewRateLimitUseCase(config, cache)
 
-		cache.On("Get", ctx, key).Return(nil, errors.New("not found"))
+		cache.On("Get", mock.Anything, key).Return(nil, errors.New("not found"))
 
 		headers := useCase.GetHttpHeaders(ctx, key)
 
 		assert.Empty(t, headers)
 	})
 }
+
+func TestRateLimitUseCase_VerifyLimit_Tracing(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Every:    60,
+				Requests: 100,
+			},
+			Token: []rate_limiter.Token{
+				{
+					Token:    "token1",
+					Every:    30,
+					Requests: 1,
+				},
+			},
+		},
+	}
+
+	t.Run("Should record decision and storage spans", func(t *testing.T) {
+		ctx := context.Background()
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory(), usecases.WithTracerProvider(provider))
+
+		assert.True(t, useCase.VerifyLimit(ctx, "token1"))
+		assert.False(t, useCase.VerifyLimit(ctx, "token1"))
+
+		spans := exporter.GetSpans()
+
+		names := []string{}
+		for _, span := range spans {
+			names = append(names, span.Name)
+		}
+
+		assert.Equal(t, []string{
+			"rate_limit.cache.get",
+			"rate_limit.cache.set",
+			"rate_limit.verify",
+			"rate_limit.cache.get",
+			"rate_limit.verify",
+		}, names)
+
+		allowed := spanAttributes(spans[2])
+		assert.Equal(t, "token_0", allowed["rate_limit.rule"].AsString())
+		assert.Equal(t, int64(0), allowed["rate_limit.remaining"].AsInt64())
+		assert.Equal(t, "allowed", allowed["rate_limit.outcome"].AsString())
+
+		limited := spanAttributes(spans[4])
+		assert.Equal(t, "limited", limited["rate_limit.outcome"].AsString())
+
+		assert.Equal(t, spans[2].SpanContext.SpanID(), spans[0].Parent.SpanID())
+		assert.False(t, spanAttributes(spans[0])["rate_limit.cache.hit"].AsBool())
+		assert.True(t, spanAttributes(spans[3])["rate_limit.cache.hit"].AsBool())
+	})
+
+	t.Run("Should record error when set cache fails", func(t *testing.T) {
+		ctx := context.Background()
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		cache := new(mockRateLimitCache)
+
+		useCase := usecases.NewRateLimitUseCase(config, cache, usecases.WithTracerProvider(provider))
+
+		cache.On("Get", mock.Anything, "token1").Return(nil, errors.New("not found"))
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("error"))
+
+		assert.False(t, useCase.VerifyLimit(ctx, "token1"))
+
+		spans := exporter.GetSpans()
+
+		assert.Len(t, spans, 3)
+		assert.Equal(t, codes.Error, spans[1].Status.Code)
+		assert.Equal(t, codes.Error, spans[2].Status.Code)
+	})
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+
+	return attributes
+}
+
+func TestRateLimitUseCase_VerifyLimit_Logging(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Every:    60,
+				Requests: 1,
+			},
+		},
+	}
+
+	t.Run("Should log rejections", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory(),
+			usecases.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		)
+
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.NotContains(t, buf.String(), "rate limit exceeded")
+
+		assert.False(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.Contains(t, buf.String(), `level=INFO msg="rate limit exceeded" key=127.0.0.1 rule=default`)
+	})
+
+	t.Run("Should log cache errors", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+		cache := new(mockRateLimitCache)
+
+		useCase := usecases.NewRateLimitUseCase(config, cache,
+			usecases.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		)
+
+		cache.On("Get", mock.Anything, "127.0.0.1").Return(nil, errors.New("connection refused"))
+		cache.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+		assert.False(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.Contains(t, buf.String(), `level=WARN msg="failed to get the rate limit window" key=127.0.0.1 error="connection refused"`)
+		assert.Contains(t, buf.String(), `level=ERROR msg="failed to update rate limit" key=127.0.0.1 rule=default error="connection refused"`)
+	})
+
+	t.Run("Should not log the windows not found as cache errors", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory(),
+			usecases.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		)
+
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.NotContains(t, buf.String(), "level=WARN")
+	})
+}
+
+func TestRateLimitUseCase_VerifyLimit_DryRun(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Every:    60,
+				Requests: 1,
+			},
+			Token: []rate_limiter.Token{
+				{
+					Token:    "token1",
+					Every:    60,
+					Requests: 1,
+					DryRun:   true,
+				},
+			},
+		},
+	}
+
+	t.Run("Should allow and count requests over the limit of a dry-run rule", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+		cache := strategies.NewRateLimitInMemory()
+
+		useCase := usecases.NewRateLimitUseCase(config, cache,
+			usecases.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		)
+
+		assert.True(t, useCase.VerifyLimit(ctx, "token1"))
+		assert.NotContains(t, buf.String(), "rate limit exceeded in dry run")
+
+		assert.True(t, useCase.VerifyLimit(ctx, "token1"))
+		assert.True(t, useCase.VerifyLimit(ctx, "token1"))
+		assert.Contains(t, buf.String(), `msg="rate limit exceeded in dry run" key=token1 rule=token_0`)
+
+		rate, err := cache.Get(ctx, "token1")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, rate.Requests)
+		assert.Equal(t, 0, rate.Remaining)
+		assert.True(t, rate.Limited)
+	})
+
+	t.Run("Should keep enforcing rules without dry-run", func(t *testing.T) {
+		ctx := context.Background()
+
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory())
+
+		assert.True(t, useCase.VerifyLimit(ctx, "token2"))
+		assert.False(t, useCase.VerifyLimit(ctx, "token2"))
+	})
+
+	t.Run("Should add the dry-run header only when enabled", func(t *testing.T) {
+		ctx := context.Background()
+
+		for _, tc := range []struct {
+			dryRunHeader bool
+			expected     string
+		}{
+			{dryRunHeader: false, expected: ""},
+			{dryRunHeader: true, expected: "limited"},
+		} {
+			cfg := config
+			cfg.RateLimiter.DryRunHeader = tc.dryRunHeader
+
+			useCase := usecases.NewRateLimitUseCase(cfg, strategies.NewRateLimitInMemory())
+
+			useCase.VerifyLimit(ctx, "token1")
+			assert.NotContains(t, useCase.GetHttpHeaders(ctx, "token1"), "Ratelimit-Dry-Run")
+
+			useCase.VerifyLimit(ctx, "token1")
+			assert.Equal(t, tc.expected, useCase.GetHttpHeaders(ctx, "token1")["Ratelimit-Dry-Run"])
+		}
+	})
+
+	t.Run("Should record the dry-run outcome in the span", func(t *testing.T) {
+		ctx := context.Background()
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory(), usecases.WithTracerProvider(provider))
+
+		useCase.VerifyLimit(ctx, "token1")
+		useCase.VerifyLimit(ctx, "token1")
+
+		spans := exporter.GetSpans()
+		attributes := spanAttributes(spans[len(spans)-1])
+
+		assert.Equal(t, "dry_run_limited", attributes["rate_limit.outcome"].AsString())
+		assert.True(t, attributes["rate_limit.dry_run"].AsBool())
+	})
+}
+
+func TestRateLimitUseCase_Check(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Every:    60,
+				Requests: 10,
+			},
+			Token: []rate_limiter.Token{
+				{
+					Token:    "token1",
+					Every:    30,
+					Requests: 5,
+				},
+			},
+			Policy: []rate_limiter.Policy{
+				{
+					Name:     "upload",
+					Every:    60,
+					Requests: 2,
+				},
+			},
+		},
+	}
+
+	t.Run("Should consume the cost from the rule of the key", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory())
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token1", Cost: 3})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Remaining)
+		assert.Equal(t, "3", decision.Headers["Ratelimit-Limit"])
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1", Cost: 3})
+
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Remaining)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1", Cost: 2})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+	})
+
+	t.Run("Should use cost 1 when cost is not informed", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory())
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "127.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 9, decision.Remaining)
+		assert.Greater(t, decision.Reset, time.Now().Unix())
+	})
+
+	t.Run("Should use the named policy with its own window", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory())
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token1", Policy: "upload", Cost: 2})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1", Policy: "upload"})
+
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1"})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 4, decision.Remaining)
+	})
+
+	t.Run("Should return error when request is invalid", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemory())
+
+		_, err := useCase.Check(ctx, entities.CheckRequest{})
+		assert.ErrorIs(t, err, usecases.ErrInvalidKey)
+
+		_, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1", Cost: -1})
+		assert.ErrorIs(t, err, usecases.ErrInvalidCost)
+
+		_, err = useCase.Check(ctx, entities.CheckRequest{Key: "token1", Policy: "unknown"})
+		assert.ErrorIs(t, err, usecases.ErrPolicyNotFound)
+	})
+}
+
+func TestNew(t *testing.T) {
+	limits := rate_limiter.RateLimiterConfig{
+		Default: rate_limiter.Default{
+			Every:    60,
+			Requests: 1,
+		},
+	}
+
+	t.Run("Should return error when the cache is not informed", func(t *testing.T) {
+		useCase, err := usecases.New(usecases.WithLimits(limits))
+
+		assert.Nil(t, useCase)
+		assert.ErrorIs(t, err, usecases.ErrMissingCache)
+	})
+
+	t.Run("Should keep instances with different limits independent", func(t *testing.T) {
+		ctx := context.Background()
+
+		strict, err := usecases.New(
+			usecases.WithLimits(limits),
+			usecases.WithCache(strategies.NewRateLimitInMemory()),
+		)
+		assert.NoError(t, err)
+
+		loose, err := usecases.New(
+			usecases.WithLimits(rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Every: 60, Requests: 3},
+			}),
+			usecases.WithCache(strategies.NewRateLimitInMemory()),
+		)
+		assert.NoError(t, err)
+
+		assert.True(t, strict.VerifyLimit(ctx, "127.0.0.1"))
+		assert.False(t, strict.VerifyLimit(ctx, "127.0.0.1"))
+
+		assert.True(t, loose.VerifyLimit(ctx, "127.0.0.1"))
+		assert.True(t, loose.VerifyLimit(ctx, "127.0.0.1"))
+	})
+
+	t.Run("Should start the windows with the time of the clock", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+
+		useCase, err := usecases.New(
+			usecases.WithLimits(limits),
+			usecases.WithCache(strategies.NewRateLimitInMemoryClock(clock)),
+			usecases.WithClock(clock),
+		)
+		assert.NoError(t, err)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "127.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, now.Add(time.Minute).Unix(), decision.Reset)
+		assert.Equal(t, "1m0s", decision.Headers["Ratelimit-Reset"])
+	})
+}
+
+func TestRateLimitUseCase_VerifyLimit_Window(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Every:    60,
+				Requests: 2,
+			},
+		},
+	}
+
+	newUseCase := func(clock *ratelimittest.Clock) usecases.RateLimitUseCase {
+		return usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock), usecases.WithClock(clock))
+	}
+
+	t.Run("Should count down the reset header as the clock advances", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		useCase := newUseCase(clock)
+
+		useCase.VerifyLimit(ctx, "127.0.0.1")
+		assert.Equal(t, "1m0s", useCase.GetHttpHeaders(ctx, "127.0.0.1")["Ratelimit-Reset"])
+
+		clock.Advance(45 * time.Second)
+		assert.Equal(t, "15s", useCase.GetHttpHeaders(ctx, "127.0.0.1")["Ratelimit-Reset"])
+	})
+
+	t.Run("Should keep rejecting until the window expires", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		useCase := newUseCase(clock)
+
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.False(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+
+		clock.Advance(60 * time.Second)
+		assert.False(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+	})
+
+	t.Run("Should start a new window after the reset", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		useCase := newUseCase(clock)
+
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.True(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+		assert.False(t, useCase.VerifyLimit(ctx, "127.0.0.1"))
+
+		clock.Advance(61 * time.Second)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "127.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 1, decision.Remaining)
+		assert.Equal(t, now.Add(121*time.Second).Unix(), decision.Reset)
+	})
+}
+
+func TestRateLimitUseCase_VerifyLimit_Quota(t *testing.T) {
+	newUseCase := func(clock *ratelimittest.Clock, period, timeZone string) usecases.RateLimitUseCase {
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{
+					Requests: 2,
+					Every:    60,
+					Period:   period,
+					TimeZone: timeZone,
+				},
+			},
+		}
+
+		return usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock), usecases.WithClock(clock))
+	}
+
+	t.Run("Should reset the windows at the start of the next period", func(t *testing.T) {
+		saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+		assert.NoError(t, err)
+
+		// friday, march 1st of 2024 at 12:00 UTC
+		for _, tc := range []struct {
+			period   string
+			timeZone string
+			reset    time.Time
+		}{
+			{rate_limiter.PeriodDay, "", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+			{rate_limiter.PeriodWeek, "", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+			{rate_limiter.PeriodMonth, "", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+			{rate_limiter.PeriodMonth, "America/Sao_Paulo", time.Date(2024, 4, 1, 0, 0, 0, 0, saoPaulo)},
+		} {
+			useCase := newUseCase(ratelimittest.NewClock(now), tc.period, tc.timeZone)
+
+			decision, err := useCase.Check(context.Background(), entities.CheckRequest{Key: "token_1"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.reset.Unix(), decision.Reset, tc.period+" "+tc.timeZone)
+		}
+	})
+
+	t.Run("Should start a week on the next monday when it is monday", func(t *testing.T) {
+		monday := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+		useCase := newUseCase(ratelimittest.NewClock(monday), rate_limiter.PeriodWeek, "")
+
+		decision, err := useCase.Check(context.Background(), entities.CheckRequest{Key: "token_1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC).Unix(), decision.Reset)
+	})
+
+	t.Run("Should keep rejecting until the start of the next period", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		useCase := newUseCase(clock, rate_limiter.PeriodMonth, "")
+
+		assert.True(t, useCase.VerifyLimit(ctx, "token_1"))
+		assert.True(t, useCase.VerifyLimit(ctx, "token_1"))
+
+		clock.Advance(15 * 24 * time.Hour)
+		assert.False(t, useCase.VerifyLimit(ctx, "token_1"))
+
+		clock.Set(time.Date(2024, 4, 1, 0, 0, 1, 0, time.UTC))
+		assert.True(t, useCase.VerifyLimit(ctx, "token_1"))
+	})
+
+	t.Run("Should use UTC when the time zone is invalid", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 2, Period: rate_limiter.PeriodDay, TimeZone: "Mars/Olympus_Mons"},
+			},
+		}
+		clock := ratelimittest.NewClock(now)
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+		decision, err := useCase.Check(context.Background(), entities.CheckRequest{Key: "token_1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC).Unix(), decision.Reset)
+		assert.Contains(t, logs.String(), "invalid rate limit time zone, using UTC")
+	})
+}
+
+func TestRateLimitUseCase_Usage(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{Requests: 10, Every: 60},
+			Policy: []rate_limiter.Policy{
+				{Name: "plan", Requests: 1000, Period: rate_limiter.PeriodMonth},
+			},
+		},
+	}
+
+	newUseCase := func() usecases.RateLimitUseCase {
+		clock := ratelimittest.NewClock(now)
+		return usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock), usecases.WithClock(clock))
+	}
+
+	t.Run("Should return the usage of the period without counting a request", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase()
+
+		_, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Policy: "plan", Cost: 400})
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			usage, err := useCase.Usage(ctx, entities.CheckRequest{Key: "token_1", Policy: "plan"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, entities.Usage{
+				Key:       "token_1",
+				Policy:    "plan",
+				Rule:      "policy_plan",
+				Period:    rate_limiter.PeriodMonth,
+				Limit:     1000,
+				Used:      400,
+				Remaining: 600,
+				Reset:     time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			}, usage)
+		}
+	})
+
+	t.Run("Should return nothing used when the key has no window", func(t *testing.T) {
+		usage, err := newUseCase().Usage(context.Background(), entities.CheckRequest{Key: "127.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Usage{
+			Key:       "127.0.0.1",
+			Rule:      "default",
+			Limit:     10,
+			Remaining: 10,
+			Reset:     now.Add(time.Minute).Unix(),
+		}, usage)
+	})
+
+	t.Run("Should return error when the key is empty or the policy does not exist", func(t *testing.T) {
+		useCase := newUseCase()
+
+		_, err := useCase.Usage(context.Background(), entities.CheckRequest{})
+		assert.ErrorIs(t, err, usecases.ErrInvalidKey)
+
+		_, err = useCase.Usage(context.Background(), entities.CheckRequest{Key: "token_1", Policy: "unknown"})
+		assert.ErrorIs(t, err, usecases.ErrPolicyNotFound)
+	})
+}
+
+type usageRecorder struct {
+	records []entities.UsageRecord
+}
+
+func (r *usageRecorder) Record(ctx context.Context, record entities.UsageRecord) {
+	r.records = append(r.records, record)
+}
+
+func TestRateLimitUseCase_VerifyLimit_UsageRecorder(t *testing.T) {
+	t.Run("Should record the requests allowed and rejected of each window", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		recorder := &usageRecorder{}
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 2, Every: 60},
+				Policy: []rate_limiter.Policy{
+					{Name: "plan", Requests: 100, Period: rate_limiter.PeriodMonth},
+				},
+			},
+		}
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithUsageRecorder(recorder))
+
+		useCase.VerifyLimit(ctx, "127.0.0.1")
+		useCase.VerifyLimit(ctx, "127.0.0.1")
+		useCase.VerifyLimit(ctx, "127.0.0.1")
+		_, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Policy: "plan", Cost: 5})
+		assert.NoError(t, err)
+
+		reset := now.Add(time.Minute).Unix()
+		month := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+		assert.Equal(t, []entities.UsageRecord{
+			{Key: "127.0.0.1", Rule: "default", Reset: reset, Requests: 1},
+			{Key: "127.0.0.1", Rule: "default", Reset: reset, Requests: 1},
+			{Key: "127.0.0.1", Rule: "default", Reset: reset, Rejected: 1},
+			{Key: "plan:token_1", Rule: "policy_plan", Period: rate_limiter.PeriodMonth, Reset: month, Requests: 5},
+		}, recorder.records)
+	})
+}
+
+type eventPublisher struct {
+	events []entities.Event
+}
+
+func (p *eventPublisher) Publish(ctx context.Context, event entities.Event) {
+	p.events = append(p.events, event)
+}
+
+func TestRateLimitUseCase_VerifyLimit_Events(t *testing.T) {
+	newUseCase := func(publisher *eventPublisher, dryRun bool, opts ...usecases.Option) usecases.RateLimitUseCase {
+		clock := ratelimittest.NewClock(now)
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 10, Every: 60, DryRun: dryRun},
+			},
+		}
+
+		return usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			append([]usecases.Option{usecases.WithClock(clock), usecases.WithEventPublisher(publisher)}, opts...)...)
+	}
+
+	reset := now.Add(time.Minute).Unix()
+
+	t.Run("Should emit the nearly exhausted and exceeded events of the window", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase := newUseCase(publisher, false)
+
+		for i := 0; i < 12; i++ {
+			useCase.VerifyLimit(ctx, "token_1")
+		}
+
+		assert.Equal(t, []entities.Event{
+			{Type: entities.EventQuotaNearlyExhausted, Key: "token_1", Rule: "default", Limit: 10, Remaining: 2, Reset: reset, Time: now.Unix()},
+			{Type: entities.EventLimitExceeded, Key: "token_1", Rule: "default", Limit: 10, Remaining: 0, Reset: reset, Time: now.Unix()},
+			{Type: entities.EventLimitExceeded, Key: "token_1", Rule: "default", Limit: 10, Remaining: 0, Reset: reset, Time: now.Unix()},
+		}, publisher.events)
+	})
+
+	t.Run("Should emit the nearly exhausted event at the percentage informed", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase := newUseCase(publisher, false, usecases.WithNearlyExhaustedPercent(50))
+
+		_, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Cost: 4})
+		assert.NoError(t, err)
+		assert.Empty(t, publisher.events)
+
+		_, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Cost: 3})
+		assert.NoError(t, err)
+		assert.Len(t, publisher.events, 1)
+		assert.Equal(t, entities.EventQuotaNearlyExhausted, publisher.events[0].Type)
+	})
+
+	t.Run("Should not emit the events of a rejected retry", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase := newUseCase(publisher, false, usecases.WithNearlyExhaustedPercent(0))
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Cost: 10, Retry: true})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Retry: true})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Empty(t, publisher.events)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Len(t, publisher.events, 1)
+	})
+
+	t.Run("Should emit the exceeded events of a rule in dry run", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase := newUseCase(publisher, true, usecases.WithNearlyExhaustedPercent(0))
+
+		for i := 0; i < 11; i++ {
+			assert.True(t, useCase.VerifyLimit(ctx, "token_1"))
+		}
+
+		assert.Equal(t, []entities.Event{
+			{Type: entities.EventLimitExceeded, Key: "token_1", Rule: "default", Limit: 10, Remaining: 0, Reset: reset, Time: now.Unix(), DryRun: true},
+		}, publisher.events)
+	})
+}
+
+func TestRateLimitUseCase_Check_Ban(t *testing.T) {
+	newUseCase := func(publisher *eventPublisher) (usecases.RateLimitUseCase, *ratelimittest.Clock) {
+		clock := ratelimittest.NewClock(now)
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 1, Every: 60},
+				Ban:     &rate_limiter.Ban{After: 2, Window: 600, Duration: 3600},
+			},
+		}
+
+		return usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithEventPublisher(publisher), usecases.WithNearlyExhaustedPercent(0)), clock
+	}
+
+	t.Run("Should ban the keys rejected too often until the ban ends", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase, clock := newUseCase(publisher)
+		banEnd := now.Add(time.Hour).Unix()
+
+		for _, allowed := range []bool{true, false, false} {
+			decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+			assert.NoError(t, err)
+			assert.Equal(t, allowed, decision.Allowed)
+		}
+
+		assert.Equal(t, entities.Event{
+			Type: entities.EventKeyBanned, Key: "10.0.0.1", Rule: "default", Limit: 1, Remaining: 0, Reset: banEnd, Time: now.Unix(),
+		}, publisher.events[len(publisher.events)-1])
+
+		clock.Advance(time.Minute)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed, "the window reset but the key is banned")
+		assert.Equal(t, banEnd, decision.Reset)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.2"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		clock.Advance(time.Hour)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("Should not count the rejections of the retries", func(t *testing.T) {
+		ctx := context.Background()
+		publisher := &eventPublisher{}
+		useCase, _ := newUseCase(publisher)
+
+		for i := 0; i < 5; i++ {
+			_, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Retry: true})
+			assert.NoError(t, err)
+		}
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		for _, event := range publisher.events {
+			assert.NotEqual(t, entities.EventKeyBanned, event.Type)
+		}
+	})
+}
+
+type limitScaler float64
+
+func (s *limitScaler) Factor() float64 {
+	return float64(*s)
+}
+
+func TestRateLimitUseCase_VerifyLimit_LimitScaler(t *testing.T) {
+	t.Run("Should scale the limits of the windows started", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		factor := limitScaler(0.5)
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 10, Every: 60},
+			},
+		}
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithLimitScaler(&factor))
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, decision.Remaining)
+
+		// a factor change applies to the window already started
+		factor = 2
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 18, decision.Remaining)
+		assert.Equal(t, 20, decision.Limit)
+
+		clock.Advance(61 * time.Second)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 19, decision.Remaining)
+	})
+
+	t.Run("Should not scale the quotas of the rules with a period", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+		factor := limitScaler(0.5)
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 10, Period: rate_limiter.PeriodDay},
+			},
+		}
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithLimitScaler(&factor))
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 10, decision.Limit)
+		assert.Equal(t, 9, decision.Remaining)
+	})
+
+	t.Run("Should allow at least one request", func(t *testing.T) {
+		clock := ratelimittest.NewClock(now)
+		factor := limitScaler(0.01)
+		config := config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 10, Every: 60},
+			},
+		}
+		useCase := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock),
+			usecases.WithClock(clock), usecases.WithLimitScaler(&factor))
+
+		assert.True(t, useCase.VerifyLimit(context.Background(), "token_1"))
+		assert.False(t, useCase.VerifyLimit(context.Background(), "token_1"))
+	})
+}
+
+func TestRateLimitUseCase_Check_Global(t *testing.T) {
+	limits := rate_limiter.RateLimiterConfig{
+		Default: rate_limiter.Default{Every: 60, Requests: 10},
+		Global:  &rate_limiter.Global{Every: 60, Requests: 3},
+		Route: []rate_limiter.Route{
+			{Path: "/search", Every: 60, Requests: 1},
+		},
+	}
+
+	newUseCase := func(t *testing.T, opts ...usecases.Option) usecases.RateLimitUseCase {
+		clock := ratelimittest.NewClock(now)
+
+		useCase, err := usecases.New(append([]usecases.Option{
+			usecases.WithLimits(limits),
+			usecases.WithCache(strategies.NewRateLimitInMemoryClock(clock)),
+			usecases.WithClock(clock),
+		}, opts...)...)
+		assert.NoError(t, err)
+
+		return useCase
+	}
+
+	t.Run("Should check the global rules together with the rule of the key", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Remaining)
+		assert.Equal(t, "9", decision.Headers["Ratelimit-Remaining"])
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Global-Remaining"])
+		assert.NotContains(t, decision.Headers, "Ratelimit-Route-Remaining")
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.2", Route: "/search/users"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Route-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.3", Route: "/search"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, "10", decision.Headers["Ratelimit-Remaining"], "the rejected request must not count for the key")
+		assert.Equal(t, "1", decision.Headers["Ratelimit-Global-Remaining"], "the rejected request must not count for the global rule")
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.3", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.4", Route: "/"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+	})
+
+	t.Run("Should not check the global rules without a route", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		for i := 0; i < 5; i++ {
+			decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+			assert.NoError(t, err)
+			assert.True(t, decision.Allowed)
+			assert.NotContains(t, decision.Headers, "Ratelimit-Global-Remaining")
+		}
+	})
+
+	t.Run("Should match the route rules on path segments", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/searches"})
+		assert.NoError(t, err)
+		assert.NotContains(t, decision.Headers, "Ratelimit-Route-Remaining")
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/search/users"})
+		assert.NoError(t, err)
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Route-Remaining"])
+	})
+
+	t.Run("Should not share the global windows with a client of the same key", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		for _, key := range []string{"~global", "~route:/search", "global:*"} {
+			decision, err := useCase.Check(ctx, entities.CheckRequest{Key: key})
+			assert.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		}
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/search"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Global-Remaining"])
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Route-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "~global"})
+		assert.NoError(t, err)
+		assert.Equal(t, 8, decision.Remaining)
+	})
+
+	t.Run("Should count the requests checked against the global rules", func(t *testing.T) {
+		ctx := context.Background()
+		reader := sdkmetric.NewManualReader()
+		useCase := newUseCase(t, usecases.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+		for i := 0; i < 2; i++ {
+			_, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/search"})
+			assert.NoError(t, err)
+		}
+
+		var metrics metricdata.ResourceMetrics
+		assert.NoError(t, reader.Collect(ctx, &metrics))
+
+		values := map[string]int64{}
+
+		for _, scope := range metrics.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					rule, _ := point.Attributes.Value(attribute.Key("rate_limit.rule"))
+					outcome, _ := point.Attributes.Value(attribute.Key("rate_limit.outcome"))
+					values[rule.AsString()+":"+outcome.AsString()] = point.Value
+				}
+			}
+		}
+
+		assert.Equal(t, map[string]int64{
+			"global:allowed":  1,
+			"route_0:allowed": 1,
+			"route_0:limited": 1,
+		}, values)
+	})
+}
+
+type orgResolver struct {
+	orgs map[string]string
+	err  error
+}
+
+func (r *orgResolver) Org(ctx context.Context, key string) (string, error) {
+	return r.orgs[key], r.err
+}
+
+// failingCache fails to write the windows with a key starting with prefix
+type failingCache struct {
+	domain.RateLimitCache
+	prefix string
+}
+
+func (c *failingCache) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	if strings.HasPrefix(rate.Key, c.prefix) {
+		return errors.New("unavailable")
+	}
+
+	return c.RateLimitCache.Set(ctx, rate, every)
+}
+
+func TestRateLimitUseCase_Check_Hierarchy(t *testing.T) {
+	limits := rate_limiter.RateLimiterConfig{
+		Default: rate_limiter.Default{Every: 60, Requests: 10},
+		Token: []rate_limiter.Token{
+			{Token: "token_1", Every: 60, Requests: 3},
+			{Token: "token_2", Every: 60, Requests: 3},
+		},
+		Org: []rate_limiter.Org{
+			{Name: "acme", Every: 60, Requests: 4, Tokens: []string{"token_1", "token_2"}},
+		},
+		Endpoint: []rate_limiter.Endpoint{
+			{Path: "/export", Every: 60, Requests: 1},
+		},
+	}
+
+	newUseCase := func(t *testing.T, opts ...usecases.Option) usecases.RateLimitUseCase {
+		clock := ratelimittest.NewClock(now)
+
+		useCase, err := usecases.New(append([]usecases.Option{
+			usecases.WithLimits(limits),
+			usecases.WithCache(strategies.NewRateLimitInMemoryClock(clock)),
+			usecases.WithClock(clock),
+		}, opts...)...)
+		assert.NoError(t, err)
+
+		return useCase
+	}
+
+	t.Run("Should share the org quota between the tokens of the org", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		for i := 0; i < 3; i++ {
+			decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/"})
+			assert.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		}
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_2", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Org-Remaining"])
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_2", Route: "/"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Remaining"], "the rejected request must not count for the token")
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.NotContains(t, decision.Headers, "Ratelimit-Org-Remaining")
+	})
+
+	t.Run("Should not count any level when the endpoint limit is reached", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/export/csv"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Endpoint-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/export/csv"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Remaining"])
+		assert.Equal(t, "3", decision.Headers["Ratelimit-Org-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_2", Route: "/export"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed, "the endpoint windows are counted per key")
+	})
+
+	t.Run("Should roll back the levels written when a write fails", func(t *testing.T) {
+		ctx := context.Background()
+		clock := ratelimittest.NewClock(now)
+
+		db, err := sql.Open("sqlite", "file::memory:")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		require.NoError(t, strategies.MigrateSQL(ctx, db))
+
+		// the SQL store adds the requests counted since the window was read
+		cache := &failingCache{RateLimitCache: strategies.NewRateLimitSQLClient(db, clock), prefix: "~endpoint:"}
+		useCase := newUseCase(t, usecases.WithCache(cache))
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/export"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, "1", decision.Headers["Ratelimit-Remaining"])
+		assert.Equal(t, "2", decision.Headers["Ratelimit-Org-Remaining"])
+	})
+
+	t.Run("Should match the endpoint rules on path segments", func(t *testing.T) {
+		ctx := context.Background()
+		useCase := newUseCase(t)
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/exports"})
+		assert.NoError(t, err)
+		assert.NotContains(t, decision.Headers, "Ratelimit-Endpoint-Remaining")
+	})
+
+	t.Run("Should keep the org windows apart from the client keys", func(t *testing.T) {
+		ctx := context.Background()
+		reader := sdkmetric.NewManualReader()
+		useCase := newUseCase(t, usecases.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+		for _, key := range []string{"~org:acme", "org:acme"} {
+			decision, err := useCase.Check(ctx, entities.CheckRequest{Key: key, Route: "/"})
+			assert.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		}
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "token_1", Route: "/export"})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", decision.Headers["Ratelimit-Org-Remaining"])
+
+		var metrics metricdata.ResourceMetrics
+		assert.NoError(t, reader.Collect(ctx, &metrics))
+
+		for _, scope := range metrics.ScopeMetrics {
+			assert.Empty(t, scope.Metrics, "the org and endpoint levels are not global")
+		}
+	})
+
+	t.Run("Should resolve the orgs with the resolver informed", func(t *testing.T) {
+		ctx := context.Background()
+		resolver := &orgResolver{orgs: map[string]string{"10.0.0.1": "acme"}}
+		useCase := newUseCase(t, usecases.WithOrgResolver(resolver))
+
+		decision, err := useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", decision.Headers["Ratelimit-Org-Remaining"])
+
+		decision, err = useCase.Check(ctx, entities.CheckRequest{Key: "token_1"})
+		assert.NoError(t, err)
+		assert.NotContains(t, decision.Headers, "Ratelimit-Org-Remaining")
+
+		resolver.err = errors.New("directory unavailable")
+
+		_, err = useCase.Check(ctx, entities.CheckRequest{Key: "10.0.0.1"})
+		assert.ErrorIs(t, err, resolver.err)
+	})
+}
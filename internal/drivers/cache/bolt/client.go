@@ -0,0 +1,32 @@
+package bolt
+
+import (
+	"sync"
+	"time"
+
+	boltConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/bolt"
+	bolt "go.etcd.io/bbolt"
+)
+
+var once sync.Once
+var instance *bolt.DB
+var instanceErr error
+
+// GetClient returns the database shared by the process, configured by the
+// cfg of the first call. A bolt file can be opened by a single process at a
+// time
+func GetClient(cfg boltConfig.BoltConfig) (*bolt.DB, error) {
+	once.Do(func() {
+		instance, instanceErr = NewClient(cfg)
+	})
+
+	return instance, instanceErr
+}
+
+// NewClient opens the bolt file of cfg, creating it when it does not exist.
+// It fails when the file stays locked by another process for the timeout
+func NewClient(cfg boltConfig.BoltConfig) (*bolt.DB, error) {
+	return bolt.Open(cfg.Path, 0o600, &bolt.Options{
+		Timeout: time.Duration(cfg.Timeout) * time.Second,
+	})
+}
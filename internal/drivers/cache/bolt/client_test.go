@@ -0,0 +1,33 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	boltConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Run("Should create the bolt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ratelimit.bolt")
+
+		db, err := NewClient(boltConfig.BoltConfig{Path: path, Timeout: 1})
+
+		assert.NoError(t, err)
+		assert.FileExists(t, path)
+		assert.NoError(t, db.Close())
+	})
+
+	t.Run("Should return error when the file is locked by another client", func(t *testing.T) {
+		cfg := boltConfig.BoltConfig{Path: filepath.Join(t.TempDir(), "ratelimit.bolt"), Timeout: 1}
+
+		db, err := NewClient(cfg)
+		assert.NoError(t, err)
+		defer db.Close()
+
+		_, err = NewClient(cfg)
+
+		assert.Error(t, err)
+	})
+}
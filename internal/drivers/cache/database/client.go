@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	databaseConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/database"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// driverNames maps the configured drivers to the database/sql drivers
+var driverNames = map[string]string{
+	databaseConfig.DriverSQLite:   "sqlite",
+	databaseConfig.DriverPostgres: "pgx",
+}
+
+var once sync.Once
+var instance *sql.DB
+var instanceErr error
+
+// GetClient returns the database shared by the process, configured by the
+// cfg of the first call
+func GetClient(cfg databaseConfig.DatabaseConfig) (*sql.DB, error) {
+	once.Do(func() {
+		instance, instanceErr = NewClient(cfg)
+	})
+
+	return instance, instanceErr
+}
+
+// NewClient opens the database of cfg. SQLite has a single writer, so its
+// pool is limited to one connection unless configured otherwise
+func NewClient(cfg databaseConfig.DatabaseConfig) (*sql.DB, error) {
+	driver, ok := driverNames[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver: %q", cfg.Driver)
+	}
+
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpenConns := cfg.MaxOpenConns
+
+	if maxOpenConns == 0 && cfg.Driver == databaseConfig.DriverSQLite {
+		maxOpenConns = 1
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	return db, nil
+}
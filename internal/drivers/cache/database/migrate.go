@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migrate applies the schema versions not applied yet to the database, each
+// one in its own transaction, recording the applied versions in the table.
+// The migrations are applied in order and must never change once released,
+// new versions are appended. It can run concurrently in several instances
+// when every statement is idempotent
+func Migrate(ctx context.Context, db *sql.DB, table string, migrations []string) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version INTEGER NOT NULL PRIMARY KEY
+	)`, table))
+	if err != nil {
+		return err
+	}
+
+	var current int
+
+	err = db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s`, table)).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		if err := migrateVersion(ctx, db, table, version, migrations[version-1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrateVersion(ctx context.Context, db *sql.DB, table string, version int, migration string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (version) VALUES ($1)
+		ON CONFLICT (version) DO NOTHING`, table), version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should apply every schema version once", func(t *testing.T) {
+		db, err := sql.Open("sqlite", "file::memory:")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+
+		migrations := []string{
+			`CREATE TABLE test_items (id INTEGER NOT NULL PRIMARY KEY)`,
+			`CREATE INDEX test_items_id ON test_items (id)`,
+		}
+
+		assert.NoError(t, Migrate(ctx, db, "test_migrations", migrations[:1]))
+		assert.NoError(t, Migrate(ctx, db, "test_migrations", migrations))
+		assert.NoError(t, Migrate(ctx, db, "test_migrations", migrations))
+
+		var versions, max int
+		err = db.QueryRow(`SELECT COUNT(*), MAX(version) FROM test_migrations`).Scan(&versions, &max)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, versions)
+		assert.Equal(t, 2, max)
+	})
+
+	t.Run("Should not record the version when its migration fails", func(t *testing.T) {
+		db, err := sql.Open("sqlite", "file::memory:")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+
+		assert.Error(t, Migrate(ctx, db, "test_migrations", []string{`CREATE TABLE`}))
+
+		var versions int
+		assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM test_migrations`).Scan(&versions))
+		assert.Equal(t, 0, versions)
+	})
+}
@@ -0,0 +1,39 @@
+package memcached
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	memcachedConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/memcached"
+)
+
+var once sync.Once
+var instance *memcache.Client
+var instanceErr error
+
+// GetClient returns the client shared by the process, configured by the cfg
+// of the first call
+func GetClient(cfg memcachedConfig.MemcachedConfig) (*memcache.Client, error) {
+	once.Do(func() {
+		instance, instanceErr = NewClient(cfg)
+	})
+
+	return instance, instanceErr
+}
+
+// NewClient returns a new client of the servers of cfg, the keys are
+// distributed among them
+func NewClient(cfg memcachedConfig.MemcachedConfig) (*memcache.Client, error) {
+	servers := new(memcache.ServerList)
+
+	if err := servers.SetServers(cfg.Addrs...); err != nil {
+		return nil, err
+	}
+
+	client := memcache.NewFromSelector(servers)
+	client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	client.MaxIdleConns = cfg.MaxIdleConns
+
+	return client, nil
+}
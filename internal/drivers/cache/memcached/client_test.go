@@ -0,0 +1,40 @@
+package memcached
+
+import (
+	"testing"
+	"time"
+
+	memcachedConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/memcached"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClient(t *testing.T) {
+	t.Run("Should return the same instance of memcache.Client", func(t *testing.T) {
+		client1, err := GetClient(memcachedConfig.MemcachedConfig{Addrs: []string{"localhost:11211"}})
+		assert.NoError(t, err)
+		client2, err := GetClient(memcachedConfig.MemcachedConfig{Addrs: []string{"localhost:11212"}})
+		assert.NoError(t, err)
+
+		assert.Same(t, client1, client2)
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	t.Run("Should return a client configured by the config informed", func(t *testing.T) {
+		client, err := NewClient(memcachedConfig.MemcachedConfig{
+			Addrs:        []string{"localhost:11211", "localhost:11212"},
+			Timeout:      2,
+			MaxIdleConns: 10,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2*time.Second, client.Timeout)
+		assert.Equal(t, 10, client.MaxIdleConns)
+	})
+
+	t.Run("Should return error when an address is invalid", func(t *testing.T) {
+		_, err := NewClient(memcachedConfig.MemcachedConfig{Addrs: []string{"localhost:port"}})
+
+		assert.Error(t, err)
+	})
+}
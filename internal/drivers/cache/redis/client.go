@@ -1,34 +1,110 @@
 package redis
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	"sync"
 
-	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
 	"github.com/redis/go-redis/v9"
 )
 
 var once sync.Once
-var instance *redis.Client
+var instance redis.UniversalClient
+var instanceErr error
 
-func GetClient() *redis.Client {
+// GetClient returns the client shared by the process, configured by the cfg
+// of the first call
+func GetClient(cfg redisConfig.RedisConfig) (redis.UniversalClient, error) {
 	once.Do(func() {
-		instance = connectRedis()
+		instance, instanceErr = NewClient(cfg)
 	})
 
-	return instance
+	return instance, instanceErr
 }
 
-func connectRedis() *redis.Client {
-	cfg := config.GetConfig().Redis
+// NewClient returns a new client configured by cfg, a single node, cluster
+// or sentinel client depending on the mode
+func NewClient(cfg redisConfig.RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	client := redis.NewClient(
-		&redis.Options{
-			Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-			Password: cfg.Password,
-			DB:       cfg.DB,
-		})
+	options := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		DB:               cfg.DB,
+		MasterName:       cfg.MasterName,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		TLSConfig:        tlsConfig,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		DialTimeout:      time.Duration(cfg.DialTimeout) * time.Second,
+		ReadTimeout:      time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:     time.Duration(cfg.WriteTimeout) * time.Second,
+	}
 
-	return client
+	switch cfg.Mode {
+	case "", redisConfig.ModeSingle:
+		options.Addrs = []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)}
+
+		return redis.NewClient(options.Simple()), nil
+	case redisConfig.ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, errors.New("redis cluster mode requires the addresses of the nodes")
+		}
+
+		return redis.NewClusterClient(options.Cluster()), nil
+	case redisConfig.ModeSentinel:
+		if len(cfg.Addrs) == 0 || cfg.MasterName == "" {
+			return nil, errors.New("redis sentinel mode requires the addresses of the sentinels and the master name")
+		}
+
+		return redis.NewFailoverClient(options.Failover()), nil
+	default:
+		return nil, fmt.Errorf("unsupported redis mode: %s", cfg.Mode)
+	}
+}
+
+func newTLSConfig(cfg redisConfig.RedisConfig) (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.TLSServerName,
+	}
+
+	if cfg.TLSCAFile != "" {
+		ca, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis tls ca file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificate found in redis tls ca file")
+		}
+	}
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load redis tls client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
 }
@@ -1,24 +1,179 @@
 package redis
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetClient(t *testing.T) {
 	t.Run("Should return the same instance of redis.Client", func(t *testing.T) {
-		client1 := GetClient()
-		client2 := GetClient()
+		client1, err := GetClient(redisConfig.RedisConfig{Host: "localhost", Port: 6379})
+		assert.NoError(t, err)
+		client2, err := GetClient(redisConfig.RedisConfig{Host: "redis", Port: 6380})
+		assert.NoError(t, err)
 
 		assert.Equal(t, client1, client2)
+		assert.Equal(t, "localhost:6379", client2.(*redis.Client).Options().Addr)
 	})
 }
 
-func TestConnectRedis(t *testing.T) {
-	t.Run("Should return a valid redis.Client instance", func(t *testing.T) {
-		client := connectRedis()
+func TestNewClient(t *testing.T) {
+	t.Run("Should return a client configured by the config informed", func(t *testing.T) {
+		client, err := NewClient(redisConfig.RedisConfig{
+			Host:         "redis",
+			Port:         6380,
+			DB:           2,
+			Username:     "limiter",
+			PoolSize:     50,
+			MinIdleConns: 5,
+			DialTimeout:  3,
+			ReadTimeout:  1,
+			WriteTimeout: 2,
+		})
+		assert.NoError(t, err)
+		defer client.Close()
 
-		assert.NotNil(t, client)
+		options := client.(*redis.Client).Options()
+
+		assert.Equal(t, "redis:6380", options.Addr)
+		assert.Equal(t, 2, options.DB)
+		assert.Equal(t, "limiter", options.Username)
+		assert.Equal(t, 50, options.PoolSize)
+		assert.Equal(t, 5, options.MinIdleConns)
+		assert.Equal(t, 3*time.Second, options.DialTimeout)
+		assert.Equal(t, time.Second, options.ReadTimeout)
+		assert.Equal(t, 2*time.Second, options.WriteTimeout)
+		assert.Nil(t, options.TLSConfig)
+	})
+
+	t.Run("Should return a new client for each call", func(t *testing.T) {
+		client1, _ := NewClient(redisConfig.RedisConfig{Host: "localhost", Port: 6379})
+		client2, _ := NewClient(redisConfig.RedisConfig{Host: "localhost", Port: 6379})
+
+		assert.NotSame(t, client1, client2)
+	})
+
+	t.Run("Should return a cluster client in cluster mode", func(t *testing.T) {
+		client, err := NewClient(redisConfig.RedisConfig{
+			Mode:  redisConfig.ModeCluster,
+			Addrs: []string{"node-1:6379", "node-2:6379"},
+		})
+		assert.NoError(t, err)
+		defer client.Close()
+
+		cluster, ok := client.(*redis.ClusterClient)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"node-1:6379", "node-2:6379"}, cluster.Options().Addrs)
+	})
+
+	t.Run("Should return a failover client in sentinel mode", func(t *testing.T) {
+		client, err := NewClient(redisConfig.RedisConfig{
+			Mode:       redisConfig.ModeSentinel,
+			Addrs:      []string{"sentinel-1:26379"},
+			MasterName: "mymaster",
+		})
+		assert.NoError(t, err)
+		defer client.Close()
+
+		_, ok := client.(*redis.Client)
+		assert.True(t, ok)
+	})
+
+	t.Run("Should return error when the mode is misconfigured", func(t *testing.T) {
+		_, err := NewClient(redisConfig.RedisConfig{Mode: redisConfig.ModeCluster})
+		assert.Error(t, err)
+
+		_, err = NewClient(redisConfig.RedisConfig{Mode: redisConfig.ModeSentinel, Addrs: []string{"sentinel-1:26379"}})
+		assert.Error(t, err)
+
+		_, err = NewClient(redisConfig.RedisConfig{Mode: "replica"})
+		assert.ErrorContains(t, err, "unsupported redis mode")
+	})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	t.Run("Should not use TLS when it is disabled", func(t *testing.T) {
+		cfg, err := newTLSConfig(redisConfig.RedisConfig{TLSCAFile: certFile})
+
+		assert.NoError(t, err)
+		assert.Nil(t, cfg)
 	})
+
+	t.Run("Should use the CA, client certificate and server name informed", func(t *testing.T) {
+		cfg, err := newTLSConfig(redisConfig.RedisConfig{
+			TLS:           true,
+			TLSCAFile:     certFile,
+			TLSCertFile:   certFile,
+			TLSKeyFile:    keyFile,
+			TLSServerName: "redis.internal",
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, cfg.RootCAs)
+		assert.Len(t, cfg.Certificates, 1)
+		assert.Equal(t, "redis.internal", cfg.ServerName)
+	})
+
+	t.Run("Should pass the TLS config to the client", func(t *testing.T) {
+		client, err := NewClient(redisConfig.RedisConfig{Host: "redis", Port: 6379, TLS: true})
+		assert.NoError(t, err)
+		defer client.Close()
+
+		assert.NotNil(t, client.(*redis.Client).Options().TLSConfig)
+	})
+
+	t.Run("Should return error when the files are invalid", func(t *testing.T) {
+		_, err := newTLSConfig(redisConfig.RedisConfig{TLS: true, TLSCAFile: "missing.pem"})
+		assert.ErrorContains(t, err, "failed to read redis tls ca file")
+
+		_, err = newTLSConfig(redisConfig.RedisConfig{TLS: true, TLSCAFile: keyFile})
+		assert.ErrorContains(t, err, "no certificate found")
+
+		_, err = newTLSConfig(redisConfig.RedisConfig{TLS: true, TLSCertFile: certFile})
+		assert.ErrorContains(t, err, "failed to load redis tls client certificate")
+	})
+}
+
+func writeCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
 }
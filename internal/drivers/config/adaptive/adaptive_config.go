@@ -0,0 +1,50 @@
+package adaptive
+
+import "github.com/spf13/viper"
+
+// AdaptiveConfig holds the options of the adaptive limits. Every Interval
+// seconds the factor applied to the limits of the rules is decreased,
+// multiplied by Decrease, when a signal is over its threshold, or increased by
+// Increase otherwise, always between MinFactor and MaxFactor. A zero threshold
+// disables its signal: LatencyMs is the mean latency of the requests allowed,
+// ErrorRate the fraction of them answered with 5xx and CPULoad the load
+// average of the last minute per CPU
+type AdaptiveConfig struct {
+	Enabled   bool    `json:"enabled,omitempty" env:"ADAPTIVE_ENABLED"`
+	Interval  int     `json:"interval,omitempty" env:"ADAPTIVE_INTERVAL"`
+	MinFactor float64 `json:"min_factor,omitempty" env:"ADAPTIVE_MIN_FACTOR"`
+	MaxFactor float64 `json:"max_factor,omitempty" env:"ADAPTIVE_MAX_FACTOR"`
+	Increase  float64 `json:"increase,omitempty" env:"ADAPTIVE_INCREASE"`
+	Decrease  float64 `json:"decrease,omitempty" env:"ADAPTIVE_DECREASE"`
+	LatencyMs int     `json:"latency_ms,omitempty" env:"ADAPTIVE_LATENCY_MS"`
+	ErrorRate float64 `json:"error_rate,omitempty" env:"ADAPTIVE_ERROR_RATE"`
+	CPULoad   float64 `json:"cpu_load,omitempty" env:"ADAPTIVE_CPU_LOAD"`
+}
+
+// GetAdaptiveConfig returns the adaptive limits configuration
+func GetAdaptiveConfig() AdaptiveConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("ADAPTIVE_INTERVAL", 5)
+	viper.SetDefault("ADAPTIVE_MIN_FACTOR", 0.1)
+	viper.SetDefault("ADAPTIVE_MAX_FACTOR", 1.0)
+	viper.SetDefault("ADAPTIVE_INCREASE", 0.05)
+	viper.SetDefault("ADAPTIVE_DECREASE", 0.5)
+
+	// get config
+	return AdaptiveConfig{
+		Enabled:   viper.GetBool("ADAPTIVE_ENABLED"),
+		Interval:  viper.GetInt("ADAPTIVE_INTERVAL"),
+		MinFactor: viper.GetFloat64("ADAPTIVE_MIN_FACTOR"),
+		MaxFactor: viper.GetFloat64("ADAPTIVE_MAX_FACTOR"),
+		Increase:  viper.GetFloat64("ADAPTIVE_INCREASE"),
+		Decrease:  viper.GetFloat64("ADAPTIVE_DECREASE"),
+		LatencyMs: viper.GetInt("ADAPTIVE_LATENCY_MS"),
+		ErrorRate: viper.GetFloat64("ADAPTIVE_ERROR_RATE"),
+		CPULoad:   viper.GetFloat64("ADAPTIVE_CPU_LOAD"),
+	}
+}
@@ -0,0 +1,51 @@
+package adaptive
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAdaptiveConfig(t *testing.T) {
+	t.Run("Should return the adaptive config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetAdaptiveConfig()
+
+		assert.Equal(t, AdaptiveConfig{
+			Interval:  5,
+			MinFactor: 0.1,
+			MaxFactor: 1,
+			Increase:  0.05,
+			Decrease:  0.5,
+		}, result)
+	})
+
+	t.Run("Should return the adaptive config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("ADAPTIVE_ENABLED", true)
+		viper.Set("ADAPTIVE_INTERVAL", 10)
+		viper.Set("ADAPTIVE_MIN_FACTOR", 0.25)
+		viper.Set("ADAPTIVE_MAX_FACTOR", 2)
+		viper.Set("ADAPTIVE_INCREASE", 0.1)
+		viper.Set("ADAPTIVE_DECREASE", 0.7)
+		viper.Set("ADAPTIVE_LATENCY_MS", 250)
+		viper.Set("ADAPTIVE_ERROR_RATE", 0.05)
+		viper.Set("ADAPTIVE_CPU_LOAD", 0.9)
+
+		result := GetAdaptiveConfig()
+
+		assert.Equal(t, AdaptiveConfig{
+			Enabled:   true,
+			Interval:  10,
+			MinFactor: 0.25,
+			MaxFactor: 2,
+			Increase:  0.1,
+			Decrease:  0.7,
+			LatencyMs: 250,
+			ErrorRate: 0.05,
+			CPULoad:   0.9,
+		}, result)
+	})
+}
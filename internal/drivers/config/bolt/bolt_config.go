@@ -0,0 +1,28 @@
+package bolt
+
+import "github.com/spf13/viper"
+
+// BoltConfig holds the file of the embedded cache, the timeout is how long
+// (in seconds) to wait for the lock of a file opened by another process
+type BoltConfig struct {
+	Path    string `json:"path,omitempty" env:"BOLT_PATH"`
+	Timeout int    `json:"timeout,omitempty" env:"BOLT_TIMEOUT"`
+}
+
+// GetBoltConfig returns the embedded cache configuration
+func GetBoltConfig() BoltConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("BOLT_PATH", "ratelimit.bolt")
+	viper.SetDefault("BOLT_TIMEOUT", 1)
+
+	// get config
+	return BoltConfig{
+		Path:    viper.GetString("BOLT_PATH"),
+		Timeout: viper.GetInt("BOLT_TIMEOUT"),
+	}
+}
@@ -0,0 +1,38 @@
+package bolt
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBoltConfig(t *testing.T) {
+	t.Run("Should return the bolt config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		expected := BoltConfig{
+			Path:    "ratelimit.bolt",
+			Timeout: 1,
+		}
+
+		result := GetBoltConfig()
+
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return the bolt config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("BOLT_PATH", "/var/lib/ratelimit/ratelimit.bolt")
+		viper.Set("BOLT_TIMEOUT", 5)
+
+		expected := BoltConfig{
+			Path:    "/var/lib/ratelimit/ratelimit.bolt",
+			Timeout: 5,
+		}
+
+		result := GetBoltConfig()
+
+		assert.Equal(t, expected, result)
+	})
+}
@@ -2,16 +2,43 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/adaptive"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/bolt"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/database"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/delay"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/events"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/hybrid"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/logger"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/memcached"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/proxy"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/server"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/shedding"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/usage"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Cache       string                         `json:"cache"`
-	Redis       redis.RedisConfig              `json:"redis"`
-	RateLimiter rate_limiter.RateLimiterConfig `json:"rate_limiter"`
+	Cache        string                         `json:"cache"`
+	CacheCleanup int                            `json:"cache_cleanup,omitempty"`
+	Redis        redis.RedisConfig              `json:"redis"`
+	Memcached    memcached.MemcachedConfig      `json:"memcached"`
+	Database     database.DatabaseConfig        `json:"database"`
+	Bolt         bolt.BoltConfig                `json:"bolt"`
+	Hybrid       hybrid.HybridConfig            `json:"hybrid"`
+	RateLimiter  rate_limiter.RateLimiterConfig `json:"rate_limiter"`
+	Logger       logger.LoggerConfig            `json:"logger"`
+	Server       server.ServerConfig            `json:"server"`
+	Proxy        proxy.ProxyConfig              `json:"proxy"`
+	Usage        usage.UsageConfig              `json:"usage"`
+	Events       events.EventsConfig            `json:"events"`
+	Adaptive     adaptive.AdaptiveConfig        `json:"adaptive"`
+	Shedding     shedding.SheddingConfig        `json:"shedding"`
+	Delay        delay.DelayConfig              `json:"delay"`
 }
 
 func GetConfig() Config {
@@ -22,11 +49,25 @@ func GetConfig() Config {
 
 	// set default value
 	viper.SetDefault("CACHE", "inmemory")
+	viper.SetDefault("CACHE_CLEANUP_INTERVAL", 60)
 
 	return Config{
-		Cache:       viper.GetString("CACHE"),
-		Redis:       redis.GetRedisConfig(),
-		RateLimiter: rate_limiter.GetRateLimiterConfig(),
+		Cache:        viper.GetString("CACHE"),
+		CacheCleanup: viper.GetInt("CACHE_CLEANUP_INTERVAL"),
+		Redis:        redis.GetRedisConfig(),
+		Memcached:    memcached.GetMemcachedConfig(),
+		Database:     database.GetDatabaseConfig(),
+		Bolt:         bolt.GetBoltConfig(),
+		Hybrid:       hybrid.GetHybridConfig(),
+		RateLimiter:  rate_limiter.GetRateLimiterConfig(),
+		Logger:       logger.GetLoggerConfig(),
+		Server:       server.GetServerConfig(),
+		Proxy:        proxy.GetProxyConfig(),
+		Usage:        usage.GetUsageConfig(),
+		Events:       events.GetEventsConfig(),
+		Adaptive:     adaptive.GetAdaptiveConfig(),
+		Shedding:     shedding.GetSheddingConfig(),
+		Delay:        delay.GetDelayConfig(),
 	}
 }
 
@@ -35,3 +76,174 @@ func (r Config) String() string {
 
 	return string(data)
 }
+
+// LogValue returns the config as log attributes, the redis password, the
+// database DSN and the webhook URLs and secret are never logged and IPs and
+// tokens use keys masked by the logger redaction
+func (r Config) LogValue() slog.Value {
+	rules := []slog.Attr{
+		slog.Int("default_requests", r.RateLimiter.Default.Requests),
+		slog.Int("default_every", r.RateLimiter.Default.Every),
+		slog.Bool("default_dry_run", r.RateLimiter.Default.DryRun),
+		slog.Bool("dry_run_header", r.RateLimiter.DryRunHeader),
+	}
+
+	for i, ip := range r.RateLimiter.IP {
+		rules = append(rules, slog.Group(fmt.Sprintf("ip_%d", i),
+			slog.String("ip", ip.IP),
+			slog.Int("requests", ip.Requests),
+			slog.Int("every", ip.Every),
+			slog.Bool("dry_run", ip.DryRun),
+		))
+	}
+
+	for i, token := range r.RateLimiter.Token {
+		rules = append(rules, slog.Group(fmt.Sprintf("token_%d", i),
+			slog.String("token", token.Token),
+			slog.Int("requests", token.Requests),
+			slog.Int("every", token.Every),
+			slog.Bool("dry_run", token.DryRun),
+		))
+	}
+
+	for i, policy := range r.RateLimiter.Policy {
+		rules = append(rules, slog.Group(fmt.Sprintf("policy_%d", i),
+			slog.String("name", policy.Name),
+			slog.Int("requests", policy.Requests),
+			slog.Int("every", policy.Every),
+			slog.Bool("dry_run", policy.DryRun),
+		))
+	}
+
+	if global := r.RateLimiter.Global; global != nil {
+		rules = append(rules, slog.Group("global",
+			slog.Int("requests", global.Requests),
+			slog.Int("every", global.Every),
+			slog.Bool("dry_run", global.DryRun),
+		))
+	}
+
+	for i, route := range r.RateLimiter.Route {
+		rules = append(rules, slog.Group(fmt.Sprintf("route_%d", i),
+			slog.String("path", route.Path),
+			slog.Int("requests", route.Requests),
+			slog.Int("every", route.Every),
+			slog.Bool("dry_run", route.DryRun),
+		))
+	}
+
+	for i, org := range r.RateLimiter.Org {
+		rules = append(rules, slog.Group(fmt.Sprintf("org_%d", i),
+			slog.String("name", org.Name),
+			slog.Int("requests", org.Requests),
+			slog.Int("every", org.Every),
+			slog.Bool("dry_run", org.DryRun),
+			slog.Int("tokens", len(org.Tokens)),
+		))
+	}
+
+	for i, endpoint := range r.RateLimiter.Endpoint {
+		rules = append(rules, slog.Group(fmt.Sprintf("endpoint_%d", i),
+			slog.String("path", endpoint.Path),
+			slog.Int("requests", endpoint.Requests),
+			slog.Int("every", endpoint.Every),
+			slog.Bool("dry_run", endpoint.DryRun),
+		))
+	}
+
+	upstreams := []slog.Attr{}
+
+	for i, upstream := range r.Proxy.Upstream {
+		upstreams = append(upstreams, slog.Group(fmt.Sprintf("upstream_%d", i),
+			slog.String("url", upstream.URL),
+			slog.String("path", upstream.Path),
+			slog.String("policy", upstream.Policy),
+		))
+	}
+
+	classes := []slog.Attr{
+		slog.Bool("enabled", r.Shedding.Enabled),
+		slog.Int("capacity", r.Shedding.Capacity),
+		slog.String("default_class", r.Shedding.DefaultClass),
+	}
+
+	for i, class := range r.Shedding.Class {
+		classes = append(classes, slog.Group(fmt.Sprintf("class_%d", i),
+			slog.String("name", class.Name),
+			slog.Int("share", class.Share),
+			slog.Any("routes", class.Routes),
+		))
+	}
+
+	return slog.GroupValue(
+		slog.String("cache", r.Cache),
+		slog.Int("cache_cleanup", r.CacheCleanup),
+		slog.Group("redis",
+			slog.String("mode", r.Redis.Mode),
+			slog.String("host", r.Redis.Host),
+			slog.Int("port", r.Redis.Port),
+			slog.Any("addrs", r.Redis.Addrs),
+			slog.Int("db", r.Redis.DB),
+			slog.Bool("tls", r.Redis.TLS),
+			slog.Int("pool_size", r.Redis.PoolSize),
+			slog.String("key_prefix", r.Redis.KeyPrefix),
+			slog.String("namespace", r.Redis.Namespace),
+			slog.Bool("key_hash", r.Redis.KeyHash),
+			slog.Bool("breaker", r.Redis.Breaker),
+		),
+		slog.Group("memcached",
+			slog.Any("addrs", r.Memcached.Addrs),
+			slog.String("key_prefix", r.Memcached.KeyPrefix),
+		),
+		slog.Group("database",
+			slog.String("driver", r.Database.Driver),
+			slog.Bool("migrate", r.Database.Migrate),
+			slog.Int("max_open_conns", r.Database.MaxOpenConns),
+		),
+		slog.Group("bolt",
+			slog.String("path", r.Bolt.Path),
+		),
+		slog.Group("hybrid",
+			slog.Int("batch_size", r.Hybrid.BatchSize),
+		),
+		slog.Attr{Key: "rate_limiter", Value: slog.GroupValue(rules...)},
+		slog.Group("logger",
+			slog.String("level", r.Logger.Level),
+			slog.String("format", r.Logger.Format),
+			slog.Bool("redact", r.Logger.Redact),
+		),
+		slog.Group("server",
+			slog.String("addr", r.Server.Addr),
+			slog.String("grpc_addr", r.Server.GRPCAddr),
+			slog.Bool("tls", r.Server.TLSCertFile != ""),
+			slog.Int("read_timeout", r.Server.ReadTimeout),
+			slog.Int("write_timeout", r.Server.WriteTimeout),
+			slog.Int("shutdown_timeout", r.Server.ShutdownTimeout),
+		),
+		slog.Attr{Key: "proxy", Value: slog.GroupValue(upstreams...)},
+		slog.Group("usage",
+			slog.String("sink", r.Usage.Sink),
+			slog.Int("flush_interval", r.Usage.FlushInterval),
+			slog.Int("retries", r.Usage.Retries),
+		),
+		slog.Group("events",
+			slog.Any("sinks", r.Events.Sinks),
+			slog.Int("nearly_exhausted_percent", r.Events.NearlyExhaustedPercent),
+			slog.Int("buffer_size", r.Events.BufferSize),
+		),
+		slog.Group("adaptive",
+			slog.Bool("enabled", r.Adaptive.Enabled),
+			slog.Float64("min_factor", r.Adaptive.MinFactor),
+			slog.Float64("max_factor", r.Adaptive.MaxFactor),
+			slog.Int("latency_ms", r.Adaptive.LatencyMs),
+			slog.Float64("error_rate", r.Adaptive.ErrorRate),
+			slog.Float64("cpu_load", r.Adaptive.CPULoad),
+		),
+		slog.Attr{Key: "shedding", Value: slog.GroupValue(classes...)},
+		slog.Group("delay",
+			slog.Int("max_wait_ms", r.Delay.MaxWait),
+			slog.Int("queue_size", r.Delay.QueueSize),
+			slog.Int("keys", len(r.Delay.Keys)),
+		),
+	)
+}
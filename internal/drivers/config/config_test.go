@@ -1,6 +1,8 @@
 package config
 
 import (
+	"bytes"
+	"log/slog"
 	"os"
 	"testing"
 
@@ -15,6 +17,8 @@ func TestGetConfig(t *testing.T) {
 		config := GetConfig()
 
 		assert.Equal(t, "inmemory", config.Cache)
+		assert.Equal(t, 60, config.CacheCleanup)
+		assert.Equal(t, ":8080", config.Server.Addr)
 		assert.NotNil(t, config.Redis)
 		assert.NotNil(t, config.RateLimiter)
 	})
@@ -62,8 +66,43 @@ func TestConfig_String(t *testing.T) {
 			},
 		}
 
-		expectedJSON := `{"cache":"inmemory","redis":{"host":"localhost","port":6379},"rate_limiter":{"default":{"requests":10,"every":60}}}`
+		expectedJSON := `{"cache":"inmemory","redis":{"host":"localhost","port":6379},"memcached":{},"database":{},"bolt":{},"hybrid":{},"rate_limiter":{"default":{"requests":10,"every":60}},"logger":{},"server":{},"proxy":{},"usage":{},"events":{},"adaptive":{},"shedding":{},"delay":{}}`
 
 		assert.Equal(t, expectedJSON, config.String())
 	})
 }
+
+func TestConfig_LogValue(t *testing.T) {
+	t.Run("Should log the config without the redis password", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		config := Config{
+			Cache: "redis",
+			Redis: redis.RedisConfig{
+				Host:     "localhost",
+				Port:     6379,
+				Password: "secret",
+			},
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{
+					Requests: 10,
+					Every:    60,
+				},
+				IP: []rate_limiter.IP{
+					{IP: "127.0.0.1", Requests: 5, Every: 30},
+				},
+				Token: []rate_limiter.Token{
+					{Token: "token_1", Requests: 20, Every: 60},
+				},
+			},
+		}
+
+		slog.New(slog.NewTextHandler(&buf, nil)).Info("config", "config", config)
+
+		assert.NotContains(t, buf.String(), "secret")
+		assert.Contains(t, buf.String(), "config.cache=redis")
+		assert.Contains(t, buf.String(), "config.redis.host=localhost config.redis.port=6379")
+		assert.Contains(t, buf.String(), "config.rate_limiter.ip_0.ip=127.0.0.1 config.rate_limiter.ip_0.requests=5")
+		assert.Contains(t, buf.String(), "config.rate_limiter.token_0.token=token_1 config.rate_limiter.token_0.requests=20")
+	})
+}
@@ -0,0 +1,40 @@
+package database
+
+import "github.com/spf13/viper"
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DatabaseConfig holds the connection of the SQL cache, the DSN is never
+// logged since it may have the database password
+type DatabaseConfig struct {
+	Driver       string `json:"driver,omitempty" env:"DATABASE_DRIVER"`
+	DSN          string `json:"-" env:"DATABASE_DSN"`
+	Migrate      bool   `json:"migrate,omitempty" env:"DATABASE_MIGRATE"`
+	MaxOpenConns int    `json:"max_open_conns,omitempty" env:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns int    `json:"max_idle_conns,omitempty" env:"DATABASE_MAX_IDLE_CONNS"`
+}
+
+// GetDatabaseConfig returns the database configuration
+func GetDatabaseConfig() DatabaseConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("DATABASE_DRIVER", DriverSQLite)
+	viper.SetDefault("DATABASE_DSN", "file:ratelimit.db?_pragma=busy_timeout(5000)")
+	viper.SetDefault("DATABASE_MIGRATE", true)
+
+	// get config
+	return DatabaseConfig{
+		Driver:       viper.GetString("DATABASE_DRIVER"),
+		DSN:          viper.GetString("DATABASE_DSN"),
+		Migrate:      viper.GetBool("DATABASE_MIGRATE"),
+		MaxOpenConns: viper.GetInt("DATABASE_MAX_OPEN_CONNS"),
+		MaxIdleConns: viper.GetInt("DATABASE_MAX_IDLE_CONNS"),
+	}
+}
@@ -0,0 +1,34 @@
+package delay
+
+import (
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
+	"github.com/spf13/viper"
+)
+
+// DelayConfig holds the delay mode of the middleware. The requests of Keys,
+// tokens or IPs, over their limit wait for the window to reset instead of
+// being rejected, while the wait is at most MaxWait milliseconds and fewer
+// than QueueSize requests of the key are waiting
+type DelayConfig struct {
+	MaxWait   int      `json:"max_wait,omitempty" env:"DELAY_MAX_WAIT_MS"`
+	QueueSize int      `json:"queue_size,omitempty" env:"DELAY_QUEUE_SIZE"`
+	Keys      []string `json:"keys,omitempty" env:"DELAY_KEYS"`
+}
+
+// GetDelayConfig returns the delay mode configuration
+func GetDelayConfig() DelayConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("DELAY_MAX_WAIT_MS", 5000)
+	viper.SetDefault("DELAY_QUEUE_SIZE", 10)
+
+	return DelayConfig{
+		MaxWait:   viper.GetInt("DELAY_MAX_WAIT_MS"),
+		QueueSize: viper.GetInt("DELAY_QUEUE_SIZE"),
+		Keys:      env.List(viper.GetString("DELAY_KEYS")),
+	}
+}
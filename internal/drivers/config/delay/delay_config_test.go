@@ -0,0 +1,36 @@
+package delay
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDelayConfig(t *testing.T) {
+	t.Run("Should return the delay config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetDelayConfig()
+
+		assert.Equal(t, DelayConfig{
+			MaxWait:   5000,
+			QueueSize: 10,
+		}, result)
+	})
+
+	t.Run("Should return the delay config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("DELAY_MAX_WAIT_MS", 30000)
+		viper.Set("DELAY_QUEUE_SIZE", 50)
+		viper.Set("DELAY_KEYS", "batch_token, 10.0.0.1")
+
+		result := GetDelayConfig()
+
+		assert.Equal(t, DelayConfig{
+			MaxWait:   30000,
+			QueueSize: 50,
+			Keys:      []string{"batch_token", "10.0.0.1"},
+		}, result)
+	})
+}
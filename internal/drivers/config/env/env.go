@@ -0,0 +1,17 @@
+package env
+
+import "strings"
+
+// List splits a comma separated list of values, the values are trimmed and
+// the empty ones are skipped
+func List(value string) []string {
+	var values []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,18 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	t.Run("Should split the values trimming them and skipping the empty ones", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "b", "c"}, List(" a,b ,, c,"))
+	})
+
+	t.Run("Should return nil when the list is empty", func(t *testing.T) {
+		assert.Nil(t, List(""))
+		assert.Nil(t, List(" , "))
+	})
+}
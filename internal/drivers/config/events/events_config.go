@@ -0,0 +1,45 @@
+package events
+
+import (
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
+	"github.com/spf13/viper"
+)
+
+// Sinks of the rate limit events
+const (
+	SinkLog     = "log"
+	SinkWebhook = "webhook"
+)
+
+// EventsConfig holds the options of the rate limit events, delivered to the
+// Sinks in background through a queue of BufferSize events. The webhook
+// requests are signed with HMAC-SHA256 of WebhookSecret when it is set. The
+// events are disabled when Sinks is empty
+type EventsConfig struct {
+	Sinks                  []string `json:"sinks,omitempty" env:"EVENTS_SINKS"`
+	WebhookURL             string   `json:"-" env:"EVENTS_WEBHOOK_URL"`
+	WebhookSecret          string   `json:"-" env:"EVENTS_WEBHOOK_SECRET"`
+	NearlyExhaustedPercent int      `json:"nearly_exhausted_percent,omitempty" env:"EVENTS_NEARLY_EXHAUSTED_PERCENT"`
+	BufferSize             int      `json:"buffer_size,omitempty" env:"EVENTS_BUFFER_SIZE"`
+}
+
+// GetEventsConfig returns the rate limit events configuration
+func GetEventsConfig() EventsConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("EVENTS_NEARLY_EXHAUSTED_PERCENT", 80)
+	viper.SetDefault("EVENTS_BUFFER_SIZE", 1000)
+
+	// get config
+	return EventsConfig{
+		Sinks:                  env.List(viper.GetString("EVENTS_SINKS")),
+		WebhookURL:             viper.GetString("EVENTS_WEBHOOK_URL"),
+		WebhookSecret:          viper.GetString("EVENTS_WEBHOOK_SECRET"),
+		NearlyExhaustedPercent: viper.GetInt("EVENTS_NEARLY_EXHAUSTED_PERCENT"),
+		BufferSize:             viper.GetInt("EVENTS_BUFFER_SIZE"),
+	}
+}
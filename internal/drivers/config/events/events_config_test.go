@@ -0,0 +1,37 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEventsConfig(t *testing.T) {
+	t.Run("Should return the events config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetEventsConfig()
+
+		assert.Equal(t, EventsConfig{NearlyExhaustedPercent: 80, BufferSize: 1000}, result)
+	})
+
+	t.Run("Should return the events config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("EVENTS_SINKS", "log, webhook")
+		viper.Set("EVENTS_WEBHOOK_URL", "https://hooks.example.com/ratelimit")
+		viper.Set("EVENTS_WEBHOOK_SECRET", "s3cr3t")
+		viper.Set("EVENTS_NEARLY_EXHAUSTED_PERCENT", 90)
+		viper.Set("EVENTS_BUFFER_SIZE", 10)
+
+		result := GetEventsConfig()
+
+		assert.Equal(t, EventsConfig{
+			Sinks:                  []string{SinkLog, SinkWebhook},
+			WebhookURL:             "https://hooks.example.com/ratelimit",
+			WebhookSecret:          "s3cr3t",
+			NearlyExhaustedPercent: 90,
+			BufferSize:             10,
+		}, result)
+	})
+}
@@ -0,0 +1,25 @@
+package hybrid
+
+import "github.com/spf13/viper"
+
+// HybridConfig holds the options of the two-tier cache, the batch size is
+// how many requests every instance reserves from redis at a time
+type HybridConfig struct {
+	BatchSize int `json:"batch_size,omitempty" env:"HYBRID_BATCH_SIZE"`
+}
+
+// GetHybridConfig returns the two-tier cache configuration
+func GetHybridConfig() HybridConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("HYBRID_BATCH_SIZE", 10)
+
+	// get config
+	return HybridConfig{
+		BatchSize: viper.GetInt("HYBRID_BATCH_SIZE"),
+	}
+}
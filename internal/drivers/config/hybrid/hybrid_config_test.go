@@ -0,0 +1,27 @@
+package hybrid
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHybridConfig(t *testing.T) {
+	t.Run("Should return the hybrid config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetHybridConfig()
+
+		assert.Equal(t, HybridConfig{BatchSize: 10}, result)
+	})
+
+	t.Run("Should return the hybrid config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("HYBRID_BATCH_SIZE", 50)
+
+		result := GetHybridConfig()
+
+		assert.Equal(t, HybridConfig{BatchSize: 50}, result)
+	})
+}
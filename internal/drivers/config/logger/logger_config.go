@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"github.com/spf13/viper"
+)
+
+type LoggerConfig struct {
+	Level  string `json:"level,omitempty" env:"LOG_LEVEL"`
+	Format string `json:"format,omitempty" env:"LOG_FORMAT"`
+	Redact bool   `json:"redact,omitempty" env:"LOG_REDACT"`
+}
+
+// GetLoggerConfig returns the logger configuration
+func GetLoggerConfig() LoggerConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "text")
+	viper.SetDefault("LOG_REDACT", false)
+
+	// get config
+	loggerConfig := LoggerConfig{
+		Level:  viper.GetString("LOG_LEVEL"),
+		Format: viper.GetString("LOG_FORMAT"),
+		Redact: viper.GetBool("LOG_REDACT"),
+	}
+
+	return loggerConfig
+}
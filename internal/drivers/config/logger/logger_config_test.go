@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLoggerConfig(t *testing.T) {
+	t.Run("Should return the logger config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		expected := LoggerConfig{
+			Level:  "info",
+			Format: "text",
+			Redact: false,
+		}
+
+		result := GetLoggerConfig()
+
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return the logger config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("LOG_LEVEL", "debug")
+		viper.Set("LOG_FORMAT", "json")
+		viper.Set("LOG_REDACT", true)
+
+		expected := LoggerConfig{
+			Level:  "debug",
+			Format: "json",
+			Redact: true,
+		}
+
+		result := GetLoggerConfig()
+
+		assert.Equal(t, expected, result)
+	})
+}
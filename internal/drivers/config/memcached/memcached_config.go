@@ -0,0 +1,35 @@
+package memcached
+
+import (
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
+	"github.com/spf13/viper"
+)
+
+// MemcachedConfig holds the memcached connection options, the timeout is in
+// seconds and zero values keep the defaults of the memcached client
+type MemcachedConfig struct {
+	Addrs        []string `json:"addrs,omitempty" env:"MEMCACHED_ADDRS"`
+	KeyPrefix    string   `json:"key_prefix,omitempty" env:"MEMCACHED_KEY_PREFIX"`
+	Timeout      int      `json:"timeout,omitempty" env:"MEMCACHED_TIMEOUT"`
+	MaxIdleConns int      `json:"max_idle_conns,omitempty" env:"MEMCACHED_MAX_IDLE_CONNS"`
+}
+
+// GetMemcachedConfig returns the memcached configuration
+func GetMemcachedConfig() MemcachedConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("MEMCACHED_ADDRS", "localhost:11211")
+	viper.SetDefault("MEMCACHED_KEY_PREFIX", "ratelimit")
+
+	// get config
+	return MemcachedConfig{
+		Addrs:        env.List(viper.GetString("MEMCACHED_ADDRS")),
+		KeyPrefix:    viper.GetString("MEMCACHED_KEY_PREFIX"),
+		Timeout:      viper.GetInt("MEMCACHED_TIMEOUT"),
+		MaxIdleConns: viper.GetInt("MEMCACHED_MAX_IDLE_CONNS"),
+	}
+}
@@ -0,0 +1,42 @@
+package memcached
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMemcachedConfig(t *testing.T) {
+	t.Run("Should return the memcached config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		expected := MemcachedConfig{
+			Addrs:     []string{"localhost:11211"},
+			KeyPrefix: "ratelimit",
+		}
+
+		result := GetMemcachedConfig()
+
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return the memcached config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("MEMCACHED_ADDRS", "memcached-1:11211, memcached-2:11211")
+		viper.Set("MEMCACHED_KEY_PREFIX", "rl")
+		viper.Set("MEMCACHED_TIMEOUT", 2)
+		viper.Set("MEMCACHED_MAX_IDLE_CONNS", 10)
+
+		expected := MemcachedConfig{
+			Addrs:        []string{"memcached-1:11211", "memcached-2:11211"},
+			KeyPrefix:    "rl",
+			Timeout:      2,
+			MaxIdleConns: 10,
+		}
+
+		result := GetMemcachedConfig()
+
+		assert.Equal(t, expected, result)
+	})
+}
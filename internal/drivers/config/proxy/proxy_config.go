@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// ProxyConfig holds the upstreams of the reverse proxy
+type ProxyConfig struct {
+	Upstream []Upstream `json:"upstream,omitempty"`
+}
+
+// Upstream is proxied for the requests under Path, with the rule of the named
+// rate limit Policy or, without one, the IP and token rules
+type Upstream struct {
+	URL    string `json:"url,omitempty" env:"PROXY_UPSTREAM_N"`
+	Path   string `json:"path,omitempty" env:"PROXY_UPSTREAM_N_PATH"`
+	Policy string `json:"policy,omitempty" env:"PROXY_UPSTREAM_N_POLICY"`
+}
+
+// GetProxyConfig returns the reverse proxy configuration
+func GetProxyConfig() ProxyConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	proxyConfig := ProxyConfig{}
+
+	for i := 0; ; i++ {
+		upstreamKey := fmt.Sprintf("PROXY_UPSTREAM_%d", i)
+
+		if !viper.IsSet(upstreamKey) {
+			break
+		}
+
+		pathKey := fmt.Sprintf("PROXY_UPSTREAM_%d_PATH", i)
+
+		// set default value
+		viper.SetDefault(pathKey, "/")
+
+		proxyConfig.Upstream = append(proxyConfig.Upstream, Upstream{
+			URL:    viper.GetString(upstreamKey),
+			Path:   viper.GetString(pathKey),
+			Policy: viper.GetString(fmt.Sprintf("PROXY_UPSTREAM_%d_POLICY", i)),
+		})
+	}
+
+	return proxyConfig
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProxyConfig(t *testing.T) {
+	t.Run("Should return no upstreams when none is configured", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetProxyConfig()
+
+		assert.Empty(t, result.Upstream)
+	})
+
+	t.Run("Should return the upstreams with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("PROXY_UPSTREAM_0", "http://api:8080")
+		viper.Set("PROXY_UPSTREAM_1", "http://search:8080")
+		viper.Set("PROXY_UPSTREAM_1_PATH", "/search/")
+		viper.Set("PROXY_UPSTREAM_1_POLICY", "search")
+
+		expected := ProxyConfig{
+			Upstream: []Upstream{
+				{URL: "http://api:8080", Path: "/"},
+				{URL: "http://search:8080", Path: "/search/", Policy: "search"},
+			},
+		}
+
+		result := GetProxyConfig()
+
+		assert.Equal(t, expected, result)
+	})
+}
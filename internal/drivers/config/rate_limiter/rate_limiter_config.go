@@ -2,31 +2,171 @@ package rate_limiter
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
 	"github.com/spf13/viper"
 )
 
+// Calendar periods of the quota rules, their windows reset at the start of the
+// next day, week (on monday) or month in the time zone of the rule
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// RateLimiterConfig holds the default, IP, token and named policy rules. Rules with
+// DryRun count requests and report the ones that would be limited, but never reject them.
+// Rules with a Period are calendar quotas and ignore Every. The Global and Route rules
+// are shared by all the clients and checked together with the rule of the key, as are
+// the Org rule of the organization of the key and the Endpoint rule of the key. The
+// keys rejected too often by their own rule are banned when Ban is set
 type RateLimiterConfig struct {
-	Default Default `json:"default"`
-	IP      []IP    `json:"ip,omitempty"`
-	Token   []Token `json:"token,omitempty"`
+	Default      Default    `json:"default"`
+	IP           []IP       `json:"ip,omitempty"`
+	Token        []Token    `json:"token,omitempty"`
+	Policy       []Policy   `json:"policy,omitempty"`
+	Global       *Global    `json:"global,omitempty"`
+	Route        []Route    `json:"route,omitempty"`
+	Org          []Org      `json:"org,omitempty"`
+	Endpoint     []Endpoint `json:"endpoint,omitempty"`
+	Ban          *Ban       `json:"ban,omitempty"`
+	DryRunHeader bool       `json:"dry_run_header,omitempty"`
 }
 
 type Default struct {
-	Requests int `json:"requests,omitempty"`
-	Every    int `json:"every,omitempty"`
+	Requests int    `json:"requests,omitempty"`
+	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Period   string `json:"period,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
 }
 
 type IP struct {
 	IP       string `json:"ip,omitempty"`
 	Requests int    `json:"requests,omitempty"`
 	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Period   string `json:"period,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
 }
 
 type Token struct {
 	Token    string `json:"token,omitempty"`
 	Requests int    `json:"requests,omitempty"`
 	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Period   string `json:"period,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
+	Plan     string `json:"plan,omitempty"`
+}
+
+// Policy is a rule chosen by name by the clients of the rate limit service
+type Policy struct {
+	Name     string `json:"name,omitempty"`
+	Requests int    `json:"requests,omitempty"`
+	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Period   string `json:"period,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// Global is the rule of all the requests to the service
+type Global struct {
+	Requests int  `json:"requests,omitempty"`
+	Every    int  `json:"every,omitempty"`
+	DryRun   bool `json:"dry_run,omitempty"`
+}
+
+// Route is the rule of all the requests with a path starting with Path
+type Route struct {
+	Path     string `json:"path,omitempty"`
+	Requests int    `json:"requests,omitempty"`
+	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// Org is the rule shared by the tokens of an organization. Tokens lists the
+// members when the organizations are not resolved elsewhere
+type Org struct {
+	Name     string   `json:"name,omitempty"`
+	Requests int      `json:"requests,omitempty"`
+	Every    int      `json:"every,omitempty"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Period   string   `json:"period,omitempty"`
+	TimeZone string   `json:"time_zone,omitempty"`
+	Tokens   []string `json:"tokens,omitempty"`
+}
+
+// Endpoint is the rule of the requests of each key with a path starting
+// with Path
+type Endpoint struct {
+	Path     string `json:"path,omitempty"`
+	Requests int    `json:"requests,omitempty"`
+	Every    int    `json:"every,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// Ban rejects for Duration seconds every request of a key rejected by its own
+// rule After times within Window seconds
+type Ban struct {
+	After    int `json:"after,omitempty"`
+	Window   int `json:"window,omitempty"`
+	Duration int `json:"duration,omitempty"`
+}
+
+// Validate returns an error when the period of a rule is not a day, week or
+// month, its time zone is unknown, or the ban is not positive
+func (c RateLimiterConfig) Validate() error {
+	if err := validateCalendar("default", c.Default.Period, c.Default.TimeZone); err != nil {
+		return err
+	}
+
+	for i, ip := range c.IP {
+		if err := validateCalendar(fmt.Sprintf("ip_%d", i), ip.Period, ip.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	for i, token := range c.Token {
+		if err := validateCalendar(fmt.Sprintf("token_%d", i), token.Period, token.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range c.Policy {
+		if err := validateCalendar("policy_"+policy.Name, policy.Period, policy.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	for _, org := range c.Org {
+		if err := validateCalendar("org_"+org.Name, org.Period, org.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	if c.Ban != nil && (c.Ban.After <= 0 || c.Ban.Window <= 0 || c.Ban.Duration <= 0) {
+		return fmt.Errorf("invalid rate limit ban after %d requests within %d seconds for %d seconds",
+			c.Ban.After, c.Ban.Window, c.Ban.Duration)
+	}
+
+	return nil
+}
+
+func validateCalendar(rule, period, timeZone string) error {
+	switch period {
+	case "", PeriodDay, PeriodWeek, PeriodMonth:
+	default:
+		return fmt.Errorf("invalid period %q of the rate limit rule %s", period, rule)
+	}
+
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q of the rate limit rule %s", timeZone, rule)
+	}
+
+	return nil
 }
 
 // GetRateLimiterConfig returns the rate limiter configuration
@@ -39,13 +179,35 @@ func GetRateLimiterConfig() RateLimiterConfig {
 	// set default value
 	viper.SetDefault("RATE_LIMIT_DEFAULT_REQUESTS", 10)
 	viper.SetDefault("RATE_LIMIT_DEFAULT_EVERY", 60)
+	viper.SetDefault("RATE_LIMIT_DEFAULT_DRY_RUN", false)
+	viper.SetDefault("RATE_LIMIT_DRY_RUN_HEADER", false)
 
 	// get config default
 	rateLimiterConfig := RateLimiterConfig{
 		Default: Default{
 			Requests: viper.GetInt("RATE_LIMIT_DEFAULT_REQUESTS"),
 			Every:    viper.GetInt("RATE_LIMIT_DEFAULT_EVERY"),
+			DryRun:   viper.GetBool("RATE_LIMIT_DEFAULT_DRY_RUN"),
+			Period:   viper.GetString("RATE_LIMIT_DEFAULT_PERIOD"),
+			TimeZone: viper.GetString("RATE_LIMIT_DEFAULT_TIMEZONE"),
 		},
+		DryRunHeader: viper.GetBool("RATE_LIMIT_DRY_RUN_HEADER"),
+	}
+
+	if viper.IsSet("RATE_LIMIT_GLOBAL_REQUESTS") {
+		rateLimiterConfig.Global = &Global{
+			Requests: viper.GetInt("RATE_LIMIT_GLOBAL_REQUESTS"),
+			Every:    viper.GetInt("RATE_LIMIT_GLOBAL_EVERY"),
+			DryRun:   viper.GetBool("RATE_LIMIT_GLOBAL_DRY_RUN"),
+		}
+	}
+
+	if viper.IsSet("RATE_LIMIT_BAN_AFTER") {
+		rateLimiterConfig.Ban = &Ban{
+			After:    viper.GetInt("RATE_LIMIT_BAN_AFTER"),
+			Window:   viper.GetInt("RATE_LIMIT_BAN_WINDOW"),
+			Duration: viper.GetInt("RATE_LIMIT_BAN_DURATION"),
+		}
 	}
 
 	for i := 0; ; i++ {
@@ -58,11 +220,17 @@ func GetRateLimiterConfig() RateLimiterConfig {
 		ip := viper.GetString(ipKey)
 		requests := viper.GetInt(fmt.Sprintf("RATE_LIMIT_IP_%d_REQUESTS", i))
 		every := viper.GetInt(fmt.Sprintf("RATE_LIMIT_IP_%d_EVERY", i))
+		dryRun := viper.GetBool(fmt.Sprintf("RATE_LIMIT_IP_%d_DRY_RUN", i))
+		period := viper.GetString(fmt.Sprintf("RATE_LIMIT_IP_%d_PERIOD", i))
+		timeZone := viper.GetString(fmt.Sprintf("RATE_LIMIT_IP_%d_TIMEZONE", i))
 
 		rateLimiterConfig.IP = append(rateLimiterConfig.IP, IP{
 			IP:       ip,
 			Requests: requests,
 			Every:    every,
+			DryRun:   dryRun,
+			Period:   period,
+			TimeZone: timeZone,
 		})
 	}
 
@@ -76,11 +244,91 @@ func GetRateLimiterConfig() RateLimiterConfig {
 		token := viper.GetString(tokenKey)
 		requests := viper.GetInt(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_REQUESTS", i))
 		every := viper.GetInt(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_EVERY", i))
+		dryRun := viper.GetBool(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_DRY_RUN", i))
+		period := viper.GetString(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_PERIOD", i))
+		timeZone := viper.GetString(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_TIMEZONE", i))
+		plan := viper.GetString(fmt.Sprintf("RATE_LIMIT_TOKEN_%d_PLAN", i))
 
 		rateLimiterConfig.Token = append(rateLimiterConfig.Token, Token{
 			Token:    token,
 			Requests: requests,
 			Every:    every,
+			DryRun:   dryRun,
+			Period:   period,
+			TimeZone: timeZone,
+			Plan:     plan,
+		})
+	}
+
+	for i := 0; ; i++ {
+		policyKey := fmt.Sprintf("RATE_LIMIT_POLICY_%d", i)
+
+		if !viper.IsSet(policyKey) {
+			break
+		}
+
+		name := viper.GetString(policyKey)
+		requests := viper.GetInt(fmt.Sprintf("RATE_LIMIT_POLICY_%d_REQUESTS", i))
+		every := viper.GetInt(fmt.Sprintf("RATE_LIMIT_POLICY_%d_EVERY", i))
+		dryRun := viper.GetBool(fmt.Sprintf("RATE_LIMIT_POLICY_%d_DRY_RUN", i))
+		period := viper.GetString(fmt.Sprintf("RATE_LIMIT_POLICY_%d_PERIOD", i))
+		timeZone := viper.GetString(fmt.Sprintf("RATE_LIMIT_POLICY_%d_TIMEZONE", i))
+
+		rateLimiterConfig.Policy = append(rateLimiterConfig.Policy, Policy{
+			Name:     name,
+			Requests: requests,
+			Every:    every,
+			DryRun:   dryRun,
+			Period:   period,
+			TimeZone: timeZone,
+		})
+	}
+
+	for i := 0; ; i++ {
+		routeKey := fmt.Sprintf("RATE_LIMIT_ROUTE_%d", i)
+
+		if !viper.IsSet(routeKey) {
+			break
+		}
+
+		rateLimiterConfig.Route = append(rateLimiterConfig.Route, Route{
+			Path:     viper.GetString(routeKey),
+			Requests: viper.GetInt(fmt.Sprintf("RATE_LIMIT_ROUTE_%d_REQUESTS", i)),
+			Every:    viper.GetInt(fmt.Sprintf("RATE_LIMIT_ROUTE_%d_EVERY", i)),
+			DryRun:   viper.GetBool(fmt.Sprintf("RATE_LIMIT_ROUTE_%d_DRY_RUN", i)),
+		})
+	}
+
+	for i := 0; ; i++ {
+		orgKey := fmt.Sprintf("RATE_LIMIT_ORG_%d", i)
+
+		if !viper.IsSet(orgKey) {
+			break
+		}
+
+		rateLimiterConfig.Org = append(rateLimiterConfig.Org, Org{
+			Name:     viper.GetString(orgKey),
+			Requests: viper.GetInt(fmt.Sprintf("RATE_LIMIT_ORG_%d_REQUESTS", i)),
+			Every:    viper.GetInt(fmt.Sprintf("RATE_LIMIT_ORG_%d_EVERY", i)),
+			DryRun:   viper.GetBool(fmt.Sprintf("RATE_LIMIT_ORG_%d_DRY_RUN", i)),
+			Period:   viper.GetString(fmt.Sprintf("RATE_LIMIT_ORG_%d_PERIOD", i)),
+			TimeZone: viper.GetString(fmt.Sprintf("RATE_LIMIT_ORG_%d_TIMEZONE", i)),
+			Tokens:   env.List(viper.GetString(fmt.Sprintf("RATE_LIMIT_ORG_%d_TOKENS", i))),
+		})
+	}
+
+	for i := 0; ; i++ {
+		endpointKey := fmt.Sprintf("RATE_LIMIT_ENDPOINT_%d", i)
+
+		if !viper.IsSet(endpointKey) {
+			break
+		}
+
+		rateLimiterConfig.Endpoint = append(rateLimiterConfig.Endpoint, Endpoint{
+			Path:     viper.GetString(endpointKey),
+			Requests: viper.GetInt(fmt.Sprintf("RATE_LIMIT_ENDPOINT_%d_REQUESTS", i)),
+			Every:    viper.GetInt(fmt.Sprintf("RATE_LIMIT_ENDPOINT_%d_EVERY", i)),
+			DryRun:   viper.GetBool(fmt.Sprintf("RATE_LIMIT_ENDPOINT_%d_DRY_RUN", i)),
 		})
 	}
 
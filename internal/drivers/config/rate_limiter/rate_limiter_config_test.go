@@ -45,3 +45,230 @@ func TestGetRateLimiterConfig(t *testing.T) {
 	// Assert the result
 	assert.Equal(t, expected, result)
 }
+
+func TestGetRateLimiterConfig_DryRun(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_DEFAULT_DRY_RUN", true)
+	viper.Set("RATE_LIMIT_DRY_RUN_HEADER", true)
+	viper.Set("RATE_LIMIT_IP_0", "127.0.0.1")
+	viper.Set("RATE_LIMIT_IP_0_REQUESTS", 10)
+	viper.Set("RATE_LIMIT_IP_0_EVERY", 60)
+	viper.Set("RATE_LIMIT_IP_0_DRY_RUN", true)
+	viper.Set("RATE_LIMIT_TOKEN_0", "abc123")
+	viper.Set("RATE_LIMIT_TOKEN_0_REQUESTS", 20)
+	viper.Set("RATE_LIMIT_TOKEN_0_EVERY", 120)
+
+	expected := RateLimiterConfig{
+		Default: Default{
+			Requests: 10,
+			Every:    60,
+			DryRun:   true,
+		},
+		IP: []IP{
+			{
+				IP:       "127.0.0.1",
+				Requests: 10,
+				Every:    60,
+				DryRun:   true,
+			},
+		},
+		Token: []Token{
+			{
+				Token:    "abc123",
+				Requests: 20,
+				Every:    120,
+			},
+		},
+		DryRunHeader: true,
+	}
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, expected, result)
+}
+
+func TestGetRateLimiterConfig_Policy(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_POLICY_0", "search")
+	viper.Set("RATE_LIMIT_POLICY_0_REQUESTS", 100)
+	viper.Set("RATE_LIMIT_POLICY_0_EVERY", 60)
+	viper.Set("RATE_LIMIT_POLICY_1", "upload")
+	viper.Set("RATE_LIMIT_POLICY_1_REQUESTS", 5)
+	viper.Set("RATE_LIMIT_POLICY_1_EVERY", 3600)
+	viper.Set("RATE_LIMIT_POLICY_1_DRY_RUN", true)
+
+	expected := []Policy{
+		{
+			Name:     "search",
+			Requests: 100,
+			Every:    60,
+		},
+		{
+			Name:     "upload",
+			Requests: 5,
+			Every:    3600,
+			DryRun:   true,
+		},
+	}
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, expected, result.Policy)
+}
+
+func TestGetRateLimiterConfig_Period(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_DEFAULT_PERIOD", PeriodDay)
+	viper.Set("RATE_LIMIT_TOKEN_0", "abc123")
+	viper.Set("RATE_LIMIT_TOKEN_0_REQUESTS", 10000)
+	viper.Set("RATE_LIMIT_TOKEN_0_PERIOD", PeriodMonth)
+	viper.Set("RATE_LIMIT_TOKEN_0_TIMEZONE", "America/Sao_Paulo")
+	viper.Set("RATE_LIMIT_TOKEN_0_PLAN", "enterprise")
+	viper.Set("RATE_LIMIT_POLICY_0", "export")
+	viper.Set("RATE_LIMIT_POLICY_0_REQUESTS", 50)
+	viper.Set("RATE_LIMIT_POLICY_0_PERIOD", PeriodWeek)
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, PeriodDay, result.Default.Period)
+	assert.Equal(t, []Token{
+		{
+			Token:    "abc123",
+			Requests: 10000,
+			Period:   PeriodMonth,
+			TimeZone: "America/Sao_Paulo",
+			Plan:     "enterprise",
+		},
+	}, result.Token)
+	assert.Equal(t, []Policy{
+		{
+			Name:     "export",
+			Requests: 50,
+			Period:   PeriodWeek,
+		},
+	}, result.Policy)
+}
+
+func TestGetRateLimiterConfig_Global(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_GLOBAL_REQUESTS", 1000)
+	viper.Set("RATE_LIMIT_GLOBAL_EVERY", 1)
+	viper.Set("RATE_LIMIT_ROUTE_0", "/search")
+	viper.Set("RATE_LIMIT_ROUTE_0_REQUESTS", 100)
+	viper.Set("RATE_LIMIT_ROUTE_0_EVERY", 1)
+	viper.Set("RATE_LIMIT_ROUTE_0_DRY_RUN", true)
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, &Global{Requests: 1000, Every: 1}, result.Global)
+	assert.Equal(t, []Route{
+		{
+			Path:     "/search",
+			Requests: 100,
+			Every:    1,
+			DryRun:   true,
+		},
+	}, result.Route)
+
+	viper.Reset()
+
+	assert.Nil(t, GetRateLimiterConfig().Global)
+}
+
+func TestGetRateLimiterConfig_Ban(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_BAN_AFTER", 5)
+	viper.Set("RATE_LIMIT_BAN_WINDOW", 600)
+	viper.Set("RATE_LIMIT_BAN_DURATION", 3600)
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, &Ban{After: 5, Window: 600, Duration: 3600}, result.Ban)
+
+	viper.Reset()
+
+	assert.Nil(t, GetRateLimiterConfig().Ban)
+}
+
+func TestGetRateLimiterConfig_Hierarchy(t *testing.T) {
+	// Set up test environment
+	viper.Reset()
+	viper.Set("RATE_LIMIT_ORG_0", "acme")
+	viper.Set("RATE_LIMIT_ORG_0_REQUESTS", 100000)
+	viper.Set("RATE_LIMIT_ORG_0_PERIOD", PeriodMonth)
+	viper.Set("RATE_LIMIT_ORG_0_TOKENS", "token_1, token_2")
+	viper.Set("RATE_LIMIT_ENDPOINT_0", "/export")
+	viper.Set("RATE_LIMIT_ENDPOINT_0_REQUESTS", 5)
+	viper.Set("RATE_LIMIT_ENDPOINT_0_EVERY", 60)
+
+	// Call the function under test
+	result := GetRateLimiterConfig()
+
+	// Assert the result
+	assert.Equal(t, []Org{
+		{
+			Name:     "acme",
+			Requests: 100000,
+			Period:   PeriodMonth,
+			Tokens:   []string{"token_1", "token_2"},
+		},
+	}, result.Org)
+	assert.Equal(t, []Endpoint{
+		{
+			Path:     "/export",
+			Requests: 5,
+			Every:    60,
+		},
+	}, result.Endpoint)
+}
+
+func TestRateLimiterConfig_Validate(t *testing.T) {
+	t.Run("Should accept the calendar periods", func(t *testing.T) {
+		config := RateLimiterConfig{
+			Default: Default{Requests: 10, Every: 60},
+			Token:   []Token{{Token: "token_1", Period: PeriodDay}, {Token: "token_2", Period: PeriodWeek}},
+			Org:     []Org{{Name: "acme", Period: PeriodMonth, TimeZone: "America/Sao_Paulo"}},
+		}
+
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("Should return error when a period is unknown", func(t *testing.T) {
+		config := RateLimiterConfig{
+			Policy: []Policy{{Name: "search", Period: "monthly"}},
+		}
+
+		assert.EqualError(t, config.Validate(), `invalid period "monthly" of the rate limit rule policy_search`)
+	})
+
+	t.Run("Should return error when a time zone is unknown", func(t *testing.T) {
+		config := RateLimiterConfig{
+			Org: []Org{{Name: "acme", Period: PeriodDay, TimeZone: "America/Sao_Paolo"}},
+		}
+
+		assert.EqualError(t, config.Validate(), `invalid time zone "America/Sao_Paolo" of the rate limit rule org_acme`)
+	})
+
+	t.Run("Should return error when the ban is not positive", func(t *testing.T) {
+		config := RateLimiterConfig{
+			Ban: &Ban{After: 5, Window: 600},
+		}
+
+		assert.EqualError(t, config.Validate(), "invalid rate limit ban after 5 requests within 600 seconds for 0 seconds")
+	})
+}
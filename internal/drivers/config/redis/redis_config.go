@@ -1,14 +1,54 @@
 package redis
 
 import (
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
 	"github.com/spf13/viper"
 )
 
+// Redis modes, single connects to Host and Port and the cluster and sentinel
+// modes connect to Addrs
+const (
+	ModeSingle   = "single"
+	ModeCluster  = "cluster"
+	ModeSentinel = "sentinel"
+)
+
+// RedisConfig holds the redis connection options, timeouts are in seconds and
+// zero values keep the defaults of the redis client and of the circuit breaker
 type RedisConfig struct {
 	Host     string `json:"host,omitempty" env:"REDIS_HOST"`
+	Username string `json:"username,omitempty" env:"REDIS_USERNAME"`
 	Password string `json:"password,omitempty" env:"REDIS_PASSWORD"`
 	Port     int    `json:"port,omitempty" env:"REDIS_PORT"`
 	DB       int    `json:"db,omitempty" env:"REDIS_DB"`
+
+	Mode             string   `json:"mode,omitempty" env:"REDIS_MODE"`
+	Addrs            []string `json:"addrs,omitempty" env:"REDIS_ADDRS"`
+	MasterName       string   `json:"master_name,omitempty" env:"REDIS_MASTER_NAME"`
+	SentinelUsername string   `json:"sentinel_username,omitempty" env:"REDIS_SENTINEL_USERNAME"`
+	SentinelPassword string   `json:"sentinel_password,omitempty" env:"REDIS_SENTINEL_PASSWORD"`
+
+	TLS           bool   `json:"tls,omitempty" env:"REDIS_TLS"`
+	TLSCAFile     string `json:"tls_ca_file,omitempty" env:"REDIS_TLS_CA_FILE"`
+	TLSCertFile   string `json:"tls_cert_file,omitempty" env:"REDIS_TLS_CERT_FILE"`
+	TLSKeyFile    string `json:"tls_key_file,omitempty" env:"REDIS_TLS_KEY_FILE"`
+	TLSServerName string `json:"tls_server_name,omitempty" env:"REDIS_TLS_SERVER_NAME"`
+
+	KeyPrefix  string `json:"key_prefix,omitempty" env:"REDIS_KEY_PREFIX"`
+	Namespace  string `json:"namespace,omitempty" env:"REDIS_NAMESPACE"`
+	KeyHash    bool   `json:"key_hash,omitempty" env:"REDIS_KEY_HASH"`
+	KeyMigrate bool   `json:"key_migrate,omitempty" env:"REDIS_KEY_MIGRATE"`
+
+	PoolSize     int `json:"pool_size,omitempty" env:"REDIS_POOL_SIZE"`
+	MinIdleConns int `json:"min_idle_conns,omitempty" env:"REDIS_MIN_IDLE_CONNS"`
+	DialTimeout  int `json:"dial_timeout,omitempty" env:"REDIS_DIAL_TIMEOUT"`
+	ReadTimeout  int `json:"read_timeout,omitempty" env:"REDIS_READ_TIMEOUT"`
+	WriteTimeout int `json:"write_timeout,omitempty" env:"REDIS_WRITE_TIMEOUT"`
+
+	Breaker            bool `json:"breaker,omitempty" env:"REDIS_BREAKER"`
+	BreakerFailures    int  `json:"breaker_failures,omitempty" env:"REDIS_BREAKER_FAILURES"`
+	BreakerLatencyMs   int  `json:"breaker_latency_ms,omitempty" env:"REDIS_BREAKER_LATENCY_MS"`
+	BreakerOpenTimeout int  `json:"breaker_open_timeout,omitempty" env:"REDIS_BREAKER_OPEN_TIMEOUT"`
 }
 
 func GetRedisConfig() RedisConfig {
@@ -22,13 +62,43 @@ func GetRedisConfig() RedisConfig {
 	viper.SetDefault("REDIS_PASSWORD", "")
 	viper.SetDefault("REDIS_DB", 0)
 	viper.SetDefault("REDIS_PORT", 6379)
+	viper.SetDefault("REDIS_MODE", ModeSingle)
+	viper.SetDefault("REDIS_KEY_PREFIX", "ratelimit")
+	// the keys of the previous versions are migrated unless it is disabled,
+	// so an upgrade does not reset the windows in progress
+	viper.SetDefault("REDIS_KEY_MIGRATE", true)
 
 	// get config
 	redisConfig := RedisConfig{
-		Host:     viper.GetString("REDIS_HOST"),
-		Password: viper.GetString("REDIS_PASSWORD"),
-		DB:       viper.GetInt("REDIS_DB"),
-		Port:     viper.GetInt("REDIS_PORT"),
+		Host:             viper.GetString("REDIS_HOST"),
+		Username:         viper.GetString("REDIS_USERNAME"),
+		Password:         viper.GetString("REDIS_PASSWORD"),
+		DB:               viper.GetInt("REDIS_DB"),
+		Port:             viper.GetInt("REDIS_PORT"),
+		Mode:             viper.GetString("REDIS_MODE"),
+		Addrs:            env.List(viper.GetString("REDIS_ADDRS")),
+		MasterName:       viper.GetString("REDIS_MASTER_NAME"),
+		SentinelUsername: viper.GetString("REDIS_SENTINEL_USERNAME"),
+		SentinelPassword: viper.GetString("REDIS_SENTINEL_PASSWORD"),
+		TLS:              viper.GetBool("REDIS_TLS"),
+		TLSCAFile:        viper.GetString("REDIS_TLS_CA_FILE"),
+		TLSCertFile:      viper.GetString("REDIS_TLS_CERT_FILE"),
+		TLSKeyFile:       viper.GetString("REDIS_TLS_KEY_FILE"),
+		TLSServerName:    viper.GetString("REDIS_TLS_SERVER_NAME"),
+		KeyPrefix:        viper.GetString("REDIS_KEY_PREFIX"),
+		Namespace:        viper.GetString("REDIS_NAMESPACE"),
+		KeyHash:          viper.GetBool("REDIS_KEY_HASH"),
+		KeyMigrate:       viper.GetBool("REDIS_KEY_MIGRATE"),
+		PoolSize:         viper.GetInt("REDIS_POOL_SIZE"),
+		MinIdleConns:     viper.GetInt("REDIS_MIN_IDLE_CONNS"),
+		DialTimeout:      viper.GetInt("REDIS_DIAL_TIMEOUT"),
+		ReadTimeout:      viper.GetInt("REDIS_READ_TIMEOUT"),
+		WriteTimeout:     viper.GetInt("REDIS_WRITE_TIMEOUT"),
+
+		Breaker:            viper.GetBool("REDIS_BREAKER"),
+		BreakerFailures:    viper.GetInt("REDIS_BREAKER_FAILURES"),
+		BreakerLatencyMs:   viper.GetInt("REDIS_BREAKER_LATENCY_MS"),
+		BreakerOpenTimeout: viper.GetInt("REDIS_BREAKER_OPEN_TIMEOUT"),
 	}
 
 	return redisConfig
@@ -16,10 +16,13 @@ func TestGetRedisConfig(t *testing.T) {
 	viper.Set("REDIS_PORT", 1234)
 
 	expected := RedisConfig{
-		Host:     "testhost",
-		Password: "testpassword",
-		DB:       1,
-		Port:     1234,
+		Host:       "testhost",
+		Password:   "testpassword",
+		DB:         1,
+		Port:       1234,
+		Mode:       ModeSingle,
+		KeyPrefix:  "ratelimit",
+		KeyMigrate: true,
 	}
 
 	// Call the function under test
@@ -28,3 +31,85 @@ func TestGetRedisConfig(t *testing.T) {
 	// Assert the result
 	assert.Equal(t, expected, result)
 }
+
+func TestGetRedisConfig_ClusterSentinelTLS(t *testing.T) {
+	t.Run("Should return the cluster, sentinel, TLS and pool options", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("REDIS_MODE", "sentinel")
+		viper.Set("REDIS_ADDRS", "sentinel-1:26379, sentinel-2:26379,")
+		viper.Set("REDIS_MASTER_NAME", "mymaster")
+		viper.Set("REDIS_USERNAME", "limiter")
+		viper.Set("REDIS_SENTINEL_PASSWORD", "sentinelpassword")
+		viper.Set("REDIS_TLS", true)
+		viper.Set("REDIS_TLS_CA_FILE", "ca.pem")
+		viper.Set("REDIS_TLS_CERT_FILE", "cert.pem")
+		viper.Set("REDIS_TLS_KEY_FILE", "key.pem")
+		viper.Set("REDIS_TLS_SERVER_NAME", "redis.internal")
+		viper.Set("REDIS_POOL_SIZE", 50)
+		viper.Set("REDIS_MIN_IDLE_CONNS", 5)
+		viper.Set("REDIS_DIAL_TIMEOUT", 3)
+		viper.Set("REDIS_READ_TIMEOUT", 1)
+		viper.Set("REDIS_WRITE_TIMEOUT", 2)
+
+		result := GetRedisConfig()
+
+		assert.Equal(t, ModeSentinel, result.Mode)
+		assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, result.Addrs)
+		assert.Equal(t, "mymaster", result.MasterName)
+		assert.Equal(t, "limiter", result.Username)
+		assert.Equal(t, "sentinelpassword", result.SentinelPassword)
+		assert.True(t, result.TLS)
+		assert.Equal(t, "ca.pem", result.TLSCAFile)
+		assert.Equal(t, "cert.pem", result.TLSCertFile)
+		assert.Equal(t, "key.pem", result.TLSKeyFile)
+		assert.Equal(t, "redis.internal", result.TLSServerName)
+		assert.Equal(t, 50, result.PoolSize)
+		assert.Equal(t, 5, result.MinIdleConns)
+		assert.Equal(t, 3, result.DialTimeout)
+		assert.Equal(t, 1, result.ReadTimeout)
+		assert.Equal(t, 2, result.WriteTimeout)
+	})
+}
+
+func TestGetRedisConfig_Keys(t *testing.T) {
+	t.Run("Should return the key prefix, namespace, hash and migration options", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("REDIS_KEY_PREFIX", "rl")
+		viper.Set("REDIS_NAMESPACE", "billing")
+		viper.Set("REDIS_KEY_HASH", true)
+		viper.Set("REDIS_KEY_MIGRATE", true)
+
+		result := GetRedisConfig()
+
+		assert.Equal(t, "rl", result.KeyPrefix)
+		assert.Equal(t, "billing", result.Namespace)
+		assert.True(t, result.KeyHash)
+		assert.True(t, result.KeyMigrate)
+	})
+
+	t.Run("Should disable the migration of the legacy keys", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("REDIS_KEY_MIGRATE", false)
+
+		result := GetRedisConfig()
+
+		assert.False(t, result.KeyMigrate)
+	})
+}
+
+func TestGetRedisConfig_Breaker(t *testing.T) {
+	t.Run("Should return the circuit breaker options", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("REDIS_BREAKER", true)
+		viper.Set("REDIS_BREAKER_FAILURES", 3)
+		viper.Set("REDIS_BREAKER_LATENCY_MS", 50)
+		viper.Set("REDIS_BREAKER_OPEN_TIMEOUT", 10)
+
+		result := GetRedisConfig()
+
+		assert.True(t, result.Breaker)
+		assert.Equal(t, 3, result.BreakerFailures)
+		assert.Equal(t, 50, result.BreakerLatencyMs)
+		assert.Equal(t, 10, result.BreakerOpenTimeout)
+	})
+}
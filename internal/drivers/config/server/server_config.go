@@ -0,0 +1,52 @@
+package server
+
+import (
+	"github.com/spf13/viper"
+)
+
+// ServerConfig holds the HTTP server options, timeouts are in seconds
+type ServerConfig struct {
+	Addr            string `json:"addr,omitempty" env:"SERVER_ADDR"`
+	GRPCAddr        string `json:"grpc_addr,omitempty" env:"SERVER_GRPC_ADDR"`
+	TLSCertFile     string `json:"tls_cert_file,omitempty" env:"SERVER_TLS_CERT_FILE"`
+	TLSKeyFile      string `json:"tls_key_file,omitempty" env:"SERVER_TLS_KEY_FILE"`
+	TLSMinVersion   string `json:"tls_min_version,omitempty" env:"SERVER_TLS_MIN_VERSION"`
+	ReadTimeout     int    `json:"read_timeout,omitempty" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    int    `json:"write_timeout,omitempty" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     int    `json:"idle_timeout,omitempty" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout int    `json:"shutdown_timeout,omitempty" env:"SERVER_SHUTDOWN_TIMEOUT"`
+}
+
+// GetServerConfig returns the HTTP server configuration
+func GetServerConfig() ServerConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("SERVER_ADDR", ":8080")
+	viper.SetDefault("SERVER_GRPC_ADDR", ":9090")
+	viper.SetDefault("SERVER_TLS_CERT_FILE", "")
+	viper.SetDefault("SERVER_TLS_KEY_FILE", "")
+	viper.SetDefault("SERVER_TLS_MIN_VERSION", "1.2")
+	viper.SetDefault("SERVER_READ_TIMEOUT", 10)
+	viper.SetDefault("SERVER_WRITE_TIMEOUT", 10)
+	viper.SetDefault("SERVER_IDLE_TIMEOUT", 60)
+	viper.SetDefault("SERVER_SHUTDOWN_TIMEOUT", 30)
+
+	// get config
+	serverConfig := ServerConfig{
+		Addr:            viper.GetString("SERVER_ADDR"),
+		GRPCAddr:        viper.GetString("SERVER_GRPC_ADDR"),
+		TLSCertFile:     viper.GetString("SERVER_TLS_CERT_FILE"),
+		TLSKeyFile:      viper.GetString("SERVER_TLS_KEY_FILE"),
+		TLSMinVersion:   viper.GetString("SERVER_TLS_MIN_VERSION"),
+		ReadTimeout:     viper.GetInt("SERVER_READ_TIMEOUT"),
+		WriteTimeout:    viper.GetInt("SERVER_WRITE_TIMEOUT"),
+		IdleTimeout:     viper.GetInt("SERVER_IDLE_TIMEOUT"),
+		ShutdownTimeout: viper.GetInt("SERVER_SHUTDOWN_TIMEOUT"),
+	}
+
+	return serverConfig
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetServerConfig(t *testing.T) {
+	t.Run("Should return the server config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		expected := ServerConfig{
+			Addr:            ":8080",
+			GRPCAddr:        ":9090",
+			TLSMinVersion:   "1.2",
+			ReadTimeout:     10,
+			WriteTimeout:    10,
+			IdleTimeout:     60,
+			ShutdownTimeout: 30,
+		}
+
+		result := GetServerConfig()
+
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return the server config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("SERVER_ADDR", "127.0.0.1:8443")
+		viper.Set("SERVER_GRPC_ADDR", "127.0.0.1:9443")
+		viper.Set("SERVER_TLS_CERT_FILE", "cert.pem")
+		viper.Set("SERVER_TLS_KEY_FILE", "key.pem")
+		viper.Set("SERVER_TLS_MIN_VERSION", "1.3")
+		viper.Set("SERVER_READ_TIMEOUT", 5)
+		viper.Set("SERVER_WRITE_TIMEOUT", 15)
+		viper.Set("SERVER_IDLE_TIMEOUT", 120)
+		viper.Set("SERVER_SHUTDOWN_TIMEOUT", 20)
+
+		expected := ServerConfig{
+			Addr:            "127.0.0.1:8443",
+			GRPCAddr:        "127.0.0.1:9443",
+			TLSCertFile:     "cert.pem",
+			TLSKeyFile:      "key.pem",
+			TLSMinVersion:   "1.3",
+			ReadTimeout:     5,
+			WriteTimeout:    15,
+			IdleTimeout:     120,
+			ShutdownTimeout: 20,
+		}
+
+		result := GetServerConfig()
+
+		assert.Equal(t, expected, result)
+	})
+}
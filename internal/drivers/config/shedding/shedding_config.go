@@ -0,0 +1,73 @@
+package shedding
+
+import (
+	"fmt"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/env"
+	"github.com/spf13/viper"
+)
+
+// SheddingConfig holds the priority classes of the load shedding. Capacity
+// is how many requests may be in flight, each class admits requests while
+// fewer than its Share percent of the capacity are in flight, so the classes
+// with lower shares are shed first. The requests of no class are in
+// DefaultClass, or in the class with the lowest share when it is empty, and
+// the requests under the Exempt paths are never shed
+type SheddingConfig struct {
+	Enabled      bool     `json:"enabled,omitempty" env:"SHEDDING_ENABLED"`
+	Capacity     int      `json:"capacity,omitempty" env:"SHEDDING_CAPACITY"`
+	Header       string   `json:"header,omitempty" env:"SHEDDING_HEADER"`
+	DefaultClass string   `json:"default_class,omitempty" env:"SHEDDING_DEFAULT_CLASS"`
+	Exempt       []string `json:"exempt,omitempty" env:"SHEDDING_EXEMPT"`
+	Class        []Class  `json:"class,omitempty"`
+}
+
+// Class is a priority class, assigned to the requests under one of its
+// Routes, with one of its Headers as the value of the priority header or with
+// a token of one of its Plans. The classes are matched in order
+type Class struct {
+	Name    string   `json:"name,omitempty" env:"SHEDDING_CLASS_N"`
+	Share   int      `json:"share,omitempty" env:"SHEDDING_CLASS_N_SHARE"`
+	Routes  []string `json:"routes,omitempty" env:"SHEDDING_CLASS_N_ROUTES"`
+	Headers []string `json:"headers,omitempty" env:"SHEDDING_CLASS_N_HEADERS"`
+	Plans   []string `json:"plans,omitempty" env:"SHEDDING_CLASS_N_PLANS"`
+}
+
+// GetSheddingConfig returns the load shedding configuration
+func GetSheddingConfig() SheddingConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("SHEDDING_CAPACITY", 100)
+	viper.SetDefault("SHEDDING_HEADER", "X-Priority")
+	viper.SetDefault("SHEDDING_EXEMPT", "/healthz,/readyz,/livez,/admin/")
+
+	sheddingConfig := SheddingConfig{
+		Enabled:      viper.GetBool("SHEDDING_ENABLED"),
+		Capacity:     viper.GetInt("SHEDDING_CAPACITY"),
+		Header:       viper.GetString("SHEDDING_HEADER"),
+		DefaultClass: viper.GetString("SHEDDING_DEFAULT_CLASS"),
+		Exempt:       env.List(viper.GetString("SHEDDING_EXEMPT")),
+	}
+
+	for i := 0; ; i++ {
+		classKey := fmt.Sprintf("SHEDDING_CLASS_%d", i)
+
+		if !viper.IsSet(classKey) {
+			break
+		}
+
+		sheddingConfig.Class = append(sheddingConfig.Class, Class{
+			Name:    viper.GetString(classKey),
+			Share:   viper.GetInt(fmt.Sprintf("SHEDDING_CLASS_%d_SHARE", i)),
+			Routes:  env.List(viper.GetString(fmt.Sprintf("SHEDDING_CLASS_%d_ROUTES", i))),
+			Headers: env.List(viper.GetString(fmt.Sprintf("SHEDDING_CLASS_%d_HEADERS", i))),
+			Plans:   env.List(viper.GetString(fmt.Sprintf("SHEDDING_CLASS_%d_PLANS", i))),
+		})
+	}
+
+	return sheddingConfig
+}
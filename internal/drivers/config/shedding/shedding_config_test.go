@@ -0,0 +1,54 @@
+package shedding
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSheddingConfig(t *testing.T) {
+	t.Run("Should return the shedding config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetSheddingConfig()
+
+		assert.Equal(t, SheddingConfig{
+			Capacity: 100,
+			Header:   "X-Priority",
+			Exempt:   []string{"/healthz", "/readyz", "/livez", "/admin/"},
+		}, result)
+	})
+
+	t.Run("Should return the shedding config with the classes", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("SHEDDING_ENABLED", true)
+		viper.Set("SHEDDING_CAPACITY", 500)
+		viper.Set("SHEDDING_DEFAULT_CLASS", "standard")
+		viper.Set("SHEDDING_EXEMPT", "/status")
+		viper.Set("SHEDDING_CLASS_0", "critical")
+		viper.Set("SHEDDING_CLASS_0_SHARE", 100)
+		viper.Set("SHEDDING_CLASS_0_ROUTES", "/checkout, /payments")
+		viper.Set("SHEDDING_CLASS_0_PLANS", "enterprise")
+		viper.Set("SHEDDING_CLASS_1", "standard")
+		viper.Set("SHEDDING_CLASS_1_SHARE", 70)
+		viper.Set("SHEDDING_CLASS_2", "batch")
+		viper.Set("SHEDDING_CLASS_2_SHARE", 30)
+		viper.Set("SHEDDING_CLASS_2_HEADERS", "low,batch")
+
+		result := GetSheddingConfig()
+
+		assert.Equal(t, SheddingConfig{
+			Enabled:      true,
+			Capacity:     500,
+			Header:       "X-Priority",
+			DefaultClass: "standard",
+			Exempt:       []string{"/status"},
+			Class: []Class{
+				{Name: "critical", Share: 100, Routes: []string{"/checkout", "/payments"}, Plans: []string{"enterprise"}},
+				{Name: "standard", Share: 70},
+				{Name: "batch", Share: 30, Headers: []string{"low", "batch"}},
+			},
+		}, result)
+	})
+}
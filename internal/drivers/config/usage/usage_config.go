@@ -0,0 +1,47 @@
+package usage
+
+import "github.com/spf13/viper"
+
+// Sinks of the usage export
+const (
+	SinkJSONL   = "jsonl"
+	SinkWebhook = "webhook"
+	SinkSQL     = "sql"
+)
+
+// UsageConfig holds the options of the usage export, the totals of requests
+// per key and window are flushed to the sink every FlushInterval seconds and
+// retried Retries times, waiting RetryBackoffMs doubled after each failure.
+// The export is disabled when Sink is empty
+type UsageConfig struct {
+	Sink           string `json:"sink,omitempty" env:"USAGE_SINK"`
+	FlushInterval  int    `json:"flush_interval,omitempty" env:"USAGE_FLUSH_INTERVAL"`
+	File           string `json:"file,omitempty" env:"USAGE_FILE"`
+	WebhookURL     string `json:"-" env:"USAGE_WEBHOOK_URL"`
+	Retries        int    `json:"retries,omitempty" env:"USAGE_RETRIES"`
+	RetryBackoffMs int    `json:"retry_backoff_ms,omitempty" env:"USAGE_RETRY_BACKOFF_MS"`
+}
+
+// GetUsageConfig returns the usage export configuration
+func GetUsageConfig() UsageConfig {
+	// set config file
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	viper.ReadInConfig()
+
+	// set default value
+	viper.SetDefault("USAGE_FLUSH_INTERVAL", 60)
+	viper.SetDefault("USAGE_FILE", "usage.jsonl")
+	viper.SetDefault("USAGE_RETRIES", 3)
+	viper.SetDefault("USAGE_RETRY_BACKOFF_MS", 1000)
+
+	// get config
+	return UsageConfig{
+		Sink:           viper.GetString("USAGE_SINK"),
+		FlushInterval:  viper.GetInt("USAGE_FLUSH_INTERVAL"),
+		File:           viper.GetString("USAGE_FILE"),
+		WebhookURL:     viper.GetString("USAGE_WEBHOOK_URL"),
+		Retries:        viper.GetInt("USAGE_RETRIES"),
+		RetryBackoffMs: viper.GetInt("USAGE_RETRY_BACKOFF_MS"),
+	}
+}
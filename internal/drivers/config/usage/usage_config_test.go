@@ -0,0 +1,43 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUsageConfig(t *testing.T) {
+	t.Run("Should return the usage config with default values", func(t *testing.T) {
+		viper.Reset()
+
+		result := GetUsageConfig()
+
+		assert.Equal(t, UsageConfig{
+			FlushInterval:  60,
+			File:           "usage.jsonl",
+			Retries:        3,
+			RetryBackoffMs: 1000,
+		}, result)
+	})
+
+	t.Run("Should return the usage config with values from viper", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("USAGE_SINK", SinkWebhook)
+		viper.Set("USAGE_FLUSH_INTERVAL", 10)
+		viper.Set("USAGE_WEBHOOK_URL", "https://billing.example.com/usage")
+		viper.Set("USAGE_RETRIES", 5)
+		viper.Set("USAGE_RETRY_BACKOFF_MS", 200)
+
+		result := GetUsageConfig()
+
+		assert.Equal(t, UsageConfig{
+			Sink:           SinkWebhook,
+			FlushInterval:  10,
+			File:           "usage.jsonl",
+			WebhookURL:     "https://billing.example.com/usage",
+			Retries:        5,
+			RetryBackoffMs: 200,
+		}, result)
+	})
+}
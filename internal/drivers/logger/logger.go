@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/logger"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attributes that identify a client and are
+// masked when redaction is enabled
+var sensitiveKeys = map[string]bool{
+	"key":   true,
+	"ip":    true,
+	"token": true,
+}
+
+// NewLogger returns a logger writing to w with the level, format and
+// redaction informed in the configuration
+func NewLogger(cfg logger.LoggerConfig, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: parseLevel(cfg.Level),
+	}
+
+	if cfg.Redact {
+		opts.ReplaceAttr = redact
+	}
+
+	if strings.EqualFold(cfg.Format, "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return l
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[a.Key] {
+		return slog.String(a.Key, redacted)
+	}
+
+	return a
+}
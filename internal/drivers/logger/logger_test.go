@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("Should write text logs at the configured level", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		log := NewLogger(logger.LoggerConfig{Level: "warn"}, &buf)
+
+		log.Info("hidden")
+		log.Warn("visible", "key", "token_1")
+
+		assert.NotContains(t, buf.String(), "hidden")
+		assert.Contains(t, buf.String(), "msg=visible key=token_1")
+	})
+
+	t.Run("Should write json logs", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		log := NewLogger(logger.LoggerConfig{Level: "debug", Format: "json"}, &buf)
+
+		log.Debug("visible", "ip", "127.0.0.1")
+
+		assert.Contains(t, buf.String(), `"msg":"visible","ip":"127.0.0.1"`)
+	})
+
+	t.Run("Should redact keys, IPs and tokens", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		log := NewLogger(logger.LoggerConfig{Level: "info", Redact: true}, &buf)
+
+		log.Info("rate limit exceeded", "key", "token_1", "ip", "127.0.0.1", "rule", "token_0")
+
+		assert.NotContains(t, buf.String(), "token_1")
+		assert.NotContains(t, buf.String(), "127.0.0.1")
+		assert.Contains(t, buf.String(), "key=[REDACTED] ip=[REDACTED] rule=token_0")
+	})
+
+	t.Run("Should fall back to info level when level is invalid", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		log := NewLogger(logger.LoggerConfig{Level: "verbose"}, &buf)
+
+		assert.False(t, log.Enabled(context.Background(), slog.LevelDebug))
+		assert.True(t, log.Enabled(context.Background(), slog.LevelInfo))
+	})
+}
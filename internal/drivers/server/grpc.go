@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type GRPCServer struct {
+	config server.ServerConfig
+	server *grpc.Server
+	logger *slog.Logger
+}
+
+// NewGRPCServer returns the gRPC server of the config, serving TLS with the
+// same certificate and min version of the HTTP server when they are informed
+func NewGRPCServer(cfg server.ServerConfig, logger *slog.Logger, opts ...grpc.ServerOption) (*GRPCServer, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load grpc tls certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		opts = append([]grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, opts...)
+	}
+
+	return &GRPCServer{
+		config: cfg,
+		server: grpc.NewServer(opts...),
+		logger: logger,
+	}, nil
+}
+
+// Server returns the gRPC server to register the services on
+func (s *GRPCServer) Server() *grpc.Server {
+	return s.server
+}
+
+// Run serves gRPC until ctx is done, then waits for the active calls up to
+// the shutdown timeout before stopping the server
+func (s *GRPCServer) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.GRPCAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.serve(ctx, listener)
+}
+
+func (s *GRPCServer) serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		s.logger.Info("grpc server listening", "addr", listener.Addr().String(), "tls", s.config.TLSCertFile != "")
+
+		serveErr <- s.server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down grpc server", "timeout", s.config.ShutdownTimeout)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Duration(s.config.ShutdownTimeout) * time.Second):
+		s.logger.Warn("grpc shutdown timeout, closing active calls")
+		s.server.Stop()
+	}
+
+	if err := <-serveErr; err != nil {
+		return err
+	}
+
+	s.logger.Info("grpc server stopped")
+
+	return nil
+}
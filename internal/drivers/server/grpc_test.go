@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCServer_Run(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Should serve the registered services until ctx is done", func(t *testing.T) {
+		srv, err := NewGRPCServer(server.ServerConfig{ShutdownTimeout: 5}, logger)
+		assert.NoError(t, err)
+		grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+
+		go func() {
+			stopped <- srv.serve(ctx, listener)
+		}()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+		cancel()
+
+		assert.NoError(t, <-stopped)
+	})
+
+	t.Run("Should return error when address is invalid", func(t *testing.T) {
+		srv, err := NewGRPCServer(server.ServerConfig{GRPCAddr: "invalid:address:0"}, logger)
+		assert.NoError(t, err)
+
+		assert.Error(t, srv.Run(context.Background()))
+	})
+}
+
+func TestGRPCServer_TLS(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Should serve TLS with the certificate of the config", func(t *testing.T) {
+		certFile, keyFile, pool := writeCertificate(t)
+
+		srv, err := NewGRPCServer(server.ServerConfig{ShutdownTimeout: 5, TLSCertFile: certFile, TLSKeyFile: keyFile}, logger)
+		assert.NoError(t, err)
+		grpc_health_v1.RegisterHealthServer(srv.Server(), health.NewServer())
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+
+		go func() {
+			stopped <- srv.serve(ctx, listener)
+		}()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+		plain, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		defer plain.Close()
+
+		_, err = grpc_health_v1.NewHealthClient(plain).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Error(t, err)
+
+		cancel()
+
+		assert.NoError(t, <-stopped)
+	})
+
+	t.Run("Should return error when the certificate is invalid", func(t *testing.T) {
+		_, err := NewGRPCServer(server.ServerConfig{TLSCertFile: "missing.pem", TLSKeyFile: "missing.pem"}, logger)
+		assert.ErrorContains(t, err, "failed to load grpc tls certificate")
+
+		_, err = NewGRPCServer(server.ServerConfig{TLSCertFile: "cert.pem"}, logger)
+		assert.Error(t, err)
+	})
+}
+
+func writeCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "limiter.internal"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/server"
+)
+
+type Server struct {
+	config server.ServerConfig
+	server *http.Server
+	logger *slog.Logger
+}
+
+func NewServer(cfg server.ServerConfig, handler http.Handler, logger *slog.Logger) (*Server, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		config: cfg,
+		logger: logger,
+		server: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			TLSConfig:    tlsConfig,
+			ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+			WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+			IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		},
+	}, nil
+}
+
+// Run serves HTTP until ctx is done, then stops accepting connections, waits
+// for the active requests up to the shutdown timeout and closes the closers
+func (s *Server) Run(ctx context.Context, closers ...io.Closer) error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.serve(ctx, listener, closers...)
+}
+
+func (s *Server) serve(ctx context.Context, listener net.Listener, closers ...io.Closer) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		s.logger.Info("server listening", "addr", listener.Addr().String(), "tls", s.server.TLSConfig != nil)
+
+		if s.server.TLSConfig != nil {
+			serveErr <- s.server.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			serveErr <- s.server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return errors.Join(err, closeAll(closers))
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down server", "timeout", s.config.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	err := s.server.Shutdown(shutdownCtx)
+
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+
+	err = errors.Join(err, closeAll(closers))
+
+	if err != nil {
+		s.logger.Error("server shutdown failed", "error", err)
+		return err
+	}
+
+	s.logger.Info("server stopped")
+
+	return nil
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+
+	for _, closer := range closers {
+		err = errors.Join(err, closer.Close())
+	}
+
+	return err
+}
+
+func newTLSConfig(cfg server.ServerConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both tls cert file and tls key file must be informed")
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+
+	switch cfg.TLSMinVersion {
+	case "", "1.2":
+	case "1.3":
+		minVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls min version: %s", cfg.TLSMinVersion)
+	}
+
+	return &tls.Config{MinVersion: minVersion}, nil
+}
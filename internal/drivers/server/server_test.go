@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCloser struct {
+	closed bool
+	err    error
+}
+
+func (m *mockCloser) Close() error {
+	m.closed = true
+	return m.err
+}
+
+func TestServer_Run(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Should drain active requests and close closers on shutdown", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+		})
+
+		srv, err := NewServer(server.ServerConfig{ShutdownTimeout: 5}, handler, logger)
+		assert.NoError(t, err)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		closer := &mockCloser{}
+		stopped := make(chan error)
+
+		go func() {
+			stopped <- srv.serve(ctx, listener, closer)
+		}()
+
+		response := make(chan string)
+
+		go func() {
+			resp, err := http.Get("http://" + listener.Addr().String())
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			response <- string(body)
+		}()
+
+		<-started
+		cancel()
+
+		select {
+		case <-stopped:
+			t.Fatal("server stopped before draining the active request")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		assert.False(t, closer.closed)
+
+		close(release)
+
+		assert.Equal(t, "done", <-response)
+		assert.NoError(t, <-stopped)
+		assert.True(t, closer.closed)
+	})
+
+	t.Run("Should return the closers error", func(t *testing.T) {
+		srv, err := NewServer(server.ServerConfig{ShutdownTimeout: 1}, http.NotFoundHandler(), logger)
+		assert.NoError(t, err)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = srv.serve(ctx, listener, &mockCloser{err: errors.New("close error")})
+
+		assert.ErrorContains(t, err, "close error")
+	})
+
+	t.Run("Should return error when address is invalid", func(t *testing.T) {
+		srv, err := NewServer(server.ServerConfig{Addr: "invalid:address:0"}, http.NotFoundHandler(), logger)
+		assert.NoError(t, err)
+
+		err = srv.Run(context.Background())
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Should set the configured timeouts", func(t *testing.T) {
+		srv, err := NewServer(server.ServerConfig{ReadTimeout: 1, WriteTimeout: 2, IdleTimeout: 3}, http.NotFoundHandler(), logger)
+		assert.NoError(t, err)
+
+		assert.Equal(t, time.Second, srv.server.ReadTimeout)
+		assert.Equal(t, 2*time.Second, srv.server.WriteTimeout)
+		assert.Equal(t, 3*time.Second, srv.server.IdleTimeout)
+		assert.Nil(t, srv.server.TLSConfig)
+	})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("Should use TLS 1.2 as default min version", func(t *testing.T) {
+		cfg, err := newTLSConfig(server.ServerConfig{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	})
+
+	t.Run("Should use TLS 1.3 as min version", func(t *testing.T) {
+		cfg, err := newTLSConfig(server.ServerConfig{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSMinVersion: "1.3"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	})
+
+	t.Run("Should return error when only one tls file is informed", func(t *testing.T) {
+		_, err := newTLSConfig(server.ServerConfig{TLSCertFile: "cert.pem"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Should return error when min version is not supported", func(t *testing.T) {
+		_, err := newTLSConfig(server.ServerConfig{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSMinVersion: "1.0"})
+
+		assert.ErrorContains(t, err, "unsupported tls min version")
+	})
+}
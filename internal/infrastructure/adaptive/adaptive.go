@@ -0,0 +1,35 @@
+package adaptive
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+)
+
+// GetController returns the controller of the configured signals, started
+// with the configured interval, or nil when the adaptive limits are disabled
+func GetController(cfg config.Config, logger *slog.Logger) (*Controller, error) {
+	if !cfg.Adaptive.Enabled {
+		return nil, nil
+	}
+
+	controller, err := NewController(
+		WithBounds(cfg.Adaptive.MinFactor, cfg.Adaptive.MaxFactor),
+		WithIncrease(cfg.Adaptive.Increase),
+		WithDecrease(cfg.Adaptive.Decrease),
+		WithLatency(time.Duration(cfg.Adaptive.LatencyMs)*time.Millisecond),
+		WithErrorRate(cfg.Adaptive.ErrorRate),
+		WithCPULoad(cfg.Adaptive.CPULoad, nil),
+		WithLogger(logger),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Adaptive.Interval > 0 {
+		controller.Start(time.Duration(cfg.Adaptive.Interval) * time.Second)
+	}
+
+	return controller, nil
+}
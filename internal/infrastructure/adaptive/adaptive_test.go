@@ -0,0 +1,50 @@
+package adaptive
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	adaptiveConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/adaptive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetController(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Should return nil when the adaptive limits are disabled", func(t *testing.T) {
+		controller, err := GetController(config.Config{}, logger)
+
+		assert.NoError(t, err)
+		assert.Nil(t, controller)
+	})
+
+	t.Run("Should return the controller of the config", func(t *testing.T) {
+		controller, err := GetController(config.Config{Adaptive: adaptiveConfig.AdaptiveConfig{
+			Enabled:   true,
+			Interval:  60,
+			MinFactor: 0.5,
+			MaxFactor: 2,
+			Increase:  0.05,
+			Decrease:  0.5,
+			LatencyMs: 100,
+		}}, logger)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2.0, controller.Factor())
+		assert.NoError(t, controller.Close())
+	})
+
+	t.Run("Should return an error when the config is invalid", func(t *testing.T) {
+		_, err := GetController(config.Config{Adaptive: adaptiveConfig.AdaptiveConfig{
+			Enabled:   true,
+			MinFactor: 2,
+			MaxFactor: 1,
+			Increase:  0.05,
+			Decrease:  0.5,
+		}}, logger)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,274 @@
+// Package adaptive scales the limits of the rules with the load of the
+// system, using an additive increase, multiplicative decrease controller
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CPULoader returns the CPU load of the system, 1 when every CPU is busy
+type CPULoader func() (float64, error)
+
+// Controller observes the latency and failures of the requests and, on
+// every step, multiplies the factor by the decrease when a signal is over
+// its threshold or adds the increase to it otherwise, between the bounds.
+// The factor starts at the upper bound
+type Controller struct {
+	minFactor float64
+	maxFactor float64
+	increase  float64
+	decrease  float64
+	latency   time.Duration
+	errorRate float64
+	cpuLoad   float64
+	cpuLoader CPULoader
+	logger    *slog.Logger
+
+	mutex      sync.Mutex
+	factor     float64
+	requests   int
+	failures   int
+	latencySum time.Duration
+	stop       context.CancelFunc
+	stopped    chan struct{}
+}
+
+// Option configures the Controller
+type Option func(*Controller)
+
+// WithBounds sets the lowest and highest factor, 0.1 and 1 when they are
+// not informed
+func WithBounds(minFactor, maxFactor float64) Option {
+	return func(c *Controller) {
+		c.minFactor = minFactor
+		c.maxFactor = maxFactor
+	}
+}
+
+// WithIncrease sets what is added to the factor on a healthy step, 0.05
+// when it is not informed
+func WithIncrease(increase float64) Option {
+	return func(c *Controller) {
+		c.increase = increase
+	}
+}
+
+// WithDecrease sets what multiplies the factor on an overloaded step, 0.5
+// when it is not informed
+func WithDecrease(decrease float64) Option {
+	return func(c *Controller) {
+		c.decrease = decrease
+	}
+}
+
+// WithLatency decreases the factor when the mean latency of the requests
+// observed in the step is over latency
+func WithLatency(latency time.Duration) Option {
+	return func(c *Controller) {
+		c.latency = latency
+	}
+}
+
+// WithErrorRate decreases the factor when the fraction of the requests
+// observed in the step that failed is over rate
+func WithErrorRate(rate float64) Option {
+	return func(c *Controller) {
+		c.errorRate = rate
+	}
+}
+
+// WithCPULoad decreases the factor when the CPU load returned by loader is
+// over load, LoadAverage is used when loader is nil
+func WithCPULoad(load float64, loader CPULoader) Option {
+	return func(c *Controller) {
+		c.cpuLoad = load
+		c.cpuLoader = loader
+	}
+}
+
+// WithLogger sets the logger of the factor changes, the default slog logger
+// is used when it is not informed
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Controller) {
+		c.logger = logger
+	}
+}
+
+// NewController returns the controller configured by the options, or an
+// error when the bounds, the increase or the decrease are invalid
+func NewController(opts ...Option) (*Controller, error) {
+	c := &Controller{
+		minFactor: 0.1,
+		maxFactor: 1,
+		increase:  0.05,
+		decrease:  0.5,
+		logger:    slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.minFactor <= 0 || c.minFactor > c.maxFactor {
+		return nil, fmt.Errorf("invalid adaptive factor bounds %v and %v", c.minFactor, c.maxFactor)
+	}
+
+	if c.increase <= 0 {
+		return nil, fmt.Errorf("invalid adaptive increase %v", c.increase)
+	}
+
+	if c.decrease <= 0 || c.decrease >= 1 {
+		return nil, fmt.Errorf("invalid adaptive decrease %v", c.decrease)
+	}
+
+	if c.cpuLoad > 0 && c.cpuLoader == nil {
+		c.cpuLoader = LoadAverage
+	}
+
+	c.factor = c.maxFactor
+
+	return c, nil
+}
+
+// Factor returns the current factor of the limits
+func (c *Controller) Factor() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.factor
+}
+
+// Observe counts a request that took latency, failed when it was answered
+// with an error
+func (c *Controller) Observe(latency time.Duration, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.requests++
+	c.latencySum += latency
+
+	if failed {
+		c.failures++
+	}
+}
+
+// Step adjusts the factor with the requests observed since the last step
+// and the CPU load, and returns it
+func (c *Controller) Step(ctx context.Context) float64 {
+	var load float64
+	var loadErr error
+
+	if c.cpuLoader != nil {
+		load, loadErr = c.cpuLoader()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	reason := ""
+
+	switch {
+	case c.requests > 0 && c.latency > 0 && c.latencySum/time.Duration(c.requests) > c.latency:
+		reason = "latency"
+	case c.requests > 0 && c.errorRate > 0 && float64(c.failures)/float64(c.requests) > c.errorRate:
+		reason = "error_rate"
+	case c.cpuLoader != nil && loadErr == nil && load > c.cpuLoad:
+		reason = "cpu_load"
+	}
+
+	if loadErr != nil {
+		c.logger.WarnContext(ctx, "failed to read the cpu load", "error", loadErr)
+	}
+
+	from := c.factor
+
+	if reason != "" {
+		c.factor = max(c.factor*c.decrease, c.minFactor)
+	} else {
+		c.factor = min(c.factor+c.increase, c.maxFactor)
+	}
+
+	c.requests, c.failures, c.latencySum = 0, 0, 0
+
+	if reason != "" && from != c.factor {
+		c.logger.InfoContext(ctx, "decreasing adaptive rate limits", "reason", reason, "from", from, "to", c.factor)
+	} else if from != c.factor {
+		c.logger.DebugContext(ctx, "increasing adaptive rate limits", "from", from, "to", c.factor)
+	}
+
+	return c.factor
+}
+
+// Start adjusts the factor every interval, until Close is called
+func (c *Controller) Start(interval time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	c.stop = stop
+	c.stopped = make(chan struct{})
+
+	go func() {
+		defer close(c.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Step(ctx)
+			}
+		}
+	}()
+}
+
+// Close stops adjusting the factor
+func (c *Controller) Close() error {
+	c.mutex.Lock()
+	stop, stopped := c.stop, c.stopped
+	c.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	return nil
+}
+
+// LoadAverage returns the load average of the last minute per CPU, read
+// from /proc/loadavg, so it is only available on Linux
+func LoadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("invalid /proc/loadavg")
+	}
+
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return load / float64(runtime.NumCPU()), nil
+}
@@ -0,0 +1,133 @@
+package adaptive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newController(opts ...Option) *Controller {
+	c, err := NewController(append([]Option{
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func TestController(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should return an error when the options are invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithBounds(0, 1),
+			WithBounds(2, 1),
+			WithIncrease(0),
+			WithDecrease(0),
+			WithDecrease(1),
+		} {
+			_, err := NewController(opt)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("Should start at the upper bound", func(t *testing.T) {
+		assert.Equal(t, 1.0, newController().Factor())
+		assert.Equal(t, 2.0, newController(WithBounds(0.5, 2)).Factor())
+	})
+
+	t.Run("Should decrease multiplicatively when the latency is over the threshold", func(t *testing.T) {
+		c := newController(WithLatency(100 * time.Millisecond))
+
+		c.Observe(50*time.Millisecond, false)
+		c.Observe(250*time.Millisecond, false)
+
+		assert.Equal(t, 0.5, c.Step(ctx))
+	})
+
+	t.Run("Should decrease multiplicatively when the error rate is over the threshold", func(t *testing.T) {
+		c := newController(WithErrorRate(0.1), WithDecrease(0.8))
+
+		for i := 0; i < 9; i++ {
+			c.Observe(time.Millisecond, false)
+		}
+
+		c.Observe(time.Millisecond, true)
+		assert.Equal(t, 1.0, c.Step(ctx))
+
+		c.Observe(time.Millisecond, false)
+		c.Observe(time.Millisecond, true)
+		assert.Equal(t, 0.8, c.Step(ctx))
+	})
+
+	t.Run("Should decrease multiplicatively when the cpu load is over the threshold", func(t *testing.T) {
+		load := 0.95
+		c := newController(WithCPULoad(0.9, func() (float64, error) { return load, nil }))
+
+		assert.Equal(t, 0.5, c.Step(ctx))
+
+		load = 0.5
+		assert.InDelta(t, 0.55, c.Step(ctx), 1e-9)
+	})
+
+	t.Run("Should keep the factor between the bounds", func(t *testing.T) {
+		c := newController(WithBounds(0.2, 1), WithIncrease(0.3), WithLatency(time.Millisecond))
+
+		for i := 0; i < 5; i++ {
+			c.Observe(time.Second, false)
+			c.Step(ctx)
+		}
+
+		assert.Equal(t, 0.2, c.Factor())
+
+		for i := 0; i < 5; i++ {
+			c.Step(ctx)
+		}
+
+		assert.Equal(t, 1.0, c.Factor())
+	})
+
+	t.Run("Should only count the requests observed since the last step", func(t *testing.T) {
+		c := newController(WithLatency(100*time.Millisecond), WithIncrease(0.1))
+
+		c.Observe(time.Second, false)
+		assert.Equal(t, 0.5, c.Step(ctx))
+		assert.InDelta(t, 0.6, c.Step(ctx), 1e-9)
+	})
+
+	t.Run("Should log the decreases and the cpu load errors", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		c, err := NewController(
+			WithLatency(time.Millisecond),
+			WithCPULoad(0.9, func() (float64, error) { return 0, errors.New("no cpu") }),
+			WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		)
+		assert.NoError(t, err)
+
+		c.Observe(time.Second, false)
+		c.Step(ctx)
+
+		assert.Contains(t, logs.String(), `msg="failed to read the cpu load" error="no cpu"`)
+		assert.Contains(t, logs.String(), `msg="decreasing adaptive rate limits" reason=latency from=1 to=0.5`)
+	})
+
+	t.Run("Should adjust the factor in background", func(t *testing.T) {
+		c := newController(WithCPULoad(0.5, func() (float64, error) { return 1, nil }))
+		c.Start(time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			return c.Factor() == 0.1
+		}, time.Second, time.Millisecond)
+
+		assert.NoError(t, c.Close())
+	})
+}
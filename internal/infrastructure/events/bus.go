@@ -0,0 +1,151 @@
+// Package events delivers the rate limit events emitted by the use case,
+// such as a key exceeding its limit, to webhooks, logs and channels
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+)
+
+// Sink delivers an event, a failed delivery is logged and not retried
+type Sink interface {
+	Send(ctx context.Context, event entities.Event) error
+}
+
+type dedupKey struct {
+	eventType string
+	key       string
+	reset     int64
+}
+
+// Bus publishes each event once per type, key and window to every sink.
+// The events are delivered in background, in the order they were published,
+// so Publish never waits for the sinks. When the queue is full the event is
+// dropped and logged
+type Bus struct {
+	sinks      []Sink
+	logger     *slog.Logger
+	bufferSize int
+	queue      chan entities.Event
+	mutex      sync.Mutex
+	seen       map[dedupKey]struct{}
+	lastPrune  int64
+	closed     bool
+	done       chan struct{}
+}
+
+// Option configures the Bus
+type Option func(*Bus)
+
+// WithLogger sets the logger of the dropped events and failed deliveries,
+// the default slog logger is used when it is not informed
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *Bus) {
+		b.logger = logger
+	}
+}
+
+// WithBufferSize sets how many events wait for delivery before new ones are
+// dropped, 1000 when it is not informed
+func WithBufferSize(size int) Option {
+	return func(b *Bus) {
+		b.bufferSize = size
+	}
+}
+
+// NewBus returns the bus delivering to sinks, started until Close is called
+func NewBus(sinks []Sink, opts ...Option) *Bus {
+	b := &Bus{
+		sinks:      sinks,
+		logger:     slog.Default(),
+		bufferSize: 1000,
+		seen:       make(map[dedupKey]struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.queue = make(chan entities.Event, max(b.bufferSize, 1))
+
+	go b.deliver()
+
+	return b
+}
+
+// Publish queues the event for delivery, unless an event of the same type
+// was published for the window of the key
+func (b *Bus) Publish(ctx context.Context, event entities.Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.prune(event.Time)
+
+	key := dedupKey{eventType: event.Type, key: event.Key, reset: event.Reset}
+
+	if _, ok := b.seen[key]; ok {
+		return
+	}
+
+	select {
+	case b.queue <- event:
+		b.seen[key] = struct{}{}
+	default:
+		b.logger.WarnContext(ctx, "rate limit event queue is full, dropping event", "type", event.Type, "key", event.Key)
+	}
+}
+
+// prune forgets the windows reset before now, at most once a minute
+func (b *Bus) prune(now int64) {
+	if now-b.lastPrune < 60 {
+		return
+	}
+
+	b.lastPrune = now
+
+	for key := range b.seen {
+		if key.reset < now {
+			delete(b.seen, key)
+		}
+	}
+}
+
+func (b *Bus) deliver() {
+	defer close(b.done)
+
+	ctx := context.Background()
+
+	for event := range b.queue {
+		for _, sink := range b.sinks {
+			if err := sink.Send(ctx, event); err != nil {
+				b.logger.ErrorContext(ctx, "failed to deliver rate limit event", "type", event.Type, "key", event.Key, "error", err)
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits for the delivery of the queued ones
+func (b *Bus) Close() error {
+	b.mutex.Lock()
+
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	close(b.queue)
+	b.mutex.Unlock()
+
+	<-b.done
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySink keeps the events sent, failing with err while it is set
+type memorySink struct {
+	mutex   sync.Mutex
+	events  []entities.Event
+	err     error
+	blocked chan struct{}
+}
+
+func (s *memorySink) Send(ctx context.Context, event entities.Event) error {
+	if s.blocked != nil {
+		<-s.blocked
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.TODO()
+	exceeded := entities.Event{Type: entities.EventLimitExceeded, Key: "token_1", Reset: 1060, Time: 1000}
+
+	t.Run("Should deliver each event once per window to every sink", func(t *testing.T) {
+		first, second := &memorySink{}, &memorySink{}
+		bus := NewBus([]Sink{first, second}, WithLogger(discardLogger()))
+
+		nearly := entities.Event{Type: entities.EventQuotaNearlyExhausted, Key: "token_1", Reset: 1060, Time: 1000}
+		nextWindow := entities.Event{Type: entities.EventLimitExceeded, Key: "token_1", Reset: 1120, Time: 1061}
+
+		bus.Publish(ctx, nearly)
+		bus.Publish(ctx, exceeded)
+		bus.Publish(ctx, exceeded)
+		bus.Publish(ctx, nearly)
+		bus.Publish(ctx, nextWindow)
+
+		assert.NoError(t, bus.Close())
+
+		for _, sink := range []*memorySink{first, second} {
+			assert.Equal(t, []entities.Event{nearly, exceeded, nextWindow}, sink.events)
+		}
+	})
+
+	t.Run("Should forget the windows after their reset", func(t *testing.T) {
+		sink := &memorySink{}
+		bus := NewBus([]Sink{sink}, WithLogger(discardLogger()))
+
+		bus.Publish(ctx, exceeded)
+		bus.Publish(ctx, entities.Event{Type: entities.EventLimitExceeded, Key: "token_2", Reset: 1200, Time: 1100})
+
+		assert.NoError(t, bus.Close())
+		assert.Len(t, bus.seen, 1)
+	})
+
+	t.Run("Should drop the events when the queue is full", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		sink := &memorySink{blocked: make(chan struct{})}
+		bus := NewBus([]Sink{sink}, WithBufferSize(1), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+		for i := int64(0); i < 3; i++ {
+			bus.Publish(ctx, entities.Event{Type: entities.EventLimitExceeded, Key: "token_1", Reset: 1060 + i*60, Time: 1000})
+		}
+
+		close(sink.blocked)
+		assert.NoError(t, bus.Close())
+
+		assert.LessOrEqual(t, len(sink.events), 2)
+		assert.Contains(t, logs.String(), "rate limit event queue is full, dropping event")
+	})
+
+	t.Run("Should log the failed deliveries", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		bus := NewBus([]Sink{&memorySink{err: errors.New("sink down")}}, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+		bus.Publish(ctx, exceeded)
+		assert.NoError(t, bus.Close())
+
+		assert.Contains(t, logs.String(), `msg="failed to deliver rate limit event" type=limit_exceeded key=token_1 error="sink down"`)
+	})
+
+	t.Run("Should ignore the events published after close", func(t *testing.T) {
+		sink := &memorySink{}
+		bus := NewBus([]Sink{sink}, WithLogger(discardLogger()))
+
+		assert.NoError(t, bus.Close())
+		bus.Publish(ctx, exceeded)
+
+		assert.Empty(t, sink.events)
+		assert.NoError(t, bus.Close())
+	})
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	eventsConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/events"
+)
+
+// GetEventBus returns the bus of the configured sinks, or nil when the events
+// are disabled. The channel sink is only available to library users
+func GetEventBus(cfg config.Config, logger *slog.Logger) (*Bus, error) {
+	var sinks []Sink
+
+	for _, sink := range cfg.Events.Sinks {
+		switch sink {
+		case eventsConfig.SinkLog:
+			sinks = append(sinks, NewLogSink(logger))
+		case eventsConfig.SinkWebhook:
+			if cfg.Events.WebhookURL == "" {
+				return nil, errors.New("event webhook URL is required")
+			}
+
+			sinks = append(sinks, NewWebhookSink(cfg.Events.WebhookURL, cfg.Events.WebhookSecret, nil))
+		default:
+			return nil, fmt.Errorf("unsupported event sink: %q", sink)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	return NewBus(sinks, WithLogger(logger), WithBufferSize(cfg.Events.BufferSize)), nil
+}
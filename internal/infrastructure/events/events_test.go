@@ -0,0 +1,37 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	eventsConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEventBus(t *testing.T) {
+	t.Run("Should return nil when the events are disabled", func(t *testing.T) {
+		bus, err := GetEventBus(config.Config{}, discardLogger())
+
+		assert.NoError(t, err)
+		assert.Nil(t, bus)
+	})
+
+	t.Run("Should return the bus of the sinks", func(t *testing.T) {
+		bus, err := GetEventBus(config.Config{Events: eventsConfig.EventsConfig{
+			Sinks:      []string{eventsConfig.SinkLog, eventsConfig.SinkWebhook},
+			WebhookURL: "http://localhost/events",
+		}}, discardLogger())
+
+		assert.NoError(t, err)
+		assert.Len(t, bus.sinks, 2)
+		assert.NoError(t, bus.Close())
+	})
+
+	t.Run("Should return error when a sink is invalid", func(t *testing.T) {
+		_, err := GetEventBus(config.Config{Events: eventsConfig.EventsConfig{Sinks: []string{"kafka"}}}, discardLogger())
+		assert.EqualError(t, err, `unsupported event sink: "kafka"`)
+
+		_, err = GetEventBus(config.Config{Events: eventsConfig.EventsConfig{Sinks: []string{eventsConfig.SinkWebhook}}}, discardLogger())
+		assert.EqualError(t, err, "event webhook URL is required")
+	})
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+)
+
+// Headers of the signed webhook requests
+const (
+	SignatureHeader = "X-Ratelimit-Signature"
+	TimestampHeader = "X-Ratelimit-Timestamp"
+)
+
+// webhookTimeout limits each post of the webhook sink when no client is
+// informed, so a slow webhook does not hold the delivery of the other events
+const webhookTimeout = 10 * time.Second
+
+type webhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookSink returns the sink that posts each event to url as JSON, any
+// answer other than 2xx is a failure. When secret is not empty the request
+// has the unix time it was sent in X-Ratelimit-Timestamp and the signature
+// sha256=<hex HMAC-SHA256 of "timestamp.body"> in X-Ratelimit-Signature.
+// A client with a timeout of 10 seconds is used when client is nil
+func NewWebhookSink(url, secret string, client *http.Client) Sink {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+
+	return &webhookSink{
+		url:    url,
+		secret: []byte(secret),
+		client: client,
+	}
+}
+
+// Sign returns the signature of the body sent at timestamp with secret, as
+// sent in X-Ratelimit-Signature
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookSink) Send(ctx context.Context, event entities.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(s.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("event webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+type logSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink returns the sink that logs each event
+func NewLogSink(logger *slog.Logger) Sink {
+	return &logSink{
+		logger: logger,
+	}
+}
+
+func (s *logSink) Send(ctx context.Context, event entities.Event) error {
+	s.logger.InfoContext(ctx, "rate limit event",
+		"type", event.Type,
+		"key", event.Key,
+		"rule", event.Rule,
+		"limit", event.Limit,
+		"remaining", event.Remaining,
+		"reset", event.Reset,
+		"dry_run", event.DryRun,
+	)
+
+	return nil
+}
+
+type channelSink struct {
+	events chan<- entities.Event
+}
+
+// NewChannelSink returns the sink that sends each event to events, the
+// delivery to the other sinks waits while the channel is full
+func NewChannelSink(events chan<- entities.Event) Sink {
+	return &channelSink{
+		events: events,
+	}
+}
+
+func (s *channelSink) Send(ctx context.Context, event entities.Event) error {
+	select {
+	case s.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var event = entities.Event{
+	Type:      entities.EventLimitExceeded,
+	Key:       "token_1",
+	Rule:      "token_0",
+	Limit:     100,
+	Remaining: 0,
+	Reset:     1709294460,
+	Time:      1709294400,
+}
+
+func TestWebhookSink(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should post the event signed with the secret", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			timestamp := r.Header.Get(TimestampHeader)
+
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.JSONEq(t, `{"type":"limit_exceeded","key":"token_1","rule":"token_0","limit":100,"remaining":0,"reset":1709294460,"time":1709294400}`, string(body))
+			assert.NotEmpty(t, timestamp)
+			assert.Equal(t, Sign([]byte("s3cr3t"), timestamp, body), r.Header.Get(SignatureHeader))
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		assert.NoError(t, NewWebhookSink(server.URL, "s3cr3t", nil).Send(ctx, event))
+	})
+
+	t.Run("Should not sign the event without a secret", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get(SignatureHeader))
+			assert.Empty(t, r.Header.Get(TimestampHeader))
+		}))
+		defer server.Close()
+
+		assert.NoError(t, NewWebhookSink(server.URL, "", server.Client()).Send(ctx, event))
+	})
+
+	t.Run("Should return error when the answer is not 2xx", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		err := NewWebhookSink(server.URL, "s3cr3t", nil).Send(ctx, event)
+
+		assert.EqualError(t, err, "event webhook answered 502 Bad Gateway")
+	})
+
+	t.Run("Should limit the posts with a timeout when no client is informed", func(t *testing.T) {
+		sink := NewWebhookSink("http://localhost", "", nil).(*webhookSink)
+
+		assert.Equal(t, webhookTimeout, sink.client.Timeout)
+	})
+}
+
+func TestSign(t *testing.T) {
+	t.Run("Should return the HMAC-SHA256 of the timestamp and body", func(t *testing.T) {
+		signature := Sign([]byte("key"), "1709294400", []byte(`{}`))
+
+		assert.Equal(t, "sha256=f32cf5bddc97a50bc02d0c5b9e0c468458d6722fec698e8b156feeaaa904616a", signature)
+	})
+}
+
+func TestLogSink(t *testing.T) {
+	t.Run("Should log the event", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		sink := NewLogSink(slog.New(slog.NewTextHandler(&logs, nil)))
+
+		assert.NoError(t, sink.Send(context.TODO(), event))
+		assert.Contains(t, logs.String(), `msg="rate limit event" type=limit_exceeded key=token_1 rule=token_0 limit=100 remaining=0 reset=1709294460 dry_run=false`)
+	})
+}
+
+func TestChannelSink(t *testing.T) {
+	t.Run("Should send the event to the channel", func(t *testing.T) {
+		events := make(chan entities.Event, 1)
+
+		assert.NoError(t, NewChannelSink(events).Send(context.TODO(), event))
+		assert.Equal(t, event, <-events)
+	})
+
+	t.Run("Should return error when the context is done while the channel is full", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		err := NewChannelSink(make(chan entities.Event)).Send(ctx, event)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
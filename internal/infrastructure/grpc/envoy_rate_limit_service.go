@@ -0,0 +1,233 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Descriptor entry keys mapped onto the rate limit rules: the IP and token
+// entries are the limited key and the route entry is the name of the policy,
+// also checked against the global route rules
+const (
+	DescriptorIP    = "remote_address"
+	DescriptorToken = "api_key"
+	DescriptorRoute = "route"
+)
+
+type envoyRateLimitService struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	uc     usecases.RateLimitUseCase
+	logger *slog.Logger
+	clock  domain.Clock
+}
+
+// EnvoyOption configures optional dependencies of the envoy rate limit service
+type EnvoyOption func(*envoyRateLimitService)
+
+// WithClock sets the clock used to compute the duration until the reset,
+// it must be the clock of the use case. The system clock is used when it
+// is not informed
+func WithClock(clock domain.Clock) EnvoyOption {
+	return func(s *envoyRateLimitService) {
+		s.clock = clock
+	}
+}
+
+// NewEnvoyRateLimitService returns the envoy.service.ratelimit.v3.RateLimitService
+// implementation, so envoy can use the use case as its external rate limit service
+func NewEnvoyRateLimitService(uc usecases.RateLimitUseCase, logger *slog.Logger, opts ...EnvoyOption) rlsv3.RateLimitServiceServer {
+	s := &envoyRateLimitService{
+		uc:     uc,
+		logger: logger,
+		clock:  domain.SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// ShouldRateLimit checks every descriptor of the request, the request is
+// over the limit when any of them is. The keys are counted apart in each
+// domain of the request. The IP or token of a route without policy is
+// limited by its own rule. Descriptors without IP, token or route entries,
+// or with only a route without policy, are not limited
+func (s *envoyRateLimitService) ShouldRateLimit(ctx context.Context, request *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if len(request.GetDescriptors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "rate limit descriptors are required")
+	}
+
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+	}
+
+	var selected *entities.Decision
+
+	for _, descriptor := range request.GetDescriptors() {
+		checkRequest, ok := descriptorCheckRequest(descriptor, int(request.GetHitsAddend()))
+		if !ok {
+			response.Statuses = append(response.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{
+				Code: rlsv3.RateLimitResponse_OK,
+			})
+			continue
+		}
+
+		checkRequest.Domain = request.GetDomain()
+
+		decision, err := s.uc.Check(ctx, checkRequest)
+
+		if errors.Is(err, usecases.ErrPolicyNotFound) && checkRequest.Key != checkRequest.Route {
+			checkRequest.Policy = ""
+			decision, err = s.uc.Check(ctx, checkRequest)
+		}
+
+		if errors.Is(err, usecases.ErrPolicyNotFound) {
+			s.logger.DebugContext(ctx, "descriptor without policy", "domain", request.GetDomain(), "policy", checkRequest.Policy)
+
+			response.Statuses = append(response.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{
+				Code: rlsv3.RateLimitResponse_OK,
+			})
+			continue
+		}
+
+		if err != nil {
+			return nil, status.Error(statusCode(err), err.Error())
+		}
+
+		descriptorStatus := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code:               rlsv3.RateLimitResponse_OK,
+			CurrentLimit:       currentLimit(checkRequest.Policy, decision),
+			LimitRemaining:     uint32(decision.Remaining),
+			DurationUntilReset: durationpb.New(time.Unix(decision.Reset, 0).Sub(s.clock.Now()).Truncate(time.Second)),
+		}
+
+		if !decision.Allowed {
+			descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+
+		if moreRestrictive(decision, selected) {
+			selected = &decision
+		}
+
+		response.Statuses = append(response.Statuses, descriptorStatus)
+	}
+
+	if selected != nil {
+		response.ResponseHeadersToAdd = headerValues(selected.Headers)
+	}
+
+	return response, nil
+}
+
+// moreRestrictive reports if the headers of decision should be sent instead of
+// the selected ones: the first decision over the limit, or the one closest to it
+func moreRestrictive(decision entities.Decision, selected *entities.Decision) bool {
+	switch {
+	case selected == nil:
+		return true
+	case selected.Allowed != decision.Allowed:
+		return !decision.Allowed
+	case decision.Allowed:
+		return decision.Remaining < selected.Remaining
+	default:
+		return false
+	}
+}
+
+// currentLimit returns the limit of the rule of the decision, its unit is
+// unknown when the window of the rule is not a second, minute, hour or day
+func currentLimit(policy string, decision entities.Decision) *rlsv3.RateLimitResponse_RateLimit {
+	limit := &rlsv3.RateLimitResponse_RateLimit{
+		Name:            policy,
+		RequestsPerUnit: uint32(decision.Limit),
+	}
+
+	switch {
+	case decision.Period == rate_limiter.PeriodDay:
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_DAY
+	case decision.Period != "":
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_UNKNOWN
+	case decision.Every == 1:
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_SECOND
+	case decision.Every == 60:
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_MINUTE
+	case decision.Every == 60*60:
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_HOUR
+	case decision.Every == 24*60*60:
+		limit.Unit = rlsv3.RateLimitResponse_RateLimit_DAY
+	}
+
+	return limit
+}
+
+func descriptorCheckRequest(descriptor *ratelimitv3.RateLimitDescriptor, cost int) (entities.CheckRequest, bool) {
+	var ip, token, route string
+
+	for _, entry := range descriptor.GetEntries() {
+		switch entry.GetKey() {
+		case DescriptorIP:
+			ip = entry.GetValue()
+		case DescriptorToken:
+			token = entry.GetValue()
+		case DescriptorRoute:
+			route = entry.GetValue()
+		}
+	}
+
+	request := entities.CheckRequest{
+		Cost:   cost,
+		Policy: route,
+		Route:  route,
+	}
+
+	switch {
+	case token != "":
+		request.Key = token
+	case ip != "":
+		request.Key = ip
+	case route != "":
+		request.Key = route
+	default:
+		return request, false
+	}
+
+	return request, true
+}
+
+func headerValues(headers map[string]string) []*corev3.HeaderValue {
+	keys := make([]string, 0, len(headers))
+
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	values := make([]*corev3.HeaderValue, 0, len(keys))
+
+	for _, key := range keys {
+		values = append(values, &corev3.HeaderValue{
+			Key:   key,
+			Value: headers[key],
+		})
+	}
+
+	return values
+}
@@ -0,0 +1,193 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func descriptor(entries ...string) *ratelimitv3.RateLimitDescriptor {
+	descriptor := &ratelimitv3.RateLimitDescriptor{}
+
+	for i := 0; i < len(entries); i += 2 {
+		descriptor.Entries = append(descriptor.Entries, &ratelimitv3.RateLimitDescriptor_Entry{
+			Key:   entries[i],
+			Value: entries[i+1],
+		})
+	}
+
+	return descriptor
+}
+
+func TestEnvoyRateLimitService_ShouldRateLimit(t *testing.T) {
+	config := config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Requests: 3,
+				Every:    60,
+			},
+			IP: []rate_limiter.IP{
+				{IP: "10.0.0.1", Requests: 1, Every: 60},
+			},
+			Token: []rate_limiter.Token{
+				{Token: "token_1", Requests: 2, Every: 60},
+			},
+			Policy: []rate_limiter.Policy{
+				{Name: "search", Requests: 1, Every: 60},
+			},
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	newService := func() rlsv3.RateLimitServiceServer {
+		uc := usecases.NewRateLimitUseCase(config, strategies.NewRateLimitInMemoryClock(clock), usecases.WithClock(clock))
+
+		return NewEnvoyRateLimitService(uc, logger, WithClock(clock))
+	}
+
+	t.Run("Should limit remote address descriptors with the IP rules", func(t *testing.T) {
+		service := newService()
+		request := &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorIP, "10.0.0.1")},
+		}
+
+		resp, err := service.ShouldRateLimit(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+		assert.Equal(t, uint32(0), resp.GetStatuses()[0].GetLimitRemaining())
+		assert.Equal(t, time.Minute, resp.GetStatuses()[0].GetDurationUntilReset().AsDuration())
+		assert.Equal(t, uint32(1), resp.GetStatuses()[0].GetCurrentLimit().GetRequestsPerUnit())
+		assert.Equal(t, rlsv3.RateLimitResponse_RateLimit_MINUTE, resp.GetStatuses()[0].GetCurrentLimit().GetUnit())
+
+		resp, err = service.ShouldRateLimit(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetStatuses()[0].GetCode())
+	})
+
+	t.Run("Should count the keys apart in each domain", func(t *testing.T) {
+		service := newService()
+
+		resp, err := service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorIP, "10.0.0.1")},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+
+		resp, err = service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Domain:      "internal",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorIP, "10.0.0.1")},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+	})
+
+	t.Run("Should prefer the token entry and consume the hits addend", func(t *testing.T) {
+		service := newService()
+
+		resp, err := service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorIP, "10.0.0.1", DescriptorToken, "token_1")},
+			HitsAddend:  2,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+		assert.Equal(t, uint32(0), resp.GetStatuses()[0].GetLimitRemaining())
+	})
+
+	t.Run("Should use the route entry as policy", func(t *testing.T) {
+		service := newService()
+		request := &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorRoute, "search", DescriptorIP, "10.0.0.2")},
+		}
+
+		resp, err := service.ShouldRateLimit(context.Background(), request)
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+
+		resp, err = service.ShouldRateLimit(context.Background(), request)
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+	})
+
+	t.Run("Should limit the key with its own rule when the route has no policy", func(t *testing.T) {
+		service := newService()
+		request := &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorRoute, "unknown", DescriptorIP, "10.0.0.1")},
+		}
+
+		resp, err := service.ShouldRateLimit(context.Background(), request)
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+		assert.Equal(t, uint32(1), resp.GetStatuses()[0].GetCurrentLimit().GetRequestsPerUnit())
+		assert.Empty(t, resp.GetStatuses()[0].GetCurrentLimit().GetName())
+
+		resp, err = service.ShouldRateLimit(context.Background(), request)
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+	})
+
+	t.Run("Should not limit descriptors without known entries or policy", func(t *testing.T) {
+		service := newService()
+
+		resp, err := service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{
+				descriptor("generic_key", "value"),
+				descriptor(DescriptorRoute, "unknown"),
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetOverallCode())
+		assert.Len(t, resp.GetStatuses(), 2)
+		assert.Empty(t, resp.GetResponseHeadersToAdd())
+	})
+
+	t.Run("Should be over the limit when any descriptor is and send its headers", func(t *testing.T) {
+		service := newService()
+
+		service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor(DescriptorIP, "10.0.0.1")},
+		})
+
+		resp, err := service.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{
+				descriptor(DescriptorIP, "10.0.0.3"),
+				descriptor(DescriptorIP, "10.0.0.1"),
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.GetStatuses()[0].GetCode())
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.GetStatuses()[1].GetCode())
+		assert.Contains(t, resp.GetResponseHeadersToAdd(), &corev3.HeaderValue{Key: "Ratelimit-Remaining", Value: "0"})
+	})
+
+	t.Run("Should return error when descriptors are missing", func(t *testing.T) {
+		_, err := newService().ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
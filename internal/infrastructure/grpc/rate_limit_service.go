@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	ratelimitv1 "github.com/mrangelba/go-exp-rate-limiter/api/ratelimit/v1"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type rateLimitService struct {
+	ratelimitv1.UnimplementedRateLimitServiceServer
+	uc usecases.RateLimitUseCase
+}
+
+func NewRateLimitService(uc usecases.RateLimitUseCase) ratelimitv1.RateLimitServiceServer {
+	return &rateLimitService{
+		uc: uc,
+	}
+}
+
+func (s *rateLimitService) ShouldRateLimit(ctx context.Context, request *ratelimitv1.ShouldRateLimitRequest) (*ratelimitv1.ShouldRateLimitResponse, error) {
+	decision, err := s.uc.Check(ctx, entities.CheckRequest{
+		Key:    request.GetKey(),
+		Cost:   int(request.GetCost()),
+		Policy: request.GetPolicy(),
+	})
+
+	if err != nil {
+		return nil, status.Error(statusCode(err), err.Error())
+	}
+
+	return &ratelimitv1.ShouldRateLimitResponse{
+		Allowed:   decision.Allowed,
+		Remaining: int32(decision.Remaining),
+		ResetAt:   decision.Reset,
+		Headers:   decision.Headers,
+	}, nil
+}
+
+func statusCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, usecases.ErrInvalidKey), errors.Is(err, usecases.ErrInvalidCost):
+		return codes.InvalidArgument
+	case errors.Is(err, usecases.ErrPolicyNotFound):
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
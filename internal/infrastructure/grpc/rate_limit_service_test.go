@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ratelimitv1 "github.com/mrangelba/go-exp-rate-limiter/api/ratelimit/v1"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type mockRateLimitUseCase struct {
+	request  entities.CheckRequest
+	decision entities.Decision
+	err      error
+}
+
+func (m *mockRateLimitUseCase) VerifyLimit(ctx context.Context, key string) bool {
+	return m.decision.Allowed
+}
+
+func (m *mockRateLimitUseCase) GetHttpHeaders(ctx context.Context, key string) map[string]string {
+	return m.decision.Headers
+}
+
+func (m *mockRateLimitUseCase) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	m.request = request
+	return m.decision, m.err
+}
+
+func (m *mockRateLimitUseCase) Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error) {
+	m.request = request
+	return entities.Usage{}, m.err
+}
+
+func TestRateLimitService_ShouldRateLimit(t *testing.T) {
+	t.Run("Should return the decision of the request", func(t *testing.T) {
+		uc := &mockRateLimitUseCase{
+			decision: entities.Decision{
+				Allowed:   false,
+				Remaining: 0,
+				Reset:     1631234567,
+				Headers:   map[string]string{"Ratelimit-Remaining": "0"},
+			},
+		}
+
+		resp, err := NewRateLimitService(uc).ShouldRateLimit(context.Background(), &ratelimitv1.ShouldRateLimitRequest{
+			Key:    "token_1",
+			Cost:   3,
+			Policy: "search",
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, resp.GetAllowed())
+		assert.Equal(t, int32(0), resp.GetRemaining())
+		assert.Equal(t, int64(1631234567), resp.GetResetAt())
+		assert.Equal(t, map[string]string{"Ratelimit-Remaining": "0"}, resp.GetHeaders())
+		assert.Equal(t, entities.CheckRequest{Key: "token_1", Cost: 3, Policy: "search"}, uc.request)
+	})
+
+	t.Run("Should map use case errors to status codes", func(t *testing.T) {
+		for err, code := range map[error]codes.Code{
+			usecases.ErrInvalidKey:     codes.InvalidArgument,
+			usecases.ErrInvalidCost:    codes.InvalidArgument,
+			usecases.ErrPolicyNotFound: codes.NotFound,
+			errors.New("unexpected"):   codes.Internal,
+		} {
+			_, rpcErr := NewRateLimitService(&mockRateLimitUseCase{err: err}).ShouldRateLimit(context.Background(), &ratelimitv1.ShouldRateLimitRequest{})
+
+			assert.Equal(t, code, status.Code(rpcErr))
+		}
+	})
+}
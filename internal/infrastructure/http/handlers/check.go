@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+)
+
+// maxCheckBodySize is the largest body of a CheckRequest
+const maxCheckBodySize = 64 << 10
+
+type checkHandler struct {
+	uc usecases.RateLimitUseCase
+}
+
+// NewCheckHandler returns the handler of POST /v1/check, it answers the
+// decision of a CheckRequest in JSON
+func NewCheckHandler(uc usecases.RateLimitUseCase) http.Handler {
+	return &checkHandler{
+		uc: uc,
+	}
+}
+
+func (h *checkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var request entities.CheckRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCheckBodySize)).Decode(&request); err != nil {
+		var maxBytesErr *http.MaxBytesError
+
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return
+		}
+
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	decision, err := h.uc.Check(r.Context(), request)
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decision)
+}
+
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, usecases.ErrInvalidKey), errors.Is(err, usecases.ErrInvalidCost):
+		return http.StatusBadRequest
+	case errors.Is(err, usecases.ErrPolicyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRateLimitUseCase struct {
+	request  entities.CheckRequest
+	decision entities.Decision
+	usage    entities.Usage
+	err      error
+}
+
+func (m *mockRateLimitUseCase) VerifyLimit(ctx context.Context, key string) bool {
+	return m.decision.Allowed
+}
+
+func (m *mockRateLimitUseCase) GetHttpHeaders(ctx context.Context, key string) map[string]string {
+	return m.decision.Headers
+}
+
+func (m *mockRateLimitUseCase) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	m.request = request
+	return m.decision, m.err
+}
+
+func (m *mockRateLimitUseCase) Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error) {
+	m.request = request
+	return m.usage, m.err
+}
+
+func TestCheckHandler(t *testing.T) {
+	t.Run("Should return the decision of the request", func(t *testing.T) {
+		uc := &mockRateLimitUseCase{
+			decision: entities.Decision{
+				Allowed:   true,
+				Remaining: 9,
+				Reset:     1631234567,
+				Headers:   map[string]string{"Ratelimit-Remaining": "9"},
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/check", strings.NewReader(`{"key":"token_1","cost":2,"policy":"search"}`))
+		rr := httptest.NewRecorder()
+
+		NewCheckHandler(uc).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"allowed":true,"remaining":9,"reset":1631234567,"headers":{"Ratelimit-Remaining":"9"}}`, rr.Body.String())
+		assert.Equal(t, entities.CheckRequest{Key: "token_1", Cost: 2, Policy: "search"}, uc.request)
+	})
+
+	t.Run("Should return 405 when method is not POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/check", nil)
+		rr := httptest.NewRecorder()
+
+		NewCheckHandler(&mockRateLimitUseCase{}).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+		assert.Equal(t, http.MethodPost, rr.Header().Get("Allow"))
+	})
+
+	t.Run("Should return 400 when body is invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/check", strings.NewReader(`{"key":`))
+		rr := httptest.NewRecorder()
+
+		NewCheckHandler(&mockRateLimitUseCase{}).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"error":"invalid request body"}`, rr.Body.String())
+	})
+
+	t.Run("Should return 413 when body is too large", func(t *testing.T) {
+		body := `{"key":"` + strings.Repeat("a", maxCheckBodySize) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/check", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		NewCheckHandler(&mockRateLimitUseCase{}).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		assert.JSONEq(t, `{"error":"request body too large"}`, rr.Body.String())
+	})
+
+	t.Run("Should map use case errors to status codes", func(t *testing.T) {
+		for err, status := range map[error]int{
+			usecases.ErrInvalidKey:     http.StatusBadRequest,
+			usecases.ErrInvalidCost:    http.StatusBadRequest,
+			usecases.ErrPolicyNotFound: http.StatusNotFound,
+			errors.New("unexpected"):   http.StatusInternalServerError,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/v1/check", strings.NewReader(`{"key":"token_1"}`))
+			rr := httptest.NewRecorder()
+
+			NewCheckHandler(&mockRateLimitUseCase{err: err}).ServeHTTP(rr, req)
+
+			assert.Equal(t, status, rr.Code)
+			assert.JSONEq(t, `{"error":"`+err.Error()+`"}`, rr.Body.String())
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/proxy"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/middlewares"
+)
+
+// NewProxyHandler returns a reverse proxy to the upstreams, each one behind
+// the rate limit middleware with its policy. Requests are routed by the
+// longest upstream path and the rate limit headers are sent with the
+// upstream response. The options configure the middleware of every upstream
+func NewProxyHandler(uc usecases.RateLimitUseCase, upstreams []proxy.Upstream, logger *slog.Logger, mwOpts ...middlewares.Option) (http.Handler, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream configured")
+	}
+
+	mux := http.NewServeMux()
+	paths := make(map[string]bool, len(upstreams))
+
+	for _, upstream := range upstreams {
+		target, err := url.Parse(upstream.URL)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("invalid upstream url %q", upstream.URL)
+		}
+
+		path := upstream.Path
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid upstream path %q", upstream.Path)
+		}
+
+		if paths[path] {
+			return nil, fmt.Errorf("duplicate upstream path %q", upstream.Path)
+		}
+
+		paths[path] = true
+
+		opts := append([]middlewares.Option{}, mwOpts...)
+
+		if upstream.Policy != "" {
+			opts = append(opts, middlewares.WithPolicy(upstream.Policy))
+		}
+
+		mux.Handle(path, middlewares.NewRateLimiter(uc, opts...).Handler(newReverseProxy(target, logger)))
+	}
+
+	return mux, nil
+}
+
+func newReverseProxy(target *url.URL, logger *slog.Logger) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.ErrorContext(r.Context(), "failed to proxy request", "upstream", target.String(), "error", err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/proxy"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.Header().Set("X-Upstream-Forwarded-For", r.Header.Get("X-Forwarded-For"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	newHandler := func(t *testing.T, upstreams ...proxy.Upstream) http.Handler {
+		uc := usecases.NewRateLimitUseCase(config.Config{
+			RateLimiter: rate_limiter.RateLimiterConfig{
+				Default: rate_limiter.Default{Requests: 2, Every: 60},
+				Policy: []rate_limiter.Policy{
+					{Name: "search", Requests: 1, Every: 60},
+				},
+			},
+		}, strategies.NewRateLimitInMemory())
+
+		handler, err := NewProxyHandler(uc, upstreams, logger)
+		assert.NoError(t, err)
+
+		return handler
+	}
+
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should proxy the request with the rate limit headers", func(t *testing.T) {
+		handler := newHandler(t, proxy.Upstream{URL: upstream.URL, Path: "/"})
+
+		rr := serve(handler, "/users")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "/users", rr.Header().Get("X-Upstream-Path"))
+		assert.Equal(t, "10.0.0.1", rr.Header().Get("X-Upstream-Forwarded-For"))
+		assert.Equal(t, "1", rr.Header().Get("Ratelimit-Remaining"))
+	})
+
+	t.Run("Should return 429 Too Many Requests without calling the upstream", func(t *testing.T) {
+		handler := newHandler(t, proxy.Upstream{URL: upstream.URL, Path: "/"})
+
+		serve(handler, "/")
+		serve(handler, "/")
+		rr := serve(handler, "/")
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Empty(t, rr.Header().Get("X-Upstream-Path"))
+		assert.Equal(t, "0", rr.Header().Get("Ratelimit-Remaining"))
+	})
+
+	t.Run("Should limit each upstream with its own rule", func(t *testing.T) {
+		handler := newHandler(t,
+			proxy.Upstream{URL: upstream.URL, Path: "/"},
+			proxy.Upstream{URL: upstream.URL, Path: "/search", Policy: "search"},
+		)
+
+		assert.Equal(t, http.StatusOK, serve(handler, "/search/items").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/search/items").Code)
+		assert.Equal(t, http.StatusOK, serve(handler, "/users").Code)
+	})
+
+	t.Run("Should return 502 Bad Gateway when the upstream is unavailable", func(t *testing.T) {
+		unavailable := httptest.NewServer(http.NotFoundHandler())
+		unavailable.Close()
+
+		handler := newHandler(t, proxy.Upstream{URL: unavailable.URL, Path: "/"})
+
+		assert.Equal(t, http.StatusBadGateway, serve(handler, "/").Code)
+	})
+
+	t.Run("Should return error when the upstreams are invalid", func(t *testing.T) {
+		uc := usecases.NewRateLimitUseCase(config.Config{}, strategies.NewRateLimitInMemory())
+
+		_, err := NewProxyHandler(uc, nil, logger)
+		assert.Error(t, err)
+
+		_, err = NewProxyHandler(uc, []proxy.Upstream{{URL: "api:8080", Path: "/"}}, logger)
+		assert.Error(t, err)
+
+		_, err = NewProxyHandler(uc, []proxy.Upstream{{URL: "http://api:8080", Path: "api"}}, logger)
+		assert.Error(t, err)
+	})
+
+	t.Run("Should return error when the upstream paths are duplicated", func(t *testing.T) {
+		uc := usecases.NewRateLimitUseCase(config.Config{}, strategies.NewRateLimitInMemory())
+
+		_, err := NewProxyHandler(uc, []proxy.Upstream{
+			{URL: "http://api:8080", Path: "/api"},
+			{URL: "http://other:8080", Path: "/api/"},
+		}, logger)
+		assert.ErrorContains(t, err, "duplicate upstream path")
+	})
+}
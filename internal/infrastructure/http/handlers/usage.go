@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+)
+
+type usageHandler struct {
+	uc     usecases.RateLimitUseCase
+	tokens map[string]bool
+}
+
+// NewUsageHandler returns the handler of GET /v1/usage?policy=, it answers
+// the usage of the token of the API_KEY header in the current window in JSON,
+// without counting a request. Only the configured tokens are answered, so a
+// client can read only its own usage
+func NewUsageHandler(uc usecases.RateLimitUseCase, tokens []rate_limiter.Token) http.Handler {
+	h := &usageHandler{
+		uc:     uc,
+		tokens: make(map[string]bool, len(tokens)),
+	}
+
+	for _, token := range tokens {
+		h.tokens[token.Token] = true
+	}
+
+	return h
+}
+
+func (h *usageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	token := r.Header.Get("API_KEY")
+	if !h.tokens[token] {
+		writeError(w, http.StatusUnauthorized, errors.New("a valid API_KEY header is required"))
+		return
+	}
+
+	usage, err := h.uc.Usage(r.Context(), entities.CheckRequest{
+		Key:    token,
+		Policy: r.URL.Query().Get("policy"),
+	})
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageHandler(t *testing.T) {
+	tokens := []rate_limiter.Token{{Token: "token_1"}}
+
+	t.Run("Should return the usage of the key", func(t *testing.T) {
+		uc := &mockRateLimitUseCase{
+			usage: entities.Usage{
+				Key:       "token_1",
+				Policy:    "search",
+				Rule:      "policy_search",
+				Period:    "month",
+				Limit:     1000,
+				Used:      400,
+				Remaining: 600,
+				Reset:     1711929600,
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/usage?policy=search", nil)
+		req.Header.Set("API_KEY", "token_1")
+		rr := httptest.NewRecorder()
+
+		NewUsageHandler(uc, tokens).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"key":"token_1","policy":"search","rule":"policy_search","period":"month","limit":1000,"used":400,"remaining":600,"reset":1711929600}`, rr.Body.String())
+		assert.Equal(t, entities.CheckRequest{Key: "token_1", Policy: "search"}, uc.request)
+	})
+
+	t.Run("Should return 405 when method is not GET", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/usage", nil)
+		rr := httptest.NewRecorder()
+
+		NewUsageHandler(&mockRateLimitUseCase{}, tokens).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+		assert.Equal(t, http.MethodGet, rr.Header().Get("Allow"))
+	})
+
+	t.Run("Should return 401 when the token is missing or not configured", func(t *testing.T) {
+		for _, token := range []string{"", "token_2"} {
+			uc := &mockRateLimitUseCase{}
+			req := httptest.NewRequest(http.MethodGet, "/v1/usage?key=token_1", nil)
+			req.Header.Set("API_KEY", token)
+			rr := httptest.NewRecorder()
+
+			NewUsageHandler(uc, tokens).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.JSONEq(t, `{"error":"a valid API_KEY header is required"}`, rr.Body.String())
+			assert.Empty(t, uc.request.Key)
+		}
+	})
+
+	t.Run("Should return 404 when the policy is not configured", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/usage?policy=unknown", nil)
+		req.Header.Set("API_KEY", "token_1")
+		rr := httptest.NewRecorder()
+
+		NewUsageHandler(&mockRateLimitUseCase{err: usecases.ErrPolicyNotFound}, tokens).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/shedding"
+)
+
+type priorityClass struct {
+	shedding.Class
+	limit int
+}
+
+type loadShedder struct {
+	header       string
+	exempt       []string
+	classes      []priorityClass
+	defaultClass *priorityClass
+	plans        map[string]string
+	logger       *slog.Logger
+	mutex        sync.Mutex
+	inFlight     int
+}
+
+// NewLoadShedder returns the middleware that sheds the requests of a class
+// when the requests in flight reach its share of the capacity, so the
+// classes with lower shares are shed first. The plans of the classes are
+// matched against the plans of the tokens
+func NewLoadShedder(cfg shedding.SheddingConfig, tokens []rate_limiter.Token, logger *slog.Logger) (*loadShedder, error) {
+	if len(cfg.Class) == 0 {
+		return nil, errors.New("load shedding has no priority class")
+	}
+
+	if cfg.Capacity <= 0 {
+		return nil, fmt.Errorf("invalid load shedding capacity %d", cfg.Capacity)
+	}
+
+	m := &loadShedder{
+		header: cfg.Header,
+		exempt: cfg.Exempt,
+		plans:  make(map[string]string),
+		logger: logger,
+	}
+
+	for _, class := range cfg.Class {
+		if class.Share <= 0 || class.Share > 100 {
+			return nil, fmt.Errorf("invalid share %d of the priority class %q", class.Share, class.Name)
+		}
+
+		m.classes = append(m.classes, priorityClass{
+			Class: class,
+			limit: max(cfg.Capacity*class.Share/100, 1),
+		})
+	}
+
+	if cfg.DefaultClass == "" {
+		m.defaultClass = &m.classes[0]
+
+		for i := range m.classes {
+			if m.classes[i].Share < m.defaultClass.Share {
+				m.defaultClass = &m.classes[i]
+			}
+		}
+	}
+
+	for i := range m.classes {
+		if m.classes[i].Name == cfg.DefaultClass {
+			m.defaultClass = &m.classes[i]
+		}
+	}
+
+	if m.defaultClass == nil {
+		return nil, fmt.Errorf("unknown default priority class %q", cfg.DefaultClass)
+	}
+
+	for _, token := range tokens {
+		if token.Plan != "" {
+			m.plans[token.Token] = token.Plan
+		}
+	}
+
+	return m, nil
+}
+
+func (m *loadShedder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.isExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		class := m.classify(r)
+
+		if !m.acquire(class) {
+			m.logger.DebugContext(r.Context(), "shedding request", "class", class.Name, "path", r.URL.Path)
+
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "the service is overloaded, try again later", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		defer m.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *loadShedder) isExempt(path string) bool {
+	for _, prefix := range m.exempt {
+		if domain.MatchRoute(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classify returns the first class matching the route, the priority header
+// or the plan of the token of the request, or the default class. The
+// priority header is trusted as is, so it must be set by the edge in front
+// of the service and never come from the clients
+func (m *loadShedder) classify(r *http.Request) *priorityClass {
+	priority := r.Header.Get(m.header)
+	plan := m.plans[r.Header.Get("API_KEY")]
+
+	for i := range m.classes {
+		class := &m.classes[i]
+
+		for _, route := range class.Routes {
+			if domain.MatchRoute(r.URL.Path, route) {
+				return class
+			}
+		}
+
+		if priority != "" && slices.Contains(class.Headers, priority) {
+			return class
+		}
+
+		if plan != "" && slices.Contains(class.Plans, plan) {
+			return class
+		}
+	}
+
+	return m.defaultClass
+}
+
+func (m *loadShedder) acquire(class *priorityClass) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.inFlight >= class.limit {
+		return false
+	}
+
+	m.inFlight++
+
+	return true
+}
+
+func (m *loadShedder) release() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.inFlight--
+}
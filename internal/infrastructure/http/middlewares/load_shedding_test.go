@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/shedding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedder(t *testing.T) {
+	cfg := shedding.SheddingConfig{
+		Capacity: 10,
+		Header:   "X-Priority",
+		Exempt:   []string{"/healthz", "/admin/"},
+		Class: []shedding.Class{
+			{Name: "critical", Share: 100, Routes: []string{"/checkout"}, Plans: []string{"enterprise"}},
+			{Name: "standard", Share: 50},
+			{Name: "batch", Share: 20, Headers: []string{"low"}},
+		},
+	}
+	tokens := []rate_limiter.Token{{Token: "abc", Plan: "enterprise"}}
+
+	t.Run("Should return an error for invalid configs", func(t *testing.T) {
+		_, err := NewLoadShedder(shedding.SheddingConfig{Capacity: 10}, nil, slog.Default())
+		assert.Error(t, err)
+
+		invalid := cfg
+		invalid.DefaultClass = "unknown"
+		_, err = NewLoadShedder(invalid, nil, slog.Default())
+		assert.Error(t, err)
+
+		invalid = cfg
+		invalid.Capacity = 0
+		_, err = NewLoadShedder(invalid, nil, slog.Default())
+		assert.Error(t, err)
+	})
+
+	t.Run("Should classify by route, header and token plan", func(t *testing.T) {
+		withDefault := cfg
+		withDefault.DefaultClass = "standard"
+
+		m, err := NewLoadShedder(withDefault, tokens, slog.Default())
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/checkout/cart", nil)
+		assert.Equal(t, "critical", m.classify(req).Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("API_KEY", "abc")
+		assert.Equal(t, "critical", m.classify(req).Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Priority", "low")
+		assert.Equal(t, "batch", m.classify(req).Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Equal(t, "standard", m.classify(req).Name)
+	})
+
+	t.Run("Should match the routes on path segments", func(t *testing.T) {
+		m, err := NewLoadShedder(cfg, nil, slog.Default())
+		assert.NoError(t, err)
+
+		assert.True(t, m.isExempt("/healthz"))
+		assert.True(t, m.isExempt("/admin"))
+		assert.True(t, m.isExempt("/admin/keys"))
+		assert.True(t, m.isExempt("/search/../admin/keys"))
+		assert.False(t, m.isExempt("/healthzz"))
+		assert.False(t, m.isExempt("/administrator"))
+		assert.False(t, m.isExempt("/admin/../search"))
+
+		req := httptest.NewRequest(http.MethodGet, "/checkouts", nil)
+		assert.Equal(t, "batch", m.classify(req).Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/checkout/../search", nil)
+		assert.Equal(t, "batch", m.classify(req).Name)
+	})
+
+	t.Run("Should use the class with the lowest share without a default class", func(t *testing.T) {
+		m, err := NewLoadShedder(cfg, nil, slog.Default())
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Equal(t, "batch", m.classify(req).Name)
+	})
+
+	t.Run("Should shed the lower classes first", func(t *testing.T) {
+		m, err := NewLoadShedder(cfg, tokens, slog.Default())
+		assert.NoError(t, err)
+
+		m.inFlight = 2
+
+		handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		serve := func(path, priority string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-Priority", priority)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			return rr
+		}
+
+		rr := serve("/", "low")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, serve("/checkout", "").Code)
+
+		m.inFlight = 10
+
+		assert.Equal(t, http.StatusServiceUnavailable, serve("/checkout", "").Code)
+		assert.Equal(t, http.StatusOK, serve("/healthz", "low").Code)
+		assert.Equal(t, http.StatusOK, serve("/admin/keys", "low").Code)
+		assert.Equal(t, 10, m.inFlight)
+	})
+}
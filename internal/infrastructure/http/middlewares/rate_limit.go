@@ -5,20 +5,119 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/middlewares"
+
+// minDelay is the shortest wait of a delayed request before it is checked
+// again, for the windows that reset in the current second
+const minDelay = 100 * time.Millisecond
+
+// resetDelay is waited after the reset of a window, which is truncated to
+// the second, so the window has expired when the request is checked again
+const resetDelay = time.Second
+
+// waiter waits for d, returning false when ctx is done first
+type waiter func(ctx context.Context, d time.Duration) bool
+
 type rateLimiter struct {
-	uc    usecases.RateLimitUseCase
-	mutex *sync.Mutex
+	uc       usecases.RateLimitUseCase
+	mutex    *sync.Mutex
+	tracer   trace.Tracer
+	policy   string
+	observer Observer
+	clock    domain.Clock
+	wait     waiter
+
+	maxWait    time.Duration
+	queueSize  int
+	delayKeys  map[string]struct{}
+	queueMutex sync.Mutex
+	queues     map[string]int
+}
+
+// Observer receives the latency of each request allowed by the middleware
+// and if it failed, answered with a 5xx status
+type Observer interface {
+	Observe(latency time.Duration, failed bool)
+}
+
+// Option configures optional dependencies of the rate limit middleware
+type Option func(*rateLimiter)
+
+// WithTracerProvider sets the provider used to trace limit checks,
+// the global provider is used when it is not informed
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(m *rateLimiter) {
+		m.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithPolicy limits the requests with the rule of the named policy instead
+// of the IP and token rules, the policy keeps its own counters
+func WithPolicy(name string) Option {
+	return func(m *rateLimiter) {
+		m.policy = name
+	}
+}
+
+// WithObserver reports the latency and failures of the requests allowed to
+// observer, such as the adaptive limits controller
+func WithObserver(observer Observer) Option {
+	return func(m *rateLimiter) {
+		m.observer = observer
+	}
+}
+
+// WithClock sets the clock of the reset of the windows that delayed requests
+// wait for and of the latency reported to the observer, the system clock is
+// used when it is not informed
+func WithClock(clock domain.Clock) Option {
+	return func(m *rateLimiter) {
+		m.clock = clock
+	}
+}
+
+// WithDelay makes the requests of keys over their limit wait for the window
+// to reset instead of being rejected, while the wait is at most maxWait and
+// fewer than queueSize requests of the key are waiting. A wait ends when the
+// context of the request is done
+func WithDelay(maxWait time.Duration, queueSize int, keys ...string) Option {
+	return func(m *rateLimiter) {
+		m.maxWait = maxWait
+		m.queueSize = queueSize
+
+		for _, key := range keys {
+			m.delayKeys[key] = struct{}{}
+		}
+	}
 }
 
-func NewRateLimiter(uc usecases.RateLimitUseCase) *rateLimiter {
-	return &rateLimiter{
-		uc:    uc,
-		mutex: &sync.Mutex{},
+func NewRateLimiter(uc usecases.RateLimitUseCase, opts ...Option) *rateLimiter {
+	m := &rateLimiter{
+		uc:        uc,
+		mutex:     &sync.Mutex{},
+		tracer:    otel.GetTracerProvider().Tracer(tracerName),
+		clock:     domain.SystemClock,
+		wait:      waitTimer,
+		delayKeys: make(map[string]struct{}),
+		queues:    make(map[string]int),
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	return m
 }
 
 func (m *rateLimiter) Handler(next http.Handler) http.Handler {
@@ -26,40 +125,198 @@ func (m *rateLimiter) Handler(next http.Handler) http.Handler {
 		apiKey := r.Header.Get("API_KEY")
 
 		if apiKey != "" {
-			if !m.checkLimitAddHeaders(r.Context(), w, apiKey) {
+			if !m.checkLimitAddHeaders(r.Context(), w, "token", apiKey, r.URL.Path) {
 				return
 			}
 		} else {
 			ips := getIPs(r)
 
-			for _, ip := range ips {
-				if !m.checkLimitAddHeaders(r.Context(), w, ip) {
+			for i, ip := range ips {
+				// the global rules are checked once, with the first IP
+				route := r.URL.Path
+				if i > 0 {
+					route = ""
+				}
+
+				if !m.checkLimitAddHeaders(r.Context(), w, "ip", ip, route) {
 					return
 				}
 			}
 		}
 
-		next.ServeHTTP(w, r)
+		if m.observer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := m.clock.Now()
+
+		next.ServeHTTP(recorder, r)
+
+		m.observer.Observe(m.clock.Now().Sub(start), recorder.status >= http.StatusInternalServerError)
 	})
 }
 
-func (m *rateLimiter) checkLimitAddHeaders(ctx context.Context, w http.ResponseWriter, ip string) bool {
-	m.mutex.Lock()
+// statusRecorder keeps the status written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
 
-	defer m.mutex.Unlock()
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the writer of the handler, for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (m *rateLimiter) checkLimitAddHeaders(ctx context.Context, w http.ResponseWriter, keyType, key, route string) bool {
+	ctx, span := m.tracer.Start(ctx, "rate_limit.check",
+		trace.WithAttributes(attribute.String("rate_limit.key_type", keyType)),
+	)
+	defer span.End()
+
+	var decision entities.Decision
+	var err error
+
+	if m.delays(key) {
+		decision, err = m.delay(ctx, span, key, route)
+	} else {
+		decision, err = m.verify(ctx, key, route, false)
+	}
+
+	hasLimit, headers := decision.Allowed, decision.Headers
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "failed to check the rate limit", http.StatusInternalServerError)
 
-	hasLimit := m.uc.VerifyLimit(ctx, ip)
-	headers := m.uc.GetHttpHeaders(ctx, ip)
+		return false
+	}
 
-	for key, value := range headers {
-		w.Header().Add(key, value)
+	for name, value := range headers {
+		w.Header().Add(name, value)
 	}
 
 	if !hasLimit {
+		span.SetAttributes(attribute.String("rate_limit.outcome", "limited"))
 		http.Error(w, "you have reached the maximum number of requests or actions allowed within a certain time frame", http.StatusTooManyRequests)
+
+		return false
+	}
+
+	span.SetAttributes(attribute.String("rate_limit.outcome", "allowed"))
+
+	return true
+}
+
+// verify checks the key under the policy, or under its own rule when there
+// is no policy, and the global rules of the route in the same decision. The
+// rejection of a retry is not recorded
+func (m *rateLimiter) verify(ctx context.Context, key, route string, retry bool) (entities.Decision, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.uc.Check(ctx, entities.CheckRequest{
+		Key:    key,
+		Policy: m.policy,
+		Route:  route,
+		Retry:  retry,
+	})
+}
+
+func (m *rateLimiter) delays(key string) bool {
+	_, ok := m.delayKeys[key]
+
+	return ok
+}
+
+// delay checks the key and, while it is rejected, waits for the reset of
+// the window and checks it again. The checks are retries, so the waits are
+// not recorded as rejections, and when the next wait would end after the max
+// wait, the queue of the key is full or ctx is done the key is checked a last
+// time as a normal request
+func (m *rateLimiter) delay(ctx context.Context, span trace.Span, key, route string) (entities.Decision, error) {
+	if !m.enqueue(key) {
+		span.AddEvent("rate_limit.delay_queue_full")
+
+		return m.verify(ctx, key, route, false)
+	}
+
+	defer m.dequeue(key)
+
+	var waited time.Duration
+
+	for {
+		decision, err := m.verify(ctx, key, route, true)
+		if err != nil || decision.Allowed {
+			if waited > 0 {
+				span.SetAttributes(attribute.Int64("rate_limit.delay_ms", waited.Milliseconds()))
+			}
+
+			return decision, err
+		}
+
+		wait := max(time.Unix(decision.Reset, 0).Add(resetDelay).Sub(m.clock.Now()), minDelay)
+
+		if waited+wait > m.maxWait {
+			break
+		}
+
+		if !m.wait(ctx, wait) {
+			span.AddEvent("rate_limit.delay_canceled")
+
+			// the rejection is recorded even when the client gave up
+			ctx = context.WithoutCancel(ctx)
+
+			break
+		}
+
+		waited += wait
 	}
 
-	return hasLimit
+	return m.verify(ctx, key, route, false)
+}
+
+// waitTimer waits for d with a timer
+func waitTimer(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// enqueue reserves a place in the queue of the key, if it is not full
+func (m *rateLimiter) enqueue(key string) bool {
+	m.queueMutex.Lock()
+	defer m.queueMutex.Unlock()
+
+	if m.queues[key] >= m.queueSize {
+		return false
+	}
+
+	m.queues[key]++
+
+	return true
+}
+
+func (m *rateLimiter) dequeue(key string) {
+	m.queueMutex.Lock()
+	defer m.queueMutex.Unlock()
+
+	if m.queues[key]--; m.queues[key] <= 0 {
+		delete(m.queues, key)
+	}
 }
 
 func getIPs(r *http.Request) []string {
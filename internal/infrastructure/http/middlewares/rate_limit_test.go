@@ -4,9 +4,17 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type mockRateLimitUseCase struct{}
@@ -24,6 +32,16 @@ func (m *mockRateLimitUseCase) GetHttpHeaders(ctx context.Context, key string) m
 	}
 }
 
+func (m *mockRateLimitUseCase) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	// Mock implementation
+	return entities.Decision{Allowed: true, Headers: m.GetHttpHeaders(ctx, request.Key)}, nil
+}
+
+func (m *mockRateLimitUseCase) Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error) {
+	// Mock implementation
+	return entities.Usage{Key: request.Key}, nil
+}
+
 type mockRateLimitUseCaseError struct{}
 
 func (m *mockRateLimitUseCaseError) VerifyLimit(ctx context.Context, key string) bool {
@@ -39,6 +57,16 @@ func (m *mockRateLimitUseCaseError) GetHttpHeaders(ctx context.Context, key stri
 	}
 }
 
+func (m *mockRateLimitUseCaseError) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	// Mock implementation
+	return entities.Decision{Allowed: false, Headers: m.GetHttpHeaders(ctx, request.Key)}, nil
+}
+
+func (m *mockRateLimitUseCaseError) Usage(ctx context.Context, request entities.CheckRequest) (entities.Usage, error) {
+	// Mock implementation
+	return entities.Usage{Key: request.Key}, nil
+}
+
 func TestRateLimiter_Handler(t *testing.T) {
 	uc := &mockRateLimitUseCase{}
 	rl := NewRateLimiter(uc)
@@ -138,3 +166,335 @@ func TestRateLimiter_Handler(t *testing.T) {
 		assert.Equal(t, "50", rr.Header().Get("X-RateLimit-Remaining"))
 	})
 }
+
+func TestRateLimiter_Handler_Tracing(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Should record a span with key type and outcome for API_KEY", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		rl := NewRateLimiter(&mockRateLimitUseCase{}, WithTracerProvider(provider))
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+		req.Header.Set("API_KEY", "test_key")
+
+		rl.Handler(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "rate_limit.check", spans[0].Name)
+		assert.Contains(t, spans[0].Attributes, attribute.String("rate_limit.key_type", "token"))
+		assert.Contains(t, spans[0].Attributes, attribute.String("rate_limit.outcome", "allowed"))
+	})
+
+	t.Run("Should record a span with key type and outcome for IP", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		rl := NewRateLimiter(&mockRateLimitUseCaseError{}, WithTracerProvider(provider))
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+
+		rl.Handler(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.Contains(t, spans[0].Attributes, attribute.String("rate_limit.key_type", "ip"))
+		assert.Contains(t, spans[0].Attributes, attribute.String("rate_limit.outcome", "limited"))
+	})
+}
+
+type mockRateLimitUseCasePolicy struct {
+	mockRateLimitUseCase
+	request  entities.CheckRequest
+	decision entities.Decision
+	err      error
+}
+
+func (m *mockRateLimitUseCasePolicy) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	m.request = request
+
+	return m.decision, m.err
+}
+
+func TestRateLimiter_Handler_Policy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Should check the key with the policy and add its headers", func(t *testing.T) {
+		uc := &mockRateLimitUseCasePolicy{
+			decision: entities.Decision{Allowed: true, Headers: map[string]string{"Ratelimit-Remaining": "9"}},
+		}
+		rl := NewRateLimiter(uc, WithPolicy("search"))
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+		req.Header.Set("API_KEY", "test_key")
+
+		rr := httptest.NewRecorder()
+
+		rl.Handler(handler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, entities.CheckRequest{Key: "test_key", Policy: "search", Route: "/"}, uc.request)
+		assert.Equal(t, "9", rr.Header().Get("Ratelimit-Remaining"))
+	})
+
+	t.Run("Should return 429 Too Many Requests when the policy limit is reached", func(t *testing.T) {
+		uc := &mockRateLimitUseCasePolicy{
+			decision: entities.Decision{Allowed: false},
+		}
+		rl := NewRateLimiter(uc, WithPolicy("search"))
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+
+		rl.Handler(handler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("Should return 500 Internal Server Error when the check fails", func(t *testing.T) {
+		uc := &mockRateLimitUseCasePolicy{
+			err: usecases.ErrPolicyNotFound,
+		}
+		rl := NewRateLimiter(uc, WithPolicy("unknown"))
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+
+		rl.Handler(handler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+type mockRateLimitUseCaseRequests struct {
+	mockRateLimitUseCase
+	requests []entities.CheckRequest
+}
+
+func (m *mockRateLimitUseCaseRequests) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	m.requests = append(m.requests, request)
+
+	return entities.Decision{Allowed: true, Headers: map[string]string{"Ratelimit-Global-Remaining": "99"}}, nil
+}
+
+func TestRateLimiter_Handler_Global(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Should check the route of the request with the key", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseRequests{}
+		rl := NewRateLimiter(uc)
+
+		req := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+		req.Header.Set("API_KEY", "test_key")
+
+		rr := httptest.NewRecorder()
+
+		rl.Handler(handler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []entities.CheckRequest{{Key: "test_key", Route: "/search"}}, uc.requests)
+		assert.Equal(t, "99", rr.Header().Get("Ratelimit-Global-Remaining"))
+	})
+
+	t.Run("Should check the route only with the first IP", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseRequests{}
+		rl := NewRateLimiter(uc)
+
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1,10.0.0.2")
+
+		rl.Handler(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, []entities.CheckRequest{
+			{Key: "10.0.0.1", Route: "/search"},
+			{Key: "10.0.0.2"},
+		}, uc.requests)
+	})
+}
+
+type observer struct {
+	latencies []time.Duration
+	failures  []bool
+}
+
+func (o *observer) Observe(latency time.Duration, failed bool) {
+	o.latencies = append(o.latencies, latency)
+	o.failures = append(o.failures, failed)
+}
+
+func TestRateLimiter_Handler_Observer(t *testing.T) {
+	t.Run("Should report the latency and failures of the requests allowed", func(t *testing.T) {
+		obs := &observer{}
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		status := http.StatusOK
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clock.Advance(time.Duration(status) * time.Millisecond)
+			w.WriteHeader(status)
+		})
+		rl := NewRateLimiter(&mockRateLimitUseCase{}, WithObserver(obs), WithClock(clock))
+
+		for _, code := range []int{http.StatusOK, http.StatusNotFound, http.StatusServiceUnavailable} {
+			status = code
+			rr := httptest.NewRecorder()
+
+			rl.Handler(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, code, rr.Code)
+		}
+
+		assert.Equal(t, []bool{false, false, true}, obs.failures)
+		assert.Equal(t, []time.Duration{200 * time.Millisecond, 404 * time.Millisecond, 503 * time.Millisecond}, obs.latencies)
+	})
+
+	t.Run("Should not report the requests rejected", func(t *testing.T) {
+		obs := &observer{}
+		rl := NewRateLimiter(&mockRateLimitUseCaseError{}, WithObserver(obs))
+
+		rr := httptest.NewRecorder()
+		rl.Handler(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Empty(t, obs.latencies)
+	})
+}
+
+type mockRateLimitUseCaseDelay struct {
+	mockRateLimitUseCase
+	mutex    sync.Mutex
+	rejected int
+	reset    int64
+	requests int
+	retries  int
+}
+
+func (m *mockRateLimitUseCaseDelay) Check(ctx context.Context, request entities.CheckRequest) (entities.Decision, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests++
+
+	if request.Retry {
+		m.retries++
+	}
+
+	return entities.Decision{Allowed: m.requests > m.rejected, Reset: m.reset}, nil
+}
+
+// fakeWaiter advances the clock instead of sleeping, or stops the wait when
+// canceled is set
+type fakeWaiter struct {
+	clock    *ratelimittest.Clock
+	canceled bool
+	waits    []time.Duration
+}
+
+func (w *fakeWaiter) wait(ctx context.Context, d time.Duration) bool {
+	if w.canceled {
+		return false
+	}
+
+	w.waits = append(w.waits, d)
+	w.clock.Advance(d)
+
+	return true
+}
+
+func TestRateLimiter_Handler_Delay(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newRateLimiter := func(uc usecases.RateLimitUseCase, maxWait time.Duration, key string) (*rateLimiter, *fakeWaiter) {
+		waiter := &fakeWaiter{clock: ratelimittest.NewClock(now)}
+		rl := NewRateLimiter(uc, WithClock(waiter.clock), WithDelay(maxWait, 1, key))
+		rl.wait = waiter.wait
+
+		return rl, waiter
+	}
+
+	serve := func(rl *rateLimiter) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("API_KEY", "batch")
+
+		rr := httptest.NewRecorder()
+		rl.Handler(handler).ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should wait for the window to reset and allow the request", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseDelay{rejected: 2, reset: now.Unix()}
+		rl, waiter := newRateLimiter(uc, 5*time.Second, "batch")
+
+		rr := serve(rl)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 3, uc.requests)
+		assert.Equal(t, 3, uc.retries, "the checks of a held request are retries")
+		assert.Equal(t, []time.Duration{time.Second, minDelay}, waiter.waits)
+		assert.Empty(t, rl.queues)
+	})
+
+	t.Run("Should return 429 when the wait would exceed the max wait", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseDelay{rejected: 2, reset: now.Add(time.Minute).Unix()}
+		rl, waiter := newRateLimiter(uc, time.Second, "batch")
+
+		rr := serve(rl)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, 2, uc.requests)
+		assert.Equal(t, 1, uc.retries, "the last check is a normal one")
+		assert.Empty(t, waiter.waits)
+	})
+
+	t.Run("Should stop waiting when the context of the request is done", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseDelay{rejected: 2, reset: now.Unix()}
+		rl, waiter := newRateLimiter(uc, 5*time.Second, "batch")
+		waiter.canceled = true
+
+		rr := serve(rl)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, 2, uc.requests)
+		assert.Equal(t, 1, uc.retries)
+		assert.Empty(t, rl.queues)
+	})
+
+	t.Run("Should return 429 when the queue of the key is full", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseDelay{rejected: 1, reset: now.Unix()}
+		rl, _ := newRateLimiter(uc, time.Second, "batch")
+		rl.queues["batch"] = 1
+
+		rr := serve(rl)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, 1, uc.requests)
+		assert.Equal(t, 0, uc.retries)
+	})
+
+	t.Run("Should not delay the keys without delay mode", func(t *testing.T) {
+		uc := &mockRateLimitUseCaseDelay{rejected: 1, reset: now.Unix()}
+		rl, _ := newRateLimiter(uc, time.Second, "other")
+
+		rr := serve(rl)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, 1, uc.requests)
+		assert.Equal(t, 0, uc.retries)
+	})
+}
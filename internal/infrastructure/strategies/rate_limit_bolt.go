@@ -0,0 +1,178 @@
+package strategies
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	bolt "go.etcd.io/bbolt"
+
+	driversBolt "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/cache/bolt"
+	boltConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/bolt"
+)
+
+var boltBucket = []byte("rate_limits")
+
+// ErrBoltNotFound is returned by the bolt cache when the window of the key
+// does not exist or is expired
+var ErrBoltNotFound = fmt.Errorf("bolt: %w", domain.ErrWindowNotFound)
+
+// rateLimitBolt stores the windows in a bolt file, so they survive a restart
+// of the process. Every value is the expiration of the window, in unix
+// nanoseconds, followed by the window
+type rateLimitBolt struct {
+	db      *bolt.DB
+	clock   domain.Clock
+	mutex   sync.Mutex
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewRateLimitBolt returns the bolt cache using the database shared by the
+// process, opened as configured by cfg
+func NewRateLimitBolt(cfg boltConfig.BoltConfig) (domain.RateLimitCache, error) {
+	db, err := driversBolt.GetClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRateLimitBoltDB(db, domain.SystemClock)
+}
+
+// NewRateLimitBoltDB returns the bolt cache using the database informed,
+// expiring the windows by the clock. Closing the cache closes the database
+func NewRateLimitBoltDB(db *bolt.DB, clock domain.Clock) (domain.RateLimitCache, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimitBolt{
+		db:    db,
+		clock: clock,
+	}, nil
+}
+
+func (r *rateLimitBolt) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	data, err := rate.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	value := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(value, uint64(r.clock.Now().Add(every).UnixNano()))
+	value = append(value, data...)
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(rate.Key), value)
+	})
+}
+
+func (r *rateLimitBolt) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	var rate entities.RateLimiter
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltBucket).Get([]byte(key))
+
+		if value == nil || r.expired(value) {
+			return ErrBoltNotFound
+		}
+
+		return rate.UnmarshalBinary(value[8:])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+// expired reports if the window stored in value is expired, values too
+// short to have an expiration are treated as expired
+func (r *rateLimitBolt) expired(value []byte) bool {
+	if len(value) < 8 {
+		return true
+	}
+
+	return int64(binary.BigEndian.Uint64(value)) <= r.clock.Now().UnixNano()
+}
+
+// StartJanitor deletes the expired windows every interval, so keys that are
+// never requested again do not grow the file, until Close is called
+func (r *rateLimitBolt) StartJanitor(interval time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.stopped = make(chan struct{})
+
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.deleteExpired()
+			}
+		}
+	}()
+}
+
+func (r *rateLimitBolt) deleteExpired() error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+
+		// deleting while iterating makes the cursor skip keys
+		var expired [][]byte
+
+		err := bucket.ForEach(func(key, value []byte) error {
+			if r.expired(value) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close stops the janitor and closes the database
+func (r *rateLimitBolt) Close() error {
+	r.mutex.Lock()
+	stop, stopped := r.stop, r.stopped
+	r.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	return r.db.Close()
+}
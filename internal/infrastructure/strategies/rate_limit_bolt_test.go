@@ -0,0 +1,127 @@
+package strategies
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newBoltCache(t *testing.T, path string, clock domain.Clock) domain.RateLimitCache {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	require.NoError(t, err)
+
+	rl, err := NewRateLimitBoltDB(db, clock)
+	require.NoError(t, err)
+
+	return rl
+}
+
+func TestRateLimitBolt_Set(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Should store the window", func(t *testing.T) {
+		rl := newBoltCache(t, filepath.Join(t.TempDir(), "ratelimit.bolt"), ratelimittest.NewClock(now))
+		defer rl.(io.Closer).Close()
+
+		rate := entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60, Reset: now.Add(time.Minute).Unix()}
+		assert.NoError(t, rl.Set(ctx, rate, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, &rate, result)
+	})
+
+	t.Run("Should keep the windows after the database is reopened", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ratelimit.bolt")
+		clock := ratelimittest.NewClock(now)
+		rate := entities.RateLimiter{Key: "test_key", Requests: 1000, Remaining: 0, Every: 86400, Reset: now.Add(24 * time.Hour).Unix()}
+
+		rl := newBoltCache(t, path, clock)
+		assert.NoError(t, rl.Set(ctx, rate, 24*time.Hour))
+		assert.NoError(t, rl.(io.Closer).Close())
+
+		clock.Advance(time.Hour)
+
+		rl = newBoltCache(t, path, clock)
+		defer rl.(io.Closer).Close()
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, &rate, result)
+	})
+}
+
+func TestRateLimitBolt_Get(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should return error when the window does not exist", func(t *testing.T) {
+		rl := newBoltCache(t, filepath.Join(t.TempDir(), "ratelimit.bolt"), ratelimittest.NewClock(time.Now()))
+		defer rl.(io.Closer).Close()
+
+		result, err := rl.Get(ctx, "test_key")
+
+		assert.ErrorIs(t, err, ErrBoltNotFound)
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should return error when the window is expired", func(t *testing.T) {
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rl := newBoltCache(t, filepath.Join(t.TempDir(), "ratelimit.bolt"), clock)
+		defer rl.(io.Closer).Close()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1}, time.Minute))
+
+		clock.Advance(time.Minute)
+
+		result, err := rl.Get(ctx, "test_key")
+
+		assert.ErrorIs(t, err, ErrBoltNotFound)
+		assert.Nil(t, result)
+	})
+}
+
+func TestRateLimitBolt_Janitor(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should delete expired windows in background", func(t *testing.T) {
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rl := newBoltCache(t, filepath.Join(t.TempDir(), "ratelimit.bolt"), clock)
+
+		for _, key := range []string{"expired_1", "expired_2", "expired_3"} {
+			assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: key, Requests: 1}, time.Second))
+		}
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "active", Requests: 1}, time.Minute))
+
+		clock.Advance(2 * time.Second)
+
+		rl.(Janitor).StartJanitor(time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			var keys int
+
+			rl.(*rateLimitBolt).db.View(func(tx *bolt.Tx) error {
+				keys = tx.Bucket(boltBucket).Stats().KeyN
+				return nil
+			})
+
+			return keys == 1
+		}, time.Second, time.Millisecond)
+
+		_, err := rl.Get(ctx, "active")
+		assert.NoError(t, err)
+
+		assert.NoError(t, rl.(io.Closer).Close())
+	})
+}
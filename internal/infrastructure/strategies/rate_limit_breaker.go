@@ -0,0 +1,351 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+
+// BreakerState is the state of the circuit breaker, reported by the
+// rate_limit.breaker.state gauge
+type BreakerState int
+
+const (
+	// BreakerClosed sends every call to the primary cache
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen sends a single probe to the primary cache
+	BreakerHalfOpen
+	// BreakerOpen sends every call to the fallback cache
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// rateLimitBreaker sends the calls to the primary cache while it is healthy.
+// After failureThreshold consecutive failures, errors or calls slower than
+// latencyThreshold, the breaker opens and the fallback cache serves every
+// call for openTimeout. Then a single probe goes to the primary cache, closing
+// the breaker when it succeeds and opening it again when it fails
+type rateLimitBreaker struct {
+	primary          domain.RateLimitCache
+	fallback         domain.RateLimitCache
+	name             string
+	failureThreshold int
+	latencyThreshold time.Duration
+	openTimeout      time.Duration
+	ignored          []error
+	clock            domain.Clock
+	logger           *slog.Logger
+	meterProvider    metric.MeterProvider
+
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+
+	transitions  metric.Int64Counter
+	fallbacks    metric.Int64Counter
+	registration metric.Registration
+}
+
+// BreakerOption configures the circuit breaker
+type BreakerOption func(*rateLimitBreaker)
+
+// WithBreakerName sets the name of the primary cache in logs and metrics,
+// redis when it is not informed
+func WithBreakerName(name string) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.name = name
+	}
+}
+
+// WithBreakerFailures sets how many consecutive failures open the breaker,
+// 5 when it is not informed
+func WithBreakerFailures(failures int) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.failureThreshold = failures
+	}
+}
+
+// WithBreakerLatency counts the calls slower than latency as failures, the
+// latency is not checked when it is not informed
+func WithBreakerLatency(latency time.Duration) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.latencyThreshold = latency
+	}
+}
+
+// WithBreakerOpenTimeout sets how long the breaker stays open before probing
+// the primary cache, 30 seconds when it is not informed
+func WithBreakerOpenTimeout(timeout time.Duration) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.openTimeout = timeout
+	}
+}
+
+// WithBreakerIgnoredErrors sets the errors of the primary cache that are not
+// failures, such as the error of a missing window
+func WithBreakerIgnoredErrors(errs ...error) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.ignored = append(b.ignored, errs...)
+	}
+}
+
+// WithBreakerClock sets the clock of the latency and open timeout, the system
+// clock is used when it is not informed
+func WithBreakerClock(clock domain.Clock) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.clock = clock
+	}
+}
+
+// WithBreakerLogger sets the logger of the state changes, the default slog
+// logger is used when it is not informed
+func WithBreakerLogger(logger *slog.Logger) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.logger = logger
+	}
+}
+
+// WithBreakerMeterProvider sets the provider of the breaker metrics, the
+// global provider is used when it is not informed
+func WithBreakerMeterProvider(provider metric.MeterProvider) BreakerOption {
+	return func(b *rateLimitBreaker) {
+		b.meterProvider = provider
+	}
+}
+
+// NewRateLimitBreaker returns the primary cache guarded by a circuit breaker
+// that falls back to fallback. It implements Janitor and io.Closer for both
+// caches
+func NewRateLimitBreaker(primary, fallback domain.RateLimitCache, opts ...BreakerOption) (domain.RateLimitCache, error) {
+	b := &rateLimitBreaker{
+		primary:          primary,
+		fallback:         fallback,
+		name:             "redis",
+		failureThreshold: 5,
+		openTimeout:      30 * time.Second,
+		clock:            domain.SystemClock,
+		logger:           slog.Default(),
+		meterProvider:    otel.GetMeterProvider(),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if err := b.registerMetrics(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *rateLimitBreaker) registerMetrics() error {
+	meter := b.meterProvider.Meter(meterName)
+
+	state, err := meter.Int64ObservableGauge("rate_limit.breaker.state",
+		metric.WithDescription("State of the circuit breaker: 0 closed, 1 half-open and 2 open"))
+	if err != nil {
+		return err
+	}
+
+	if b.transitions, err = meter.Int64Counter("rate_limit.breaker.transitions",
+		metric.WithDescription("State changes of the circuit breaker")); err != nil {
+		return err
+	}
+
+	if b.fallbacks, err = meter.Int64Counter("rate_limit.breaker.fallbacks",
+		metric.WithDescription("Calls served by the fallback cache")); err != nil {
+		return err
+	}
+
+	b.registration, err = meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		observer.ObserveInt64(state, int64(b.state), metric.WithAttributes(b.attributes()...))
+
+		return nil
+	}, state)
+
+	return err
+}
+
+func (b *rateLimitBreaker) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	var rate *entities.RateLimiter
+
+	err := b.do(ctx, func(cache domain.RateLimitCache) error {
+		var err error
+		rate, err = cache.Get(ctx, key)
+
+		return err
+	})
+
+	return rate, err
+}
+
+func (b *rateLimitBreaker) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	return b.do(ctx, func(cache domain.RateLimitCache) error {
+		return cache.Set(ctx, rate, every)
+	})
+}
+
+// State returns the current state of the breaker
+func (b *rateLimitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// do runs call against the primary cache when the breaker allows it, and
+// against the fallback cache when it does not or the primary cache failed
+func (b *rateLimitBreaker) do(ctx context.Context, call func(domain.RateLimitCache) error) error {
+	probe, allowed := b.allow(ctx)
+
+	if !allowed {
+		b.fallbacks.Add(ctx, 1, metric.WithAttributes(b.attributes()...))
+		return call(b.fallback)
+	}
+
+	start := b.clock.Now()
+	err := call(b.primary)
+	slow := b.latencyThreshold > 0 && b.clock.Now().Sub(start) > b.latencyThreshold
+	failed := err != nil && !b.isIgnored(err)
+
+	b.record(ctx, probe, failed || slow)
+
+	if failed {
+		b.fallbacks.Add(ctx, 1, metric.WithAttributes(b.attributes()...))
+		return call(b.fallback)
+	}
+
+	return err
+}
+
+// allow reports if the call goes to the primary cache and if it is the probe
+// of the half-open breaker
+func (b *rateLimitBreaker) allow(ctx context.Context) (probe bool, allowed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return false, true
+	case BreakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.openTimeout {
+			return false, false
+		}
+
+		b.transition(ctx, BreakerHalfOpen)
+
+		return true, true
+	default:
+		// the probe is running
+		return false, false
+	}
+}
+
+// record counts the result of a call to the primary cache
+func (b *rateLimitBreaker) record(ctx context.Context, probe, failure bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch {
+	case probe && failure:
+		b.transition(ctx, BreakerOpen)
+	case probe:
+		b.transition(ctx, BreakerClosed)
+	case b.state != BreakerClosed:
+		// the call started before the breaker opened
+	case !failure:
+		b.failures = 0
+	default:
+		b.failures++
+
+		if b.failures >= b.failureThreshold {
+			b.transition(ctx, BreakerOpen)
+		}
+	}
+}
+
+func (b *rateLimitBreaker) transition(ctx context.Context, state BreakerState) {
+	from := b.state
+	b.state = state
+	b.failures = 0
+
+	if state == BreakerOpen {
+		b.openedAt = b.clock.Now()
+	}
+
+	b.transitions.Add(ctx, 1, metric.WithAttributes(append(b.attributes(),
+		attribute.String("rate_limit.breaker.state", state.String()))...))
+
+	attrs := []any{"cache", b.name, "from", from.String(), "to", state.String()}
+
+	switch state {
+	case BreakerOpen:
+		b.logger.WarnContext(ctx, "circuit breaker opened, using fallback cache", append(attrs, "open_timeout", b.openTimeout)...)
+	case BreakerHalfOpen:
+		b.logger.InfoContext(ctx, "circuit breaker half-open, probing cache", attrs...)
+	default:
+		b.logger.InfoContext(ctx, "circuit breaker closed", attrs...)
+	}
+}
+
+func (b *rateLimitBreaker) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("rate_limit.breaker.cache", b.name)}
+}
+
+func (b *rateLimitBreaker) isIgnored(err error) bool {
+	for _, ignored := range b.ignored {
+		if errors.Is(err, ignored) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StartJanitor starts the janitor of the caches that have one
+func (b *rateLimitBreaker) StartJanitor(interval time.Duration) {
+	for _, cache := range []domain.RateLimitCache{b.primary, b.fallback} {
+		if janitor, ok := cache.(Janitor); ok {
+			janitor.StartJanitor(interval)
+		}
+	}
+}
+
+// Close stops reporting the breaker state and closes both caches
+func (b *rateLimitBreaker) Close() error {
+	err := b.registration.Unregister()
+
+	for _, cache := range []domain.RateLimitCache{b.primary, b.fallback} {
+		if closer, ok := cache.(io.Closer); ok {
+			err = errors.Join(err, closer.Close())
+		}
+	}
+
+	return err
+}
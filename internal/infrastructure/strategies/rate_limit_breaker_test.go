@@ -0,0 +1,259 @@
+package strategies
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var (
+	errBackendDown = errors.New("backend down")
+	errBackendMiss = errors.New("backend miss")
+)
+
+// failingCache is a backend that fails with err, and takes delay on the
+// clock, for every call while they are set
+type failingCache struct {
+	domain.RateLimitCache
+	clock *ratelimittest.Clock
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (f *failingCache) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	f.calls++
+	f.clock.Advance(f.delay)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.RateLimitCache.Get(ctx, key)
+}
+
+func (f *failingCache) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	f.calls++
+	f.clock.Advance(f.delay)
+
+	if f.err != nil {
+		return f.err
+	}
+
+	return f.RateLimitCache.Set(ctx, rate, every)
+}
+
+func TestRateLimitBreaker(t *testing.T) {
+	ctx := context.TODO()
+	rate := entities.RateLimiter{Key: "token_1", Requests: 1, Remaining: 9, Every: 60}
+
+	newBreaker := func(t *testing.T, opts ...BreakerOption) (*failingCache, domain.RateLimitCache, domain.RateLimitCache, *ratelimittest.Clock) {
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rate.Reset = clock.Now().Add(time.Hour).Unix()
+
+		primary := &failingCache{RateLimitCache: NewRateLimitInMemoryClock(clock), clock: clock}
+		fallback := NewRateLimitInMemoryClock(clock)
+
+		opts = append([]BreakerOption{
+			WithBreakerClock(clock),
+			WithBreakerFailures(3),
+			WithBreakerOpenTimeout(10 * time.Second),
+			WithBreakerLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		}, opts...)
+
+		breaker, err := NewRateLimitBreaker(primary, fallback, opts...)
+		require.NoError(t, err)
+
+		return primary, fallback, breaker, clock
+	}
+
+	t.Run("Should use the primary cache while it is healthy", func(t *testing.T) {
+		primary, fallback, breaker, _ := newBreaker(t)
+
+		assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+
+		_, err := primary.Get(ctx, "token_1")
+		assert.NoError(t, err)
+		_, err = fallback.Get(ctx, "token_1")
+		assert.Error(t, err)
+	})
+
+	t.Run("Should serve a failed call with the fallback cache", func(t *testing.T) {
+		primary, fallback, breaker, _ := newBreaker(t)
+		primary.err = errBackendDown
+
+		assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+
+		_, err := fallback.Get(ctx, "token_1")
+		assert.NoError(t, err)
+		assert.Equal(t, BreakerClosed, breaker.(*rateLimitBreaker).State())
+	})
+
+	t.Run("Should open after consecutive failures and skip the primary cache", func(t *testing.T) {
+		primary, _, breaker, _ := newBreaker(t)
+		primary.err = errBackendDown
+
+		for i := 0; i < 3; i++ {
+			_, _ = breaker.Get(ctx, "token_1")
+		}
+
+		assert.Equal(t, BreakerOpen, breaker.(*rateLimitBreaker).State())
+
+		calls := primary.calls
+		assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+		assert.Equal(t, calls, primary.calls)
+	})
+
+	t.Run("Should reset the failures after a success", func(t *testing.T) {
+		primary, _, breaker, _ := newBreaker(t)
+
+		for i := 0; i < 5; i++ {
+			primary.err = errBackendDown
+			_, _ = breaker.Get(ctx, "token_1")
+			_, _ = breaker.Get(ctx, "token_1")
+
+			primary.err = nil
+			assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+		}
+
+		assert.Equal(t, BreakerClosed, breaker.(*rateLimitBreaker).State())
+	})
+
+	t.Run("Should not count the ignored errors as failures", func(t *testing.T) {
+		primary, _, breaker, _ := newBreaker(t, WithBreakerIgnoredErrors(errBackendMiss))
+		primary.err = errBackendMiss
+
+		for i := 0; i < 5; i++ {
+			_, err := breaker.Get(ctx, "token_1")
+			assert.ErrorIs(t, err, errBackendMiss)
+		}
+
+		assert.Equal(t, BreakerClosed, breaker.(*rateLimitBreaker).State())
+	})
+
+	t.Run("Should open when the calls are slower than the latency threshold", func(t *testing.T) {
+		primary, _, breaker, _ := newBreaker(t, WithBreakerLatency(50*time.Millisecond))
+		primary.delay = 100 * time.Millisecond
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+		}
+
+		assert.Equal(t, BreakerOpen, breaker.(*rateLimitBreaker).State())
+	})
+
+	t.Run("Should close after a successful probe", func(t *testing.T) {
+		primary, _, breaker, clock := newBreaker(t)
+		primary.err = errBackendDown
+
+		for i := 0; i < 3; i++ {
+			_, _ = breaker.Get(ctx, "token_1")
+		}
+
+		clock.Advance(10 * time.Second)
+		primary.err = nil
+		calls := primary.calls
+
+		assert.NoError(t, breaker.Set(ctx, rate, time.Hour))
+
+		assert.Equal(t, calls+1, primary.calls)
+		assert.Equal(t, BreakerClosed, breaker.(*rateLimitBreaker).State())
+	})
+
+	t.Run("Should open again after a failed probe", func(t *testing.T) {
+		primary, _, breaker, clock := newBreaker(t)
+		primary.err = errBackendDown
+
+		for i := 0; i < 3; i++ {
+			_, _ = breaker.Get(ctx, "token_1")
+		}
+
+		clock.Advance(10 * time.Second)
+
+		_, _ = breaker.Get(ctx, "token_1")
+		assert.Equal(t, BreakerOpen, breaker.(*rateLimitBreaker).State())
+
+		calls := primary.calls
+		_, _ = breaker.Get(ctx, "token_1")
+		assert.Equal(t, calls, primary.calls)
+	})
+
+	t.Run("Should send a single probe while half-open", func(t *testing.T) {
+		primary, _, breaker, clock := newBreaker(t)
+		b := breaker.(*rateLimitBreaker)
+		primary.err = errBackendDown
+
+		for i := 0; i < 3; i++ {
+			_, _ = breaker.Get(ctx, "token_1")
+		}
+
+		clock.Advance(10 * time.Second)
+
+		probe, allowed := b.allow(ctx)
+		assert.True(t, probe)
+		assert.True(t, allowed)
+		assert.Equal(t, BreakerHalfOpen, b.State())
+
+		_, allowed = b.allow(ctx)
+		assert.False(t, allowed)
+	})
+
+	t.Run("Should log and report the state changes", func(t *testing.T) {
+		var logs bytes.Buffer
+		reader := sdkmetric.NewManualReader()
+
+		primary, _, breaker, _ := newBreaker(t,
+			WithBreakerLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			WithBreakerMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		)
+		primary.err = errBackendDown
+
+		for i := 0; i < 4; i++ {
+			_, _ = breaker.Get(ctx, "token_1")
+		}
+
+		assert.Contains(t, logs.String(), `msg="circuit breaker opened, using fallback cache" cache=redis from=closed to=open`)
+
+		var metrics metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(ctx, &metrics))
+
+		values := map[string]int64{}
+
+		for _, scope := range metrics.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Gauge[int64]:
+					values[m.Name] = data.DataPoints[0].Value
+				case metricdata.Sum[int64]:
+					values[m.Name] = data.DataPoints[0].Value
+
+					if m.Name == "rate_limit.breaker.transitions" {
+						state, _ := data.DataPoints[0].Attributes.Value(attribute.Key("rate_limit.breaker.state"))
+						assert.Equal(t, "open", state.AsString())
+					}
+				}
+			}
+		}
+
+		assert.Equal(t, map[string]int64{
+			"rate_limit.breaker.state":       int64(BreakerOpen),
+			"rate_limit.breaker.transitions": 1,
+			"rate_limit.breaker.fallbacks":   4,
+		}, values)
+
+		assert.NoError(t, breaker.(io.Closer).Close())
+	})
+}
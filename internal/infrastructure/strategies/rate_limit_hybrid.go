@@ -0,0 +1,288 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	hybridConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/hybrid"
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
+)
+
+// ErrReservationNotFound is returned by a Reserver when the quota of the key
+// does not exist and no window was informed to create it
+var ErrReservationNotFound = errors.New("rate limit reservation not found")
+
+// Reservation is the state of the quota of a window shared by the instances
+// after a reservation: the requests granted to the caller, the requests
+// reserved by every instance and the window limit, duration and reset
+type Reservation struct {
+	Granted  int
+	Reserved int
+	Limit    int
+	Every    int
+	Reset    int64
+}
+
+// Reserver reserves batches of requests of windows shared by several
+// instances. When the quota of the key does not exist it is created with the
+// limit, every and reset of window, or ErrReservationNotFound is returned
+// when window is nil
+type Reserver interface {
+	Reserve(ctx context.Context, key string, requests int, window *Reservation) (Reservation, error)
+}
+
+// hybridWindow is the local view of a shared window: the requests counted by
+// this instance and the lease, the requests reserved and not used yet
+type hybridWindow struct {
+	rate      entities.RateLimiter
+	lease     int
+	exhausted bool
+	refilling bool
+}
+
+// rateLimitHybrid decides locally against the requests leased from the
+// remote quota, so most checks make no round trip. The lease is refilled in
+// background when it falls below half of the batch, and synchronously only
+// when it is empty.
+//
+// The remote quota never grants more than the limit, so the only
+// over-admission is the first request of a window this instance creates
+// while other instances exhausted it, at most its cost per instance. Leases
+// not used by an instance until the reset are lost, so up to the batch size
+// minus one request per instance may be refused while under the limit
+type rateLimitHybrid struct {
+	remote    Reserver
+	clock     domain.Clock
+	batchSize int
+	mutex     sync.Mutex
+	windows   map[string]*hybridWindow
+	refills   sync.WaitGroup
+	stop      context.CancelFunc
+	stopped   chan struct{}
+}
+
+// NewRateLimitHybrid returns the two-tier cache reserving from the redis
+// cache configured by redisCfg, in batches of the size of cfg
+func NewRateLimitHybrid(redisCfg redisConfig.RedisConfig, cfg hybridConfig.HybridConfig) (domain.RateLimitCache, error) {
+	remote, err := NewRateLimitRedis(redisCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRateLimitHybridClient(remote.(Reserver), domain.SystemClock, cfg.BatchSize), nil
+}
+
+// NewRateLimitHybridClient returns the two-tier cache reserving batchSize
+// requests at a time from remote. Closing the cache closes remote when it
+// implements io.Closer
+func NewRateLimitHybridClient(remote Reserver, clock domain.Clock, batchSize int) domain.RateLimitCache {
+	return &rateLimitHybrid{
+		remote:    remote,
+		clock:     clock,
+		batchSize: max(batchSize, 1),
+		windows:   make(map[string]*hybridWindow),
+	}
+}
+
+// Get returns the local window with the lease as the remaining requests,
+// joining the remote quota when the window is not known locally
+func (h *rateLimitHybrid) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	window, ok := h.windows[key]
+
+	if !ok || window.rate.Reset < h.clock.Now().Unix() {
+		delete(h.windows, key)
+
+		reservation, err := h.reserve(ctx, key, h.batchSize, nil)
+		if errors.Is(err, ErrReservationNotFound) {
+			return nil, fmt.Errorf("%w: %w", domain.ErrWindowNotFound, err)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		window = &hybridWindow{
+			rate: entities.RateLimiter{
+				Key:   key,
+				Every: reservation.Every,
+				Reset: reservation.Reset,
+			},
+			lease:     reservation.Granted,
+			exhausted: reservation.Reserved >= reservation.Limit,
+		}
+
+		h.windows[key] = window
+	}
+
+	switch {
+	case window.exhausted:
+	case window.lease == 0:
+		if reservation, err := h.reserve(ctx, key, h.batchSize, nil); err == nil {
+			h.lease(key, window.rate.Reset, reservation)
+		}
+	case window.lease < (h.batchSize+1)/2 && !window.refilling:
+		window.refilling = true
+		h.refills.Add(1)
+
+		go h.refill(context.WithoutCancel(ctx), key, window.rate.Reset)
+	}
+
+	rate := window.rate
+	rate.Remaining = window.lease
+	rate.Counted = rate.Requests
+
+	return &rate, nil
+}
+
+// Set consumes the requests counted since the window was read from the
+// lease. A new window creates the remote quota, reserving its requests and
+// a batch
+func (h *rateLimitHybrid) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if window, ok := h.windows[rate.Key]; ok && window.rate.Reset == rate.Reset {
+		window.lease = max(window.lease-(rate.Requests-rate.Counted), 0)
+		window.rate.Requests = rate.Requests
+		window.rate.Limited = window.rate.Limited || rate.Limited
+
+		return nil
+	}
+
+	window := &hybridWindow{rate: rate, exhausted: true}
+	window.rate.Remaining = 0
+
+	if rate.Every > 0 {
+		reservation, err := h.reserve(ctx, rate.Key, h.batchSize+rate.Requests, &Reservation{
+			Limit: rate.Requests + rate.Remaining,
+			Every: rate.Every,
+			Reset: rate.Reset,
+		})
+		if err != nil {
+			return err
+		}
+
+		window.rate.Every = reservation.Every
+		window.rate.Reset = reservation.Reset
+		window.lease = max(reservation.Granted-rate.Requests, 0)
+		window.exhausted = reservation.Reserved >= reservation.Limit
+	}
+
+	h.windows[rate.Key] = window
+
+	return nil
+}
+
+// reserve reserves requests from the remote quota without holding the lock,
+// so checks of other keys are not blocked by the round trip
+func (h *rateLimitHybrid) reserve(ctx context.Context, key string, requests int, window *Reservation) (Reservation, error) {
+	h.mutex.Unlock()
+	defer h.mutex.Lock()
+
+	return h.remote.Reserve(ctx, key, requests, window)
+}
+
+// refill reserves a batch in background, a failure is retried by the next
+// check of the key
+func (h *rateLimitHybrid) refill(ctx context.Context, key string, reset int64) {
+	defer h.refills.Done()
+
+	reservation, err := h.remote.Reserve(ctx, key, h.batchSize, nil)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if window, ok := h.windows[key]; ok && window.rate.Reset == reset {
+		window.refilling = false
+	}
+
+	if err == nil {
+		h.lease(key, reset, reservation)
+	}
+}
+
+// lease adds the granted requests to the window of the key, they are lost
+// when the window was replaced during the reservation
+func (h *rateLimitHybrid) lease(key string, reset int64, reservation Reservation) {
+	window, ok := h.windows[key]
+	if !ok || window.rate.Reset != reset || reservation.Reset != reset {
+		return
+	}
+
+	window.lease += reservation.Granted
+	window.exhausted = reservation.Reserved >= reservation.Limit
+}
+
+// StartJanitor removes the expired local windows every interval, until Close
+// is called. The remote quotas expire by themselves
+func (h *rateLimitHybrid) StartJanitor(interval time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	h.stop = stop
+	h.stopped = make(chan struct{})
+
+	go func() {
+		defer close(h.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.deleteExpired()
+			}
+		}
+	}()
+}
+
+func (h *rateLimitHybrid) deleteExpired() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.clock.Now().Unix()
+
+	for key, window := range h.windows {
+		if window.rate.Reset < now {
+			delete(h.windows, key)
+		}
+	}
+}
+
+// Close stops the janitor, waits for the background reservations and closes
+// the remote quota
+func (h *rateLimitHybrid) Close() error {
+	h.mutex.Lock()
+	stop, stopped := h.stop, h.stopped
+	h.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	h.refills.Wait()
+
+	if closer, ok := h.remote.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package strategies
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisReserver(t *testing.T) (*miniredis.Miniredis, Reserver) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), DisableIndentity: true})
+
+	return server, NewRateLimitRedisClient(client, WithKeyPrefix("ratelimit", "")).(Reserver)
+}
+
+func TestRateLimitRedis_Reserve(t *testing.T) {
+	ctx := context.TODO()
+	reset := time.Now().Add(time.Minute).Unix()
+	window := &Reservation{Limit: 10, Every: 60, Reset: reset}
+
+	t.Run("Should create the quota and reserve the requests", func(t *testing.T) {
+		server, reserver := newRedisReserver(t)
+
+		result, err := reserver.Reserve(ctx, "token_1", 4, window)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Reservation{Granted: 4, Reserved: 4, Limit: 10, Every: 60, Reset: reset}, result)
+		assert.True(t, server.Exists("ratelimit:{token_1}:quota"))
+		assert.Equal(t, time.Minute, server.TTL("ratelimit:{token_1}:quota"))
+	})
+
+	t.Run("Should grant only the requests left under the limit", func(t *testing.T) {
+		_, reserver := newRedisReserver(t)
+
+		_, err := reserver.Reserve(ctx, "token_1", 8, window)
+		require.NoError(t, err)
+
+		result, err := reserver.Reserve(ctx, "token_1", 8, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Granted)
+		assert.Equal(t, 10, result.Reserved)
+
+		result, err = reserver.Reserve(ctx, "token_1", 8, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, result.Granted)
+	})
+
+	t.Run("Should return error when the quota does not exist and no window is informed", func(t *testing.T) {
+		_, reserver := newRedisReserver(t)
+
+		_, err := reserver.Reserve(ctx, "token_1", 1, nil)
+
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+	})
+}
+
+func TestRateLimitHybrid(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Should decide locally while the lease lasts", func(t *testing.T) {
+		server, reserver := newRedisReserver(t)
+		clock := ratelimittest.NewClock(now)
+		rl := NewRateLimitHybridClient(reserver, clock, 10)
+		defer rl.(io.Closer).Close()
+
+		_, err := rl.Get(ctx, "token_1")
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
+
+		// the use case creates the window with the first request
+		err = rl.Set(ctx, entities.RateLimiter{Key: "token_1", Requests: 1, Remaining: 99, Every: 60, Reset: now.Add(time.Minute).Unix()}, time.Minute)
+		assert.NoError(t, err)
+
+		commands := server.CommandCount()
+
+		for i := 0; i < 4; i++ {
+			rate, err := rl.Get(ctx, "token_1")
+			require.NoError(t, err)
+			assert.Equal(t, 10-i, rate.Remaining)
+
+			rate.Requests++
+			assert.NoError(t, rl.Set(ctx, *rate, time.Minute))
+		}
+
+		assert.Equal(t, commands, server.CommandCount())
+
+		quota := server.HGet("ratelimit:{token_1}:quota", "reserved")
+		assert.Equal(t, "11", quota)
+	})
+
+	t.Run("Should refill the lease in background before it is empty", func(t *testing.T) {
+		server, reserver := newRedisReserver(t)
+		clock := ratelimittest.NewClock(now)
+		rl := NewRateLimitHybridClient(reserver, clock, 4)
+
+		err := rl.Set(ctx, entities.RateLimiter{Key: "token_1", Requests: 1, Remaining: 99, Every: 60, Reset: now.Add(time.Minute).Unix()}, time.Minute)
+		require.NoError(t, err)
+
+		// the lease falls below half of the batch in the fourth check
+		for i := 0; i < 4; i++ {
+			rate, err := rl.Get(ctx, "token_1")
+			require.NoError(t, err)
+
+			rate.Requests++
+			require.NoError(t, rl.Set(ctx, *rate, time.Minute))
+		}
+
+		assert.NoError(t, rl.(io.Closer).Close())
+
+		assert.Equal(t, "9", server.HGet("ratelimit:{token_1}:quota", "reserved"))
+	})
+
+	t.Run("Should join the window created by another instance", func(t *testing.T) {
+		_, reserver := newRedisReserver(t)
+		clock := ratelimittest.NewClock(now)
+		first := NewRateLimitHybridClient(reserver, clock, 5)
+		second := NewRateLimitHybridClient(reserver, clock, 5)
+		defer first.(io.Closer).Close()
+
+		err := first.Set(ctx, entities.RateLimiter{Key: "token_1", Requests: 1, Remaining: 7, Every: 60, Reset: now.Add(time.Minute).Unix()}, time.Minute)
+		require.NoError(t, err)
+
+		rate, err := second.Get(ctx, "token_1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, rate.Remaining)
+		assert.Equal(t, now.Add(time.Minute).Unix(), rate.Reset)
+	})
+
+	t.Run("Should start a new window after the reset", func(t *testing.T) {
+		server, reserver := newRedisReserver(t)
+		clock := ratelimittest.NewClock(now)
+		rl := NewRateLimitHybridClient(reserver, clock, 5)
+		defer rl.(io.Closer).Close()
+
+		err := rl.Set(ctx, entities.RateLimiter{Key: "token_1", Requests: 1, Remaining: 9, Every: 60, Reset: now.Add(time.Minute).Unix()}, time.Minute)
+		require.NoError(t, err)
+
+		clock.Advance(time.Minute + time.Second)
+		server.FastForward(time.Minute + time.Second)
+
+		_, err = rl.Get(ctx, "token_1")
+
+		assert.ErrorIs(t, err, ErrReservationNotFound)
+	})
+
+	t.Run("Should keep the instances under the limit with a bounded over-admission", func(t *testing.T) {
+		_, reserver := newRedisReserver(t)
+		clock := ratelimittest.NewClock(now)
+		limits := rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{Requests: 20, Every: 60},
+		}
+
+		var instances []usecases.RateLimitUseCase
+
+		for i := 0; i < 3; i++ {
+			rl := NewRateLimitHybridClient(reserver, clock, 4)
+			defer rl.(io.Closer).Close()
+
+			uc, err := usecases.New(usecases.WithLimits(limits), usecases.WithCache(rl), usecases.WithClock(clock))
+			require.NoError(t, err)
+
+			instances = append(instances, uc)
+		}
+
+		allowed := 0
+
+		for i := 0; i < 60; i++ {
+			decision, err := instances[i%len(instances)].Check(ctx, entities.CheckRequest{Key: "token_1"})
+			require.NoError(t, err)
+
+			if decision.Allowed {
+				allowed++
+			}
+		}
+
+		// up to the batch size minus one request per instance may be leased
+		// and not used, and only the first request of each instance may be
+		// admitted beyond the limit
+		assert.LessOrEqual(t, allowed, 20+len(instances))
+		assert.GreaterOrEqual(t, allowed, 20-len(instances)*3)
+	})
+}
@@ -2,38 +2,118 @@ package strategies
 
 import (
 	"context"
-	"errors"
+	"sync"
 	"time"
 
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 )
 
+// Janitor is implemented by caches that remove expired rate limits in background
+type Janitor interface {
+	StartJanitor(interval time.Duration)
+}
+
 type rateLimitInMemory struct {
-	rates map[string]entities.RateLimiter
+	mutex   sync.Mutex
+	rates   map[string]entities.RateLimiter
+	clock   domain.Clock
+	stop    context.CancelFunc
+	stopped chan struct{}
 }
 
 func NewRateLimitInMemory() domain.RateLimitCache {
+	return NewRateLimitInMemoryClock(domain.SystemClock)
+}
+
+// NewRateLimitInMemoryClock returns the in-memory cache expiring the rate
+// limits by the clock informed
+func NewRateLimitInMemoryClock(clock domain.Clock) domain.RateLimitCache {
 	return &rateLimitInMemory{
 		rates: make(map[string]entities.RateLimiter),
+		clock: clock,
 	}
 }
 
 func (r *rateLimitInMemory) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	r.rates[rate.Key] = rate
 	return nil
 }
 
 func (r *rateLimitInMemory) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	rate, ok := r.rates[key]
 	if !ok {
-		return nil, errors.New("rate limit not found")
+		return nil, domain.ErrWindowNotFound
 	}
 
-	if rate.Reset < time.Now().Unix() {
+	if rate.Reset < r.clock.Now().Unix() {
 		delete(r.rates, key)
-		return nil, errors.New("rate limit expired")
+		return nil, domain.ErrWindowNotFound
 	}
 
 	return &rate, nil
 }
+
+// StartJanitor removes the expired rate limits every interval, so keys that
+// are never requested again do not stay in memory, until Close is called
+func (r *rateLimitInMemory) StartJanitor(interval time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.stopped = make(chan struct{})
+
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.deleteExpired()
+			}
+		}
+	}()
+}
+
+func (r *rateLimitInMemory) deleteExpired() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now().Unix()
+
+	for key, rate := range r.rates {
+		if rate.Reset < now {
+			delete(r.rates, key)
+		}
+	}
+}
+
+// Close stops the janitor and waits for it to finish
+func (r *rateLimitInMemory) Close() error {
+	r.mutex.Lock()
+	stop, stopped := r.stop, r.stopped
+	r.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	return nil
+}
@@ -2,11 +2,14 @@ package strategies
 
 import (
 	"context"
+	"io"
 	"log"
 	"testing"
 	"time"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -67,7 +70,7 @@ func TestRateLimitInMemory_Get(t *testing.T) {
 
 		// Check if the error is not nil
 		assert.Error(t, err)
-		assert.ErrorContains(t, err, "rate limit not found")
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
 
 		// Check if the retrieved rate limit is nil
 		assert.Nil(t, rate)
@@ -75,25 +78,66 @@ func TestRateLimitInMemory_Get(t *testing.T) {
 
 	t.Run("Should handle error when rate limit has expired", func(t *testing.T) {
 		// Create a rateLimitInMemory instance
-		rl := NewRateLimitInMemory()
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rl := NewRateLimitInMemoryClock(clock)
 
-		// Set the rate limit in memory with an expired reset time
+		// Set the rate limit in memory and move the clock past its reset time
 		expiredRate := entities.RateLimiter{
 			Key:      key,
 			Requests: 10,
-			Reset:    time.Now().Unix() - 1,
+			Reset:    clock.Now().Add(time.Minute).Unix(),
 		}
 		err := rl.Set(ctx, expiredRate, time.Minute)
 		assert.NoError(t, err)
 
+		clock.Advance(time.Minute + time.Second)
+
 		// Get the rate limit
 		rate, err := rl.Get(ctx, key)
 
 		// Check if the error is not nil
 		assert.Error(t, err)
-		assert.ErrorContains(t, err, "rate limit expired")
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
 
 		// Check if the retrieved rate limit is nil
 		assert.Nil(t, rate)
 	})
 }
+
+func TestRateLimitInMemory_Janitor(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should remove expired rate limits in background", func(t *testing.T) {
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rl := NewRateLimitInMemoryClock(clock)
+
+		err := rl.Set(ctx, entities.RateLimiter{Key: "expired", Reset: clock.Now().Add(time.Second).Unix()}, time.Second)
+		assert.NoError(t, err)
+		err = rl.Set(ctx, entities.RateLimiter{Key: "active", Reset: clock.Now().Add(time.Minute).Unix()}, time.Minute)
+		assert.NoError(t, err)
+
+		clock.Advance(2 * time.Second)
+
+		rl.(Janitor).StartJanitor(time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			r := rl.(*rateLimitInMemory)
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+
+			_, ok := r.rates["expired"]
+			return !ok
+		}, time.Second, time.Millisecond)
+
+		_, err = rl.Get(ctx, "active")
+		assert.NoError(t, err)
+
+		assert.NoError(t, rl.(io.Closer).Close())
+	})
+
+	t.Run("Should close without a janitor running", func(t *testing.T) {
+		rl := NewRateLimitInMemory()
+
+		assert.NoError(t, rl.(io.Closer).Close())
+	})
+}
@@ -0,0 +1,272 @@
+package strategies
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	memcachedConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/memcached"
+
+	driversMemcached "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/cache/memcached"
+)
+
+const (
+	// memcachedMaxRelativeExpiration is the longest expiration memcached
+	// reads as seconds from now, longer ones must be unix timestamps
+	memcachedMaxRelativeExpiration = 30 * 24 * time.Hour
+
+	// memcachedCASRetries is how many times an update of the window retries
+	// after another instance changed it
+	memcachedCASRetries = 3
+)
+
+// rateLimitMemcached stores every window in two items: the window itself and
+// a counter of its requests. The counter is updated with incr, so instances
+// sharing the server never lose requests, and the window with cas
+type rateLimitMemcached struct {
+	client *memcache.Client
+	prefix string
+}
+
+// NewRateLimitMemcached returns the memcached cache using the client shared
+// by the process, prefixing the keys as configured by cfg
+func NewRateLimitMemcached(cfg memcachedConfig.MemcachedConfig) (domain.RateLimitCache, error) {
+	client, err := driversMemcached.GetClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRateLimitMemcachedClient(client, cfg.KeyPrefix), nil
+}
+
+// NewRateLimitMemcachedClient returns the memcached cache using the client
+// informed, the keys are prefixed with prefix when it is not empty. Closing
+// the cache closes the client
+func NewRateLimitMemcachedClient(client *memcache.Client, prefix string) domain.RateLimitCache {
+	return &rateLimitMemcached{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (m *rateLimitMemcached) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	windowKey, counterKey := m.storageKeys(rate.Key)
+	expiration := memcachedExpiration(rate, every)
+	delta := rate.Requests - rate.Counted
+
+	if rate.Counted == 0 {
+		created, err := m.create(rate, windowKey, counterKey, expiration)
+		if err != nil || created {
+			return err
+		}
+
+		// the window was created by another instance, count every request
+		// in it
+		delta = rate.Requests
+	}
+
+	if delta > 0 {
+		_, err := m.client.Increment(counterKey, uint64(delta))
+
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			// the window expired after it was read
+			_, err = m.create(rate, windowKey, counterKey, expiration)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if delta < 0 {
+		// the requests counted are rolled back, there is nothing to roll
+		// back when the window expired
+		_, err := m.client.Decrement(counterKey, uint64(-delta))
+		if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+			return err
+		}
+	}
+
+	if !rate.Limited {
+		return nil
+	}
+
+	return m.markLimited(windowKey, expiration)
+}
+
+// create stores a new window with its counter, it reports false when the
+// window already exists
+func (m *rateLimitMemcached) create(rate entities.RateLimiter, windowKey, counterKey string, expiration int32) (bool, error) {
+	err := m.client.Add(&memcache.Item{
+		Key:        counterKey,
+		Value:      []byte(strconv.Itoa(rate.Requests)),
+		Expiration: expiration,
+	})
+
+	if errors.Is(err, memcache.ErrNotStored) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	rate.Key = ""
+	rate.Counted = 0
+
+	data, err := rate.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+
+	return true, m.client.Set(&memcache.Item{
+		Key:        windowKey,
+		Value:      data,
+		Expiration: expiration,
+	})
+}
+
+// markLimited flags the stored window as exceeded in dry run, retrying when
+// another instance updates it at the same time
+func (m *rateLimitMemcached) markLimited(windowKey string, expiration int32) error {
+	var err error
+
+	for i := 0; i < memcachedCASRetries; i++ {
+		var item *memcache.Item
+
+		item, err = m.client.Get(windowKey)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var stored entities.RateLimiter
+
+		if err := stored.UnmarshalBinary(item.Value); err != nil {
+			return err
+		}
+
+		if stored.Limited {
+			return nil
+		}
+
+		stored.Limited = true
+
+		if item.Value, err = stored.MarshalBinary(); err != nil {
+			return err
+		}
+
+		item.Expiration = expiration
+
+		err = m.client.CompareAndSwap(item)
+		if !errors.Is(err, memcache.ErrCASConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (m *rateLimitMemcached) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	windowKey, counterKey := m.storageKeys(key)
+
+	items, err := m.client.GetMulti([]string{windowKey, counterKey})
+	if err != nil {
+		return nil, err
+	}
+
+	window, ok := items[windowKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", domain.ErrWindowNotFound, memcache.ErrCacheMiss)
+	}
+
+	var rate entities.RateLimiter
+
+	if err := rate.UnmarshalBinary(window.Value); err != nil {
+		return nil, err
+	}
+
+	rate.Key = key
+	rate.Counted = rate.Requests
+
+	if counter, ok := items[counterKey]; ok {
+		requests, err := strconv.Atoi(strings.TrimSpace(string(counter.Value)))
+		if err != nil {
+			return nil, err
+		}
+
+		rate.Counted = requests
+	}
+
+	if rate.Every > 0 {
+		rate.Remaining = max(rate.Remaining-(rate.Counted-rate.Requests), 0)
+	}
+
+	rate.Requests = rate.Counted
+
+	return &rate, nil
+}
+
+// storageKeys returns the keys of the window and of the counter of the IP or
+// token, hashed when they are not valid memcached keys
+func (m *rateLimitMemcached) storageKeys(key string) (string, string) {
+	windowKey := m.prefixed(key)
+
+	if !memcachedLegalKey(windowKey + ":requests") {
+		sum := sha256.Sum256([]byte(key))
+		windowKey = m.prefixed(hex.EncodeToString(sum[:]))
+	}
+
+	return windowKey, windowKey + ":requests"
+}
+
+func (m *rateLimitMemcached) prefixed(key string) string {
+	if m.prefix == "" {
+		return key
+	}
+
+	return m.prefix + ":" + key
+}
+
+// Close closes the connections of the memcached client
+func (m *rateLimitMemcached) Close() error {
+	return m.client.Close()
+}
+
+// memcachedLegalKey reports if memcached accepts the key: up to 250 bytes
+// without spaces or control characters
+func memcachedLegalKey(key string) bool {
+	if len(key) > 250 {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// memcachedExpiration returns the expiration of the window in seconds, never
+// zero since it means no expiration. Windows longer than 30 days expire at
+// their reset
+func memcachedExpiration(rate entities.RateLimiter, every time.Duration) int32 {
+	if every > memcachedMaxRelativeExpiration {
+		return int32(rate.Reset)
+	}
+
+	return int32(max((every+time.Second-1)/time.Second, 1))
+}
@@ -0,0 +1,407 @@
+package strategies
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitMemcached_Set(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should store a new window with its counter", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "ratelimit")
+
+		err := rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "1", server.value("ratelimit:test_key:requests"))
+		assert.Equal(t, int32(60), server.expiration("ratelimit:test_key:requests"))
+		assert.JSONEq(t, `{"key":"","requests":1,"remaining":9,"reset":0,"every":60}`, server.value("ratelimit:test_key"))
+		assert.Equal(t, int32(60), server.expiration("ratelimit:test_key"))
+	})
+
+	t.Run("Should count only the requests made since the window was read", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+
+		// two instances read the window before any of them updates it
+		first, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+		second, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+
+		first.Requests += 2
+		second.Requests++
+
+		assert.NoError(t, rl.Set(ctx, *first, time.Minute))
+		assert.NoError(t, rl.Set(ctx, *second, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Requests)
+		assert.Equal(t, 6, result.Remaining)
+	})
+
+	t.Run("Should count every request when another instance created the window", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 2, Remaining: 8, Every: 60}, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.Requests)
+		assert.Equal(t, 7, result.Remaining)
+	})
+
+	t.Run("Should create the window again when it expired after it was read", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+
+		rate, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+
+		server.delete("test_key")
+		server.delete("test_key:requests")
+
+		rate.Requests++
+		assert.NoError(t, rl.Set(ctx, *rate, time.Minute))
+
+		assert.Equal(t, "2", server.value("test_key:requests"))
+	})
+
+	t.Run("Should remove the requests rolled back", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 3, Remaining: 7, Every: 60}, time.Minute))
+
+		// the 3 requests written are rolled back to the 1 read before
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60, Counted: 3}, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Requests)
+		assert.Equal(t, 9, result.Remaining)
+	})
+
+	t.Run("Should mark the stored window as limited", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Every: 60}, time.Minute))
+
+		rate, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+
+		rate.Requests++
+		rate.Limited = true
+		assert.NoError(t, rl.Set(ctx, *rate, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.True(t, result.Limited)
+		assert.Equal(t, 2, result.Requests)
+	})
+
+	t.Run("Should expire windows longer than 30 days at their reset", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+		reset := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Reset: reset}, 31*24*time.Hour))
+
+		assert.Equal(t, int32(reset), server.expiration("test_key"))
+	})
+
+	t.Run("Should never store a window without expiration", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1}, 0))
+
+		assert.Equal(t, int32(1), server.expiration("test_key"))
+	})
+}
+
+func TestRateLimitMemcached_Get(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should return error when the window does not exist", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		result, err := rl.Get(ctx, "test_key")
+
+		assert.ErrorIs(t, err, memcache.ErrCacheMiss)
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should hash the keys memcached does not accept", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "ratelimit")
+		key := "token with spaces"
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: key, Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+
+		result, err := rl.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, result.Key)
+		assert.Equal(t, 1, result.Requests)
+
+		sum := sha256.Sum256([]byte(key))
+		assert.Equal(t, "1", server.value("ratelimit:"+hex.EncodeToString(sum[:])+":requests"))
+	})
+}
+
+func TestRateLimitMemcached_Close(t *testing.T) {
+	t.Run("Should close the memcached client", func(t *testing.T) {
+		server := newMemcachedServer(t)
+		rl := NewRateLimitMemcachedClient(server.client(), "")
+
+		assert.NoError(t, rl.(io.Closer).Close())
+	})
+}
+
+type memcachedItem struct {
+	value      []byte
+	flags      uint32
+	expiration int32
+	cas        uint64
+}
+
+// memcachedServer is a memcached stand-in speaking the text protocol used by
+// the client: gets, set, add, cas, incr, decr and delete. Expirations are recorded
+// but items never expire
+type memcachedServer struct {
+	t        *testing.T
+	listener net.Listener
+	mutex    sync.Mutex
+	items    map[string]memcachedItem
+	cas      uint64
+}
+
+func newMemcachedServer(t *testing.T) *memcachedServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &memcachedServer{
+		t:        t,
+		listener: listener,
+		items:    map[string]memcachedItem{},
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go s.serve()
+
+	return s
+}
+
+func (s *memcachedServer) client() *memcache.Client {
+	return memcache.New(s.listener.Addr().String())
+}
+
+func (s *memcachedServer) value(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return string(s.items[key].value)
+}
+
+func (s *memcachedServer) expiration(key string) int32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.items[key].expiration
+}
+
+func (s *memcachedServer) delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.items, key)
+}
+
+func (s *memcachedServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *memcachedServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reply string
+
+		switch fields[0] {
+		case "gets", "get":
+			reply = s.get(fields[1:])
+		case "set", "add", "cas":
+			data := make([]byte, s.atoi(fields[4])+2)
+
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+
+			reply = s.store(fields, data[:len(data)-2])
+		case "incr", "decr":
+			reply = s.incr(fields[1], uint64(s.atoi(fields[2])), fields[0] == "decr")
+		case "delete":
+			reply = s.remove(fields[1])
+		default:
+			reply = "ERROR\r\n"
+		}
+
+		rw.WriteString(reply)
+		rw.Flush()
+	}
+}
+
+func (s *memcachedServer) get(keys []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var reply strings.Builder
+
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			fmt.Fprintf(&reply, "VALUE %s %d %d %d\r\n%s\r\n", key, item.flags, len(item.value), item.cas, item.value)
+		}
+	}
+
+	reply.WriteString("END\r\n")
+
+	return reply.String()
+}
+
+func (s *memcachedServer) store(fields []string, value []byte) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := fields[1]
+	current, exists := s.items[key]
+
+	switch fields[0] {
+	case "add":
+		if exists {
+			return "NOT_STORED\r\n"
+		}
+	case "cas":
+		if !exists {
+			return "NOT_FOUND\r\n"
+		}
+
+		if strconv.FormatUint(current.cas, 10) != fields[5] {
+			return "EXISTS\r\n"
+		}
+	}
+
+	s.cas++
+	s.items[key] = memcachedItem{
+		value:      value,
+		flags:      uint32(s.atoi(fields[2])),
+		expiration: int32(s.atoi(fields[3])),
+		cas:        s.cas,
+	}
+
+	return "STORED\r\n"
+}
+
+// incr adds delta to the counter, or subtracts it down to zero when
+// decrement is set
+func (s *memcachedServer) incr(key string, delta uint64, decrement bool) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return "NOT_FOUND\r\n"
+	}
+
+	value, err := strconv.ParseUint(string(item.value), 10, 64)
+	if err != nil {
+		return "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"
+	}
+
+	switch {
+	case !decrement:
+		value += delta
+	case delta > value:
+		value = 0
+	default:
+		value -= delta
+	}
+
+	s.cas++
+	item.value = []byte(strconv.FormatUint(value, 10))
+	item.cas = s.cas
+	s.items[key] = item
+
+	return string(item.value) + "\r\n"
+}
+
+func (s *memcachedServer) remove(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.items[key]; !ok {
+		return "NOT_FOUND\r\n"
+	}
+
+	delete(s.items, key)
+
+	return "DELETED\r\n"
+}
+
+func (s *memcachedServer) atoi(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		s.t.Errorf("invalid memcached command argument %q", value)
+	}
+
+	return n
+}
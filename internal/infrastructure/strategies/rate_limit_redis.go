@@ -2,11 +2,16 @@ package strategies
 
 import (
 	"context"
-	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
 
 	"github.com/redis/go-redis/v9"
 
@@ -14,28 +19,103 @@ import (
 )
 
 type rateLimitRedis struct {
-	client *redis.Client
+	client     redis.UniversalClient
+	prefix     string
+	hashKeys   bool
+	legacyKeys bool
 }
 
-func NewRateLimitRedis() domain.RateLimitCache {
-	return &rateLimitRedis{
-		client: driversRedis.GetClient(),
+// RedisOption configures how the redis cache names its keys
+type RedisOption func(*rateLimitRedis)
+
+// WithKeyPrefix prefixes the keys with the prefix and the app namespace, so
+// they do not collide with other data of the same database. Empty parts are
+// skipped
+func WithKeyPrefix(prefix, namespace string) RedisOption {
+	return func(r *rateLimitRedis) {
+		var parts []string
+
+		for _, part := range []string{prefix, namespace} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+
+		r.prefix = strings.Join(parts, ":")
 	}
 }
 
-func (r *rateLimitRedis) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
-	err := r.client.Set(ctx, rate.Key, rate, every).Err()
+// WithKeyHash stores the SHA-256 of the IPs and tokens instead of the plain
+// values, in the keys and in the stored windows
+func WithKeyHash() RedisOption {
+	return func(r *rateLimitRedis) {
+		r.hashKeys = true
+	}
+}
 
+// WithLegacyKeys migrates the windows stored by previous versions, without
+// prefix and hash, to the current key when they are read
+func WithLegacyKeys() RedisOption {
+	return func(r *rateLimitRedis) {
+		r.legacyKeys = true
+	}
+}
+
+// NewRateLimitRedis returns the redis cache using the client shared by the
+// process, naming the keys as configured by cfg
+func NewRateLimitRedis(cfg redisConfig.RedisConfig) (domain.RateLimitCache, error) {
+	client, err := driversRedis.GetClient(cfg)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
 
-	return err
+	opts := []RedisOption{WithKeyPrefix(cfg.KeyPrefix, cfg.Namespace)}
+
+	if cfg.KeyHash {
+		opts = append(opts, WithKeyHash())
+	}
+
+	if cfg.KeyMigrate {
+		opts = append(opts, WithLegacyKeys())
+	}
+
+	return NewRateLimitRedisClient(client, opts...), nil
+}
+
+// NewRateLimitRedisClient returns the redis cache using the client informed,
+// closing the cache closes the client
+func NewRateLimitRedisClient(client redis.UniversalClient, opts ...RedisOption) domain.RateLimitCache {
+	r := &rateLimitRedis{
+		client: client,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *rateLimitRedis) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	key := r.storageKey(rate.Key)
+
+	if r.hashKeys {
+		rate.Key = ""
+	}
 
+	return r.client.Set(ctx, key, rate, every).Err()
 }
 
 func (r *rateLimitRedis) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
-	val, err := r.client.Get(ctx, key).Result()
+	val, err := r.client.Get(ctx, r.storageKey(key)).Result()
+	if errors.Is(err, redis.Nil) && r.legacyKeys {
+		val, err = r.migrate(ctx, key)
+	}
+
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%w: %w", domain.ErrWindowNotFound, err)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -46,5 +126,190 @@ func (r *rateLimitRedis) Get(ctx context.Context, key string) (*entities.RateLim
 		return nil, err
 	}
 
+	rate.Key = key
+
 	return &rate, nil
 }
+
+// migrateScript moves the window of the legacy key KEYS[2] to the current key
+// KEYS[1] keeping its expiration, clearing the key stored in the window when
+// ARGV[1] is 1. It returns the window moved, or nil when the legacy key does
+// not exist
+var migrateScript = redis.NewScript(`
+local val = redis.call('GET', KEYS[2])
+local ttl = redis.call('PTTL', KEYS[2])
+
+if not val or ttl == -2 then
+	return false
+end
+
+if ARGV[1] == '1' then
+	local rate = cjson.decode(val)
+	rate['key'] = ''
+	val = cjson.encode(rate)
+end
+
+if ttl > 0 then
+	redis.call('SET', KEYS[1], val, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], val)
+end
+
+redis.call('DEL', KEYS[2])
+
+return val
+`)
+
+// migrate moves the window of the key stored with a legacy key, hash tagged or
+// plain, to the current key keeping its expiration. Every key is moved by a
+// single script, so concurrent reads never move it twice nor lose requests.
+// In cluster mode the keys of different slots are moved one command at a time
+func (r *rateLimitRedis) migrate(ctx context.Context, key string) (string, error) {
+	current := r.storageKey(key)
+	hashKeys := 0
+
+	if r.hashKeys {
+		hashKeys = 1
+	}
+
+	for _, legacy := range []string{redisKey(key), key} {
+		if legacy == current {
+			continue
+		}
+
+		val, err := migrateScript.Run(ctx, r.client, []string{current, legacy}, hashKeys).Text()
+		if err != nil && strings.HasPrefix(err.Error(), "CROSSSLOT") {
+			val, err = r.migrateKey(ctx, current, legacy)
+		}
+
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+
+		return val, err
+	}
+
+	return "", redis.Nil
+}
+
+// migrateKey moves the legacy window like migrateScript when the keys are
+// in different cluster slots, so it is not atomic
+func (r *rateLimitRedis) migrateKey(ctx context.Context, current, legacy string) (string, error) {
+	val, err := r.client.Get(ctx, legacy).Result()
+	if err != nil {
+		return "", err
+	}
+
+	ttl, err := r.client.PTTL(ctx, legacy).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if ttl == -2 {
+		return "", redis.Nil
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	if r.hashKeys {
+		var rate entities.RateLimiter
+
+		if err := rate.UnmarshalBinary([]byte(val)); err != nil {
+			return "", err
+		}
+
+		rate.Key = ""
+
+		data, err := rate.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+
+		val = string(data)
+	}
+
+	if err := r.client.Set(ctx, current, val, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return val, r.client.Del(ctx, legacy).Err()
+}
+
+// storageKey returns the redis key of the IP or token: the prefix and the
+// hash tagged identifier, hashed when enabled
+func (r *rateLimitRedis) storageKey(key string) string {
+	if r.hashKeys {
+		sum := sha256.Sum256([]byte(key))
+		key = hex.EncodeToString(sum[:])
+	}
+
+	if r.prefix == "" {
+		return redisKey(key)
+	}
+
+	return r.prefix + ":" + redisKey(key)
+}
+
+// redisKey hash tags the key, so every redis key derived from it is stored in
+// the same cluster slot and can be used by multi-key scripts
+func redisKey(key string) string {
+	return "{" + key + "}"
+}
+
+// reserveScript reserves up to ARGV[1] requests of the quota hash KEYS[1].
+// A missing quota is created with the limit ARGV[2], every ARGV[3] and reset
+// ARGV[4], expiring after every seconds, unless the limit is negative
+var reserveScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	if tonumber(ARGV[2]) < 0 then
+		return false
+	end
+
+	redis.call('HSET', KEYS[1], 'limit', ARGV[2], 'reserved', 0, 'every', ARGV[3], 'reset', ARGV[4])
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+
+local quota = redis.call('HMGET', KEYS[1], 'limit', 'reserved', 'every', 'reset')
+local limit, reserved = tonumber(quota[1]), tonumber(quota[2])
+local granted = math.max(math.min(tonumber(ARGV[1]), limit - reserved), 0)
+
+if granted > 0 then
+	reserved = redis.call('HINCRBY', KEYS[1], 'reserved', granted)
+end
+
+return {granted, reserved, limit, tonumber(quota[3]), tonumber(quota[4])}
+`)
+
+// Reserve reserves requests of the quota of the key shared by the instances,
+// kept apart from the windows stored by Set
+func (r *rateLimitRedis) Reserve(ctx context.Context, key string, requests int, window *Reservation) (Reservation, error) {
+	args := []any{requests, -1, 0, 0}
+
+	if window != nil {
+		args = []any{requests, window.Limit, window.Every, window.Reset}
+	}
+
+	result, err := reserveScript.Run(ctx, r.client, []string{r.storageKey(key) + ":quota"}, args...).Int64Slice()
+	if errors.Is(err, redis.Nil) {
+		return Reservation{}, ErrReservationNotFound
+	}
+
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	return Reservation{
+		Granted:  int(result[0]),
+		Reserved: int(result[1]),
+		Limit:    int(result[2]),
+		Every:    int(result[3]),
+		Reset:    result[4],
+	}, nil
+}
+
+// Close closes the redis client
+func (r *rateLimitRedis) Close() error {
+	return r.client.Close()
+}
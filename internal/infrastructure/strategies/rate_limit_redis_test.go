@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -62,7 +64,7 @@ func TestRateLimitRedis_Set(t *testing.T) {
 		assert.NoError(t, err)
 
 		// Get the rate limit from Redis
-		val, err := client.Get(ctx, rate.Key).Result()
+		val, err := client.Get(ctx, redisKey(rate.Key)).Result()
 
 		// Check if there was no error
 		assert.NoError(t, err)
@@ -141,7 +143,7 @@ func TestRateLimitRedis_Get(t *testing.T) {
 		}
 
 		// Set the rate limit in Redis
-		err = client.Set(ctx, key, `{"key":"test_key","requests":10}`, 0).Err()
+		err = client.Set(ctx, redisKey(key), `{"key":"test_key","requests":10}`, 0).Err()
 		assert.NoError(t, err)
 
 		// Get the rate limit
@@ -169,7 +171,7 @@ func TestRateLimitRedis_Get(t *testing.T) {
 		}
 
 		// Set the rate limit in Redis
-		err = client.Set(ctx, key, `{"key":"test_key","requests":10.0}`, 0).Err()
+		err = client.Set(ctx, redisKey(key), `{"key":"test_key","requests":10.0}`, 0).Err()
 		assert.NoError(t, err)
 
 		// Get the rate limit
@@ -207,3 +209,120 @@ func TestRateLimitRedis_Get(t *testing.T) {
 		assert.Nil(t, rate)
 	})
 }
+
+func TestRateLimitRedis_Close(t *testing.T) {
+	t.Run("Should close the redis client", func(t *testing.T) {
+		client := redis.NewClient(&redis.Options{
+			Addr: "",
+		})
+
+		rl := rateLimitRedis{
+			client: client,
+		}
+
+		assert.NoError(t, rl.Close())
+		assert.ErrorIs(t, client.Ping(context.TODO()).Err(), redis.ErrClosed)
+	})
+}
+
+func TestRedisKey(t *testing.T) {
+	t.Run("Should hash tag the key", func(t *testing.T) {
+		assert.Equal(t, "{token_1}", redisKey("token_1"))
+		assert.Equal(t, "{search:token_1}", redisKey("search:token_1"))
+	})
+}
+
+func TestRateLimitRedis_Keys(t *testing.T) {
+	ctx := context.TODO()
+	rate := entities.RateLimiter{
+		Key:       "token_1",
+		Requests:  1,
+		Remaining: 9,
+		Reset:     time.Now().Add(time.Minute).Unix(),
+	}
+
+	newRedis := func(t *testing.T, opts ...RedisOption) (*miniredis.Miniredis, domain.RateLimitCache) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr(), DisableIndentity: true})
+
+		return server, NewRateLimitRedisClient(client, opts...)
+	}
+
+	t.Run("Should prefix the keys with the prefix and namespace", func(t *testing.T) {
+		server, rl := newRedis(t, WithKeyPrefix("ratelimit", "billing"))
+
+		assert.NoError(t, rl.Set(ctx, rate, time.Minute))
+
+		assert.True(t, server.Exists("ratelimit:billing:{token_1}"))
+		assert.Equal(t, time.Minute, server.TTL("ratelimit:billing:{token_1}"))
+
+		stored, err := rl.Get(ctx, "token_1")
+		assert.NoError(t, err)
+		assert.Equal(t, &rate, stored)
+	})
+
+	t.Run("Should skip empty parts of the prefix", func(t *testing.T) {
+		server, rl := newRedis(t, WithKeyPrefix("", "billing"))
+
+		assert.NoError(t, rl.Set(ctx, rate, time.Minute))
+
+		assert.True(t, server.Exists("billing:{token_1}"))
+	})
+
+	t.Run("Should not store the plain identifier when hashing keys", func(t *testing.T) {
+		server, rl := newRedis(t, WithKeyPrefix("ratelimit", ""), WithKeyHash())
+
+		assert.NoError(t, rl.Set(ctx, rate, time.Minute))
+
+		key := "ratelimit:{ccee1a67bb5b1c0b2cc95e39dfa8ed366388a3664558f408e0a606569337251d}"
+		assert.Equal(t, []string{key}, server.Keys())
+
+		val, err := server.Get(key)
+		assert.NoError(t, err)
+		assert.NotContains(t, val, "token_1")
+
+		stored, err := rl.Get(ctx, "token_1")
+		assert.NoError(t, err)
+		assert.Equal(t, &rate, stored)
+	})
+
+	t.Run("Should migrate the legacy keys when they are read", func(t *testing.T) {
+		for _, legacy := range []string{"token_1", "{token_1}"} {
+			server, rl := newRedis(t, WithKeyPrefix("ratelimit", ""), WithKeyHash(), WithLegacyKeys())
+
+			data, _ := rate.MarshalBinary()
+			assert.NoError(t, server.Set(legacy, string(data)))
+			server.SetTTL(legacy, 30*time.Second)
+
+			stored, err := rl.Get(ctx, "token_1")
+			assert.NoError(t, err)
+			assert.Equal(t, &rate, stored)
+
+			assert.False(t, server.Exists(legacy))
+
+			keys := server.Keys()
+			assert.Len(t, keys, 1)
+			assert.Equal(t, 30*time.Second, server.TTL(keys[0]))
+		}
+	})
+
+	t.Run("Should return not found when there is no legacy key to migrate", func(t *testing.T) {
+		server, rl := newRedis(t, WithKeyPrefix("ratelimit", ""), WithLegacyKeys())
+
+		_, err := rl.Get(ctx, "token_1")
+		assert.ErrorIs(t, err, redis.Nil)
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
+		assert.Empty(t, server.Keys())
+	})
+
+	t.Run("Should not read the legacy keys without migration", func(t *testing.T) {
+		server, rl := newRedis(t, WithKeyPrefix("ratelimit", ""))
+
+		data, _ := rate.MarshalBinary()
+		assert.NoError(t, server.Set("token_1", string(data)))
+
+		_, err := rl.Get(ctx, "token_1")
+		assert.ErrorIs(t, err, redis.Nil)
+		assert.True(t, server.Exists("token_1"))
+	})
+}
@@ -0,0 +1,200 @@
+package strategies
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	databaseConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/database"
+
+	driversDatabase "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/cache/database"
+)
+
+// sqlMigrations are the schema versions of the SQL cache, applied in order.
+// The statements are portable between PostgreSQL and SQLite and must never
+// change once released, new versions are appended
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS rate_limit_windows (
+		rate_key VARCHAR(255) NOT NULL PRIMARY KEY,
+		requests INTEGER NOT NULL,
+		every_seconds INTEGER NOT NULL,
+		remaining INTEGER NOT NULL,
+		reset_at BIGINT NOT NULL,
+		limited INTEGER NOT NULL DEFAULT 0,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS rate_limit_windows_expires_at ON rate_limit_windows (expires_at)`,
+}
+
+// sqlUpsert stores the window in a single statement. When the stored window
+// is still valid only the requests counted since it was read ($8) are added
+// to it, so concurrent instances never lose requests, otherwise the new
+// window replaces the expired one
+const sqlUpsert = `INSERT INTO rate_limit_windows (rate_key, requests, every_seconds, remaining, reset_at, limited, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (rate_key) DO UPDATE SET
+	requests = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.requests
+		ELSE rate_limit_windows.requests + $8 END,
+	remaining = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.remaining
+		WHEN rate_limit_windows.every_seconds <= 0 THEN rate_limit_windows.remaining
+		WHEN rate_limit_windows.remaining - $8 > 0 THEN rate_limit_windows.remaining - $8
+		ELSE 0 END,
+	every_seconds = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.every_seconds
+		ELSE rate_limit_windows.every_seconds END,
+	reset_at = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.reset_at
+		ELSE rate_limit_windows.reset_at END,
+	limited = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.limited
+		WHEN excluded.limited = 1 THEN 1
+		ELSE rate_limit_windows.limited END,
+	expires_at = CASE WHEN rate_limit_windows.expires_at <= $9 THEN excluded.expires_at
+		ELSE rate_limit_windows.expires_at END`
+
+const sqlSelect = `SELECT requests, every_seconds, remaining, reset_at, limited FROM rate_limit_windows
+WHERE rate_key = $1 AND expires_at > $2`
+
+const sqlDeleteExpired = `DELETE FROM rate_limit_windows WHERE expires_at <= $1`
+
+type rateLimitSQL struct {
+	db      *sql.DB
+	clock   domain.Clock
+	mutex   sync.Mutex
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewRateLimitSQL returns the SQL cache using the database shared by the
+// process, migrated first when cfg enables it
+func NewRateLimitSQL(cfg databaseConfig.DatabaseConfig) (domain.RateLimitCache, error) {
+	db, err := driversDatabase.GetClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Migrate {
+		if err := MigrateSQL(context.Background(), db); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewRateLimitSQLClient(db, domain.SystemClock), nil
+}
+
+// NewRateLimitSQLClient returns the SQL cache using the database informed,
+// which must be migrated by MigrateSQL. Closing the cache closes the database
+func NewRateLimitSQLClient(db *sql.DB, clock domain.Clock) domain.RateLimitCache {
+	return &rateLimitSQL{
+		db:    db,
+		clock: clock,
+	}
+}
+
+// MigrateSQL applies the schema versions of the SQL cache not applied yet to
+// the database. It can run concurrently in several instances since every
+// statement is idempotent
+func MigrateSQL(ctx context.Context, db *sql.DB) error {
+	return driversDatabase.Migrate(ctx, db, "rate_limit_schema_migrations", sqlMigrations)
+}
+
+func (r *rateLimitSQL) Set(ctx context.Context, rate entities.RateLimiter, every time.Duration) error {
+	now := r.clock.Now()
+	limited := 0
+
+	if rate.Limited {
+		limited = 1
+	}
+
+	_, err := r.db.ExecContext(ctx, sqlUpsert,
+		rate.Key,
+		rate.Requests,
+		rate.Every,
+		rate.Remaining,
+		rate.Reset,
+		limited,
+		now.Add(max(every, time.Second)).Unix(),
+		rate.Requests-rate.Counted,
+		now.Unix(),
+	)
+
+	return err
+}
+
+func (r *rateLimitSQL) Get(ctx context.Context, key string) (*entities.RateLimiter, error) {
+	rate := entities.RateLimiter{Key: key}
+
+	var limited int
+
+	err := r.db.QueryRowContext(ctx, sqlSelect, key, r.clock.Now().Unix()).
+		Scan(&rate.Requests, &rate.Every, &rate.Remaining, &rate.Reset, &limited)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %w", domain.ErrWindowNotFound, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rate.Limited = limited == 1
+	rate.Counted = rate.Requests
+
+	return &rate, nil
+}
+
+// StartJanitor deletes the expired rows every interval, until Close is called
+func (r *rateLimitSQL) StartJanitor(interval time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.stopped = make(chan struct{})
+
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.deleteExpired(ctx)
+			}
+		}
+	}()
+}
+
+// deleteExpired deletes the expired rows, a failure is retried in the next
+// interval
+func (r *rateLimitSQL) deleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, sqlDeleteExpired, r.clock.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Close stops the janitor and closes the database
+func (r *rateLimitSQL) Close() error {
+	r.mutex.Lock()
+	stop, stopped := r.stop, r.stopped
+	r.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	return r.db.Close()
+}
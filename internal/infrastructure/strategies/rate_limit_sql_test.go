@@ -0,0 +1,194 @@
+package strategies
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteDatabase returns a migrated in-memory SQLite database, a single
+// connection keeps every query in the same database
+func newSQLiteDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", "file::memory:")
+	require.NoError(t, err)
+
+	db.SetMaxOpenConns(1)
+	require.NoError(t, MigrateSQL(context.Background(), db))
+
+	return db
+}
+
+func TestMigrateSQL(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should apply every schema version once", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		defer db.Close()
+
+		assert.NoError(t, MigrateSQL(ctx, db))
+
+		var versions, max int
+		err := db.QueryRow(`SELECT COUNT(*), MAX(version) FROM rate_limit_schema_migrations`).Scan(&versions, &max)
+		assert.NoError(t, err)
+		assert.Equal(t, len(sqlMigrations), versions)
+		assert.Equal(t, len(sqlMigrations), max)
+	})
+}
+
+func TestRateLimitSQL_Set(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Should store a new window", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		rl := NewRateLimitSQLClient(db, ratelimittest.NewClock(now))
+		defer rl.(io.Closer).Close()
+
+		rate := entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60, Reset: now.Add(time.Minute).Unix()}
+		assert.NoError(t, rl.Set(ctx, rate, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+
+		rate.Counted = 1
+		assert.Equal(t, &rate, result)
+	})
+
+	t.Run("Should count only the requests made since the window was read", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		rl := NewRateLimitSQLClient(db, ratelimittest.NewClock(now))
+		defer rl.(io.Closer).Close()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+
+		// two instances read the window before any of them updates it
+		first, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+		second, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+
+		first.Requests += 2
+		second.Requests++
+
+		assert.NoError(t, rl.Set(ctx, *first, time.Minute))
+		assert.NoError(t, rl.Set(ctx, *second, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Requests)
+		assert.Equal(t, 6, result.Remaining)
+	})
+
+	t.Run("Should count every request when another instance created the window", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		rl := NewRateLimitSQLClient(db, ratelimittest.NewClock(now))
+		defer rl.(io.Closer).Close()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 2, Remaining: 8, Every: 60}, 30*time.Second))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.Requests)
+		assert.Equal(t, 7, result.Remaining)
+
+		var expiresAt int64
+		assert.NoError(t, db.QueryRow(`SELECT expires_at FROM rate_limit_windows`).Scan(&expiresAt))
+		assert.Equal(t, now.Add(time.Minute).Unix(), expiresAt)
+	})
+
+	t.Run("Should replace the window when the stored one is expired", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		clock := ratelimittest.NewClock(now)
+		rl := NewRateLimitSQLClient(db, clock)
+		defer rl.(io.Closer).Close()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 5, Remaining: 5, Every: 60, Limited: true}, time.Minute))
+
+		clock.Advance(time.Minute)
+
+		_, err := rl.Get(ctx, "test_key")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.ErrorIs(t, err, domain.ErrWindowNotFound)
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Remaining: 9, Every: 60}, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Requests)
+		assert.Equal(t, 9, result.Remaining)
+		assert.False(t, result.Limited)
+	})
+
+	t.Run("Should keep the window marked as limited", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		rl := NewRateLimitSQLClient(db, ratelimittest.NewClock(now))
+		defer rl.(io.Closer).Close()
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "test_key", Requests: 1, Every: 60}, time.Minute))
+
+		rate, err := rl.Get(ctx, "test_key")
+		require.NoError(t, err)
+
+		rate.Requests++
+		rate.Limited = true
+		assert.NoError(t, rl.Set(ctx, *rate, time.Minute))
+
+		rate.Requests++
+		rate.Limited = false
+		rate.Counted++
+		assert.NoError(t, rl.Set(ctx, *rate, time.Minute))
+
+		result, err := rl.Get(ctx, "test_key")
+		assert.NoError(t, err)
+		assert.True(t, result.Limited)
+		assert.Equal(t, 3, result.Requests)
+	})
+}
+
+func TestRateLimitSQL_Janitor(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should delete expired rows in background", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		rl := NewRateLimitSQLClient(db, clock)
+
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "expired", Requests: 1}, time.Second))
+		assert.NoError(t, rl.Set(ctx, entities.RateLimiter{Key: "active", Requests: 1}, time.Minute))
+
+		clock.Advance(2 * time.Second)
+
+		rl.(Janitor).StartJanitor(time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			var rows int
+			err := db.QueryRow(`SELECT COUNT(*) FROM rate_limit_windows`).Scan(&rows)
+
+			return err == nil && rows == 1
+		}, time.Second, time.Millisecond)
+
+		_, err := rl.Get(ctx, "active")
+		assert.NoError(t, err)
+
+		assert.NoError(t, rl.(io.Closer).Close())
+	})
+
+	t.Run("Should close the database without a janitor running", func(t *testing.T) {
+		db := newSQLiteDatabase(t)
+		rl := NewRateLimitSQLClient(db, ratelimittest.NewClock(time.Now()))
+
+		assert.NoError(t, rl.(io.Closer).Close())
+		assert.Error(t, db.Ping())
+	})
+}
@@ -1,17 +1,66 @@
 package strategies
 
 import (
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
 )
 
-func GetCacheStrategy(cache string) domain.RateLimitCache {
-	if cache == "redis" {
-		log.Println("Using Redis as cache")
-		return NewRateLimitRedis()
+// GetCacheStrategy returns the cache selected by cfg.Cache, configured by
+// the settings of cfg
+func GetCacheStrategy(cfg config.Config, logger *slog.Logger) (domain.RateLimitCache, error) {
+	switch cfg.Cache {
+	case "redis":
+		logger.Info("using cache strategy", "cache", "redis")
+		return newRedisStrategy(cfg.Redis, logger)
+	case "memcached":
+		logger.Info("using cache strategy", "cache", "memcached")
+		return NewRateLimitMemcached(cfg.Memcached)
+	case "sql":
+		logger.Info("using cache strategy", "cache", "sql")
+		return NewRateLimitSQL(cfg.Database)
+	case "bolt":
+		logger.Info("using cache strategy", "cache", "bolt")
+		return NewRateLimitBolt(cfg.Bolt)
+	case "hybrid":
+		logger.Info("using cache strategy", "cache", "hybrid")
+		return NewRateLimitHybrid(cfg.Redis, cfg.Hybrid)
 	}
 
-	log.Println("Using InMemory as cache")
-	return NewRateLimitInMemory()
+	logger.Info("using cache strategy", "cache", "inmemory")
+	return NewRateLimitInMemory(), nil
+}
+
+// newRedisStrategy returns the redis cache, guarded by a circuit breaker
+// falling back to the in-memory cache when it is enabled
+func newRedisStrategy(cfg redisConfig.RedisConfig, logger *slog.Logger) (domain.RateLimitCache, error) {
+	cache, err := NewRateLimitRedis(cfg)
+
+	if err != nil || !cfg.Breaker {
+		return cache, err
+	}
+
+	opts := []BreakerOption{
+		WithBreakerLogger(logger),
+		WithBreakerIgnoredErrors(domain.ErrWindowNotFound),
+	}
+
+	if cfg.BreakerFailures > 0 {
+		opts = append(opts, WithBreakerFailures(cfg.BreakerFailures))
+	}
+
+	if cfg.BreakerLatencyMs > 0 {
+		opts = append(opts, WithBreakerLatency(time.Duration(cfg.BreakerLatencyMs)*time.Millisecond))
+	}
+
+	if cfg.BreakerOpenTimeout > 0 {
+		opts = append(opts, WithBreakerOpenTimeout(time.Duration(cfg.BreakerOpenTimeout)*time.Second))
+	}
+
+	logger.Info("using circuit breaker", "cache", "redis", "fallback", "inmemory")
+
+	return NewRateLimitBreaker(cache, NewRateLimitInMemory(), opts...)
 }
@@ -1,27 +1,95 @@
 package strategies
 
 import (
+	"io"
+	"log/slog"
+	"path/filepath"
 	"testing"
 
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	boltConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/bolt"
+	databaseConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/database"
+	hybridConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/hybrid"
+	memcachedConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/memcached"
+	redisConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/redis"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetCacheStrategy(t *testing.T) {
+	redis := redisConfig.RedisConfig{Host: "localhost", Port: 6379}
+
 	t.Run("Should return Redis cache strategy when cache is set to 'redis'", func(t *testing.T) {
-		cache := "redis"
-		expected := NewRateLimitRedis()
+		expected, err := NewRateLimitRedis(redis)
+		assert.NoError(t, err)
+
+		result, err := GetCacheStrategy(config.Config{Cache: "redis", Redis: redis}, slog.Default())
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should guard the Redis cache strategy with the circuit breaker when it is enabled", func(t *testing.T) {
+		breaker := redis
+		breaker.Breaker = true
+
+		result, err := GetCacheStrategy(config.Config{Cache: "redis", Redis: breaker}, slog.Default())
+
+		assert.NoError(t, err)
+		assert.IsType(t, &rateLimitBreaker{}, result)
+		assert.NoError(t, result.(io.Closer).Close())
+	})
+
+	t.Run("Should return Memcached cache strategy when cache is set to 'memcached'", func(t *testing.T) {
+		memcached := memcachedConfig.MemcachedConfig{Addrs: []string{"localhost:11211"}}
+		expected, err := NewRateLimitMemcached(memcached)
+		assert.NoError(t, err)
+
+		result, err := GetCacheStrategy(config.Config{Cache: "memcached", Memcached: memcached}, slog.Default())
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return SQL cache strategy when cache is set to 'sql'", func(t *testing.T) {
+		database := databaseConfig.DatabaseConfig{Driver: databaseConfig.DriverSQLite, DSN: "file::memory:", Migrate: true}
+		expected, err := NewRateLimitSQL(database)
+		assert.NoError(t, err)
+
+		result, err := GetCacheStrategy(config.Config{Cache: "sql", Database: database}, slog.Default())
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return Bolt cache strategy when cache is set to 'bolt'", func(t *testing.T) {
+		bolt := boltConfig.BoltConfig{Path: filepath.Join(t.TempDir(), "ratelimit.bolt"), Timeout: 1}
+		expected, err := NewRateLimitBolt(bolt)
+		assert.NoError(t, err)
+		defer expected.(io.Closer).Close()
+
+		result, err := GetCacheStrategy(config.Config{Cache: "bolt", Bolt: bolt}, slog.Default())
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("Should return Hybrid cache strategy when cache is set to 'hybrid'", func(t *testing.T) {
+		hybrid := hybridConfig.HybridConfig{BatchSize: 10}
+		expected, err := NewRateLimitHybrid(redis, hybrid)
+		assert.NoError(t, err)
 
-		result := GetCacheStrategy(cache)
+		result, err := GetCacheStrategy(config.Config{Cache: "hybrid", Redis: redis, Hybrid: hybrid}, slog.Default())
 
+		assert.NoError(t, err)
 		assert.Equal(t, expected, result)
 	})
 
-	t.Run("Should return InMemory cache strategy when cache is set to any value other than 'redis'", func(t *testing.T) {
-		cache := "memcached"
+	t.Run("Should return InMemory cache strategy when cache is set to any other value", func(t *testing.T) {
 		expected := NewRateLimitInMemory()
 
-		result := GetCacheStrategy(cache)
+		result, err := GetCacheStrategy(config.Config{Cache: "inmemory"}, slog.Default())
 
+		assert.NoError(t, err)
 		assert.Equal(t, expected, result)
 	})
 }
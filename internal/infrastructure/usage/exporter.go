@@ -0,0 +1,224 @@
+// Package usage aggregates the requests counted by the rate limit use case
+// per key, rule and window, and exports the totals to a sink, such as a
+// JSONL file, a webhook or a SQL table, for billing
+package usage
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+)
+
+// Sink receives the totals of each flush. A failed write is retried with the
+// same totals, so sinks that add them up must be idempotent or tolerate
+// duplicates when a write fails after storing part of them
+type Sink interface {
+	Write(ctx context.Context, records []entities.UsageRecord) error
+}
+
+type totalKey struct {
+	key   string
+	rule  string
+	reset int64
+}
+
+// Exporter adds up the records of the same key, rule and window and writes
+// the totals to the sink on every flush. A flush that fails after all
+// retries keeps the totals, which are added to the next flush
+type Exporter struct {
+	sink    Sink
+	retries int
+	backoff time.Duration
+	logger  *slog.Logger
+	mutex   sync.Mutex
+	totals  map[totalKey]*entities.UsageRecord
+	flush   sync.Mutex
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// Option configures the Exporter
+type Option func(*Exporter)
+
+// WithRetries sets how many times a failed write is retried, 3 when it is
+// not informed
+func WithRetries(retries int) Option {
+	return func(e *Exporter) {
+		e.retries = retries
+	}
+}
+
+// WithBackoff sets the wait before the first retry, doubled after each
+// failure, 1 second when it is not informed
+func WithBackoff(backoff time.Duration) Option {
+	return func(e *Exporter) {
+		e.backoff = backoff
+	}
+}
+
+// WithLogger sets the logger of the failed flushes, the default slog logger
+// is used when it is not informed
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Exporter) {
+		e.logger = logger
+	}
+}
+
+// NewExporter returns the exporter of the totals to sink
+func NewExporter(sink Sink, opts ...Option) *Exporter {
+	e := &Exporter{
+		sink:    sink,
+		retries: 3,
+		backoff: time.Second,
+		logger:  slog.Default(),
+		totals:  make(map[totalKey]*entities.UsageRecord),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Record adds the requests of the record to the total of its key, rule and
+// window
+func (e *Exporter) Record(ctx context.Context, record entities.UsageRecord) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.add(record)
+}
+
+func (e *Exporter) add(record entities.UsageRecord) {
+	key := totalKey{key: record.Key, rule: record.Rule, reset: record.Reset}
+
+	total, ok := e.totals[key]
+	if !ok {
+		e.totals[key] = &record
+		return
+	}
+
+	total.Requests += record.Requests
+	total.Rejected += record.Rejected
+}
+
+// Flush writes the totals recorded since the last flush to the sink,
+// retrying while ctx is not done. The totals are kept for the next flush
+// when the write fails
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.flush.Lock()
+	defer e.flush.Unlock()
+
+	e.mutex.Lock()
+	records := make([]entities.UsageRecord, 0, len(e.totals))
+
+	for _, total := range e.totals {
+		records = append(records, *total)
+	}
+
+	e.totals = make(map[totalKey]*entities.UsageRecord)
+	e.mutex.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(records, func(a, b entities.UsageRecord) int {
+		if c := cmp.Compare(a.Key, b.Key); c != 0 {
+			return c
+		}
+
+		if c := cmp.Compare(a.Rule, b.Rule); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Reset, b.Reset)
+	})
+
+	err := e.write(ctx, records)
+	if err == nil {
+		return nil
+	}
+
+	e.logger.ErrorContext(ctx, "failed to export rate limit usage", "records", len(records), "error", err)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, record := range records {
+		e.add(record)
+	}
+
+	return err
+}
+
+func (e *Exporter) write(ctx context.Context, records []entities.UsageRecord) error {
+	backoff := e.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := e.sink.Write(ctx, records)
+		if err == nil || attempt >= e.retries {
+			return err
+		}
+
+		e.logger.WarnContext(ctx, "retrying rate limit usage export", "attempt", attempt+1, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// Start flushes the totals every interval, until Close is called
+func (e *Exporter) Start(interval time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.stop != nil {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	e.stop = stop
+	e.stopped = make(chan struct{})
+
+	go func() {
+		defer close(e.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = e.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// Close stops the periodic flush and flushes the totals left
+func (e *Exporter) Close() error {
+	e.mutex.Lock()
+	stop, stopped := e.stop, e.stopped
+	e.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+
+	return e.Flush(context.Background())
+}
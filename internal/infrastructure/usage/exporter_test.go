@@ -0,0 +1,149 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSinkDown = errors.New("sink down")
+
+// memorySink keeps the records written, failing the first failures writes
+type memorySink struct {
+	mutex    sync.Mutex
+	failures int
+	writes   int
+	records  []entities.UsageRecord
+}
+
+func (s *memorySink) Write(ctx context.Context, records []entities.UsageRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.writes++
+
+	if s.failures > 0 {
+		s.failures--
+		return errSinkDown
+	}
+
+	s.records = append(s.records, records...)
+
+	return nil
+}
+
+func (s *memorySink) Records() []entities.UsageRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.records
+}
+
+func newExporter(sink Sink, opts ...Option) *Exporter {
+	return NewExporter(sink, append([]Option{
+		WithBackoff(time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)...)
+}
+
+func TestExporter(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should add up the records of the same key, rule and window", func(t *testing.T) {
+		sink := &memorySink{}
+		exporter := newExporter(sink)
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Rule: "token_0", Reset: 100, Requests: 1})
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Rule: "token_0", Reset: 100, Requests: 2})
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Rule: "token_0", Reset: 100, Rejected: 1})
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Rule: "token_0", Reset: 160, Requests: 1})
+		exporter.Record(ctx, entities.UsageRecord{Key: "127.0.0.1", Rule: "default", Reset: 100, Requests: 5})
+
+		assert.NoError(t, exporter.Flush(ctx))
+
+		assert.Equal(t, []entities.UsageRecord{
+			{Key: "127.0.0.1", Rule: "default", Reset: 100, Requests: 5},
+			{Key: "token_1", Rule: "token_0", Reset: 100, Requests: 3, Rejected: 1},
+			{Key: "token_1", Rule: "token_0", Reset: 160, Requests: 1},
+		}, sink.Records())
+	})
+
+	t.Run("Should write only the records since the last flush", func(t *testing.T) {
+		sink := &memorySink{}
+		exporter := newExporter(sink)
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+		assert.NoError(t, exporter.Flush(ctx))
+		assert.NoError(t, exporter.Flush(ctx))
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 2})
+		assert.NoError(t, exporter.Flush(ctx))
+
+		assert.Equal(t, 2, sink.writes)
+		assert.Equal(t, []entities.UsageRecord{
+			{Key: "token_1", Reset: 100, Requests: 1},
+			{Key: "token_1", Reset: 100, Requests: 2},
+		}, sink.Records())
+	})
+
+	t.Run("Should retry the failed writes", func(t *testing.T) {
+		sink := &memorySink{failures: 2}
+		exporter := newExporter(sink, WithRetries(2))
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+
+		assert.NoError(t, exporter.Flush(ctx))
+		assert.Equal(t, 3, sink.writes)
+		assert.Len(t, sink.Records(), 1)
+	})
+
+	t.Run("Should keep the totals for the next flush when the retries fail", func(t *testing.T) {
+		sink := &memorySink{failures: 2}
+		exporter := newExporter(sink, WithRetries(1))
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+		assert.ErrorIs(t, exporter.Flush(ctx), errSinkDown)
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 2})
+		assert.NoError(t, exporter.Flush(ctx))
+
+		assert.Equal(t, []entities.UsageRecord{{Key: "token_1", Reset: 100, Requests: 3}}, sink.Records())
+	})
+
+	t.Run("Should stop retrying when the context is done", func(t *testing.T) {
+		sink := &memorySink{failures: 10}
+		exporter := newExporter(sink, WithRetries(10), WithBackoff(time.Hour))
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+
+		assert.ErrorIs(t, exporter.Flush(ctx), errSinkDown)
+		assert.Equal(t, 1, sink.writes)
+	})
+
+	t.Run("Should flush in background and when closed", func(t *testing.T) {
+		sink := &memorySink{}
+		exporter := newExporter(sink)
+		exporter.Start(time.Millisecond)
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+
+		assert.Eventually(t, func() bool {
+			return len(sink.Records()) == 1
+		}, time.Second, time.Millisecond)
+
+		exporter.Record(ctx, entities.UsageRecord{Key: "token_1", Reset: 100, Requests: 1})
+
+		assert.NoError(t, exporter.Close())
+		assert.Len(t, sink.Records(), 2)
+	})
+}
@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/cache/database"
+)
+
+type jsonlSink struct {
+	path string
+}
+
+// NewJSONLSink returns the sink that appends the totals to the file at path,
+// one JSON object per line
+func NewJSONLSink(path string) Sink {
+	return &jsonlSink{
+		path: path,
+	}
+}
+
+func (s *jsonlSink) Write(ctx context.Context, records []entities.UsageRecord) error {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// webhookTimeout limits each post of the webhook sink when no client is
+// informed, so a slow webhook does not hold the flush forever
+const webhookTimeout = 10 * time.Second
+
+// NewWebhookSink returns the sink that posts the totals to url as a JSON
+// array, any answer other than 2xx is a failure. A client with a timeout of
+// 10 seconds is used when client is nil
+func NewWebhookSink(url string, client *http.Client) Sink {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+
+	return &webhookSink{
+		url:    url,
+		client: client,
+	}
+}
+
+func (s *webhookSink) Write(ctx context.Context, records []entities.UsageRecord) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("usage webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// sqlMigrations are the schema versions of the SQL sink, applied in order.
+// The statements are portable between PostgreSQL and SQLite and must never
+// change once released, new versions are appended
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS rate_limit_usage (
+		rate_key VARCHAR(255) NOT NULL,
+		rule VARCHAR(255) NOT NULL,
+		period VARCHAR(16) NOT NULL,
+		reset_at BIGINT NOT NULL,
+		requests BIGINT NOT NULL,
+		rejected BIGINT NOT NULL,
+		PRIMARY KEY (rate_key, rule, reset_at)
+	)`,
+}
+
+// MigrateSQL applies the schema versions of the SQL sink not applied yet to
+// the database. It can run concurrently in several instances since every
+// statement is idempotent
+func MigrateSQL(ctx context.Context, db *sql.DB) error {
+	return database.Migrate(ctx, db, "rate_limit_usage_schema_migrations", sqlMigrations)
+}
+
+// sqlUpsert adds the totals to the row of the key, rule and window
+const sqlUpsert = `INSERT INTO rate_limit_usage (rate_key, rule, period, reset_at, requests, rejected)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (rate_key, rule, reset_at) DO UPDATE SET
+	requests = rate_limit_usage.requests + excluded.requests,
+	rejected = rate_limit_usage.rejected + excluded.rejected`
+
+type sqlSink struct {
+	db *sql.DB
+}
+
+// NewSQLSink returns the sink that adds the totals to the rate_limit_usage
+// table, in a single transaction per flush. The database must be migrated
+// by MigrateSQL
+func NewSQLSink(db *sql.DB) Sink {
+	return &sqlSink{
+		db: db,
+	}
+}
+
+func (s *sqlSink) Write(ctx context.Context, records []entities.UsageRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, sqlUpsert)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, record := range records {
+		_, err := stmt.ExecContext(ctx, record.Key, record.Rule, record.Period, record.Reset, record.Requests, record.Rejected)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+var records = []entities.UsageRecord{
+	{Key: "token_1", Rule: "token_0", Period: "month", Reset: 1711929600, Requests: 40, Rejected: 2},
+	{Key: "127.0.0.1", Rule: "default", Reset: 1709294460, Requests: 3},
+}
+
+func TestJSONLSink(t *testing.T) {
+	t.Run("Should append one record per line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "usage.jsonl")
+		sink := NewJSONLSink(path)
+
+		assert.NoError(t, sink.Write(context.TODO(), records[:1]))
+		assert.NoError(t, sink.Write(context.TODO(), records[1:]))
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"key":"token_1","rule":"token_0","period":"month","reset":1711929600,"requests":40,"rejected":2}
+{"key":"127.0.0.1","rule":"default","reset":1709294460,"requests":3,"rejected":0}
+`, string(data))
+	})
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Run("Should post the records as a JSON array", func(t *testing.T) {
+		var received []entities.UsageRecord
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		assert.NoError(t, NewWebhookSink(server.URL, nil).Write(context.TODO(), records))
+		assert.Equal(t, records, received)
+	})
+
+	t.Run("Should return error when the answer is not 2xx", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		err := NewWebhookSink(server.URL, server.Client()).Write(context.TODO(), records)
+
+		assert.EqualError(t, err, "usage webhook answered 503 Service Unavailable")
+	})
+
+	t.Run("Should limit the posts with a timeout when no client is informed", func(t *testing.T) {
+		sink := NewWebhookSink("http://localhost", nil).(*webhookSink)
+
+		assert.Equal(t, webhookTimeout, sink.client.Timeout)
+	})
+}
+
+func TestSQLSink(t *testing.T) {
+	t.Run("Should add the records to the totals of the table", func(t *testing.T) {
+		ctx := context.TODO()
+
+		db, err := sql.Open("sqlite", "file::memory:")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+
+		require.NoError(t, MigrateSQL(ctx, db))
+
+		sink := NewSQLSink(db)
+		assert.NoError(t, sink.Write(ctx, records))
+		assert.NoError(t, sink.Write(ctx, records[:1]))
+
+		var requests, rejected int
+		var period string
+
+		err = db.QueryRow(`SELECT period, requests, rejected FROM rate_limit_usage WHERE rate_key = $1 AND rule = $2 AND reset_at = $3`,
+			"token_1", "token_0", 1711929600).Scan(&period, &requests, &rejected)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "month", period)
+		assert.Equal(t, 80, requests)
+		assert.Equal(t, 4, rejected)
+	})
+}
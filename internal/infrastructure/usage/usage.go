@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/cache/database"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	usageConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/usage"
+)
+
+// GetUsageExporter returns the exporter of the configured sink, started with
+// the configured flush interval, or nil when the export is disabled. The SQL
+// sink uses the database of the SQL cache
+func GetUsageExporter(cfg config.Config, logger *slog.Logger) (*Exporter, error) {
+	sink, err := getSink(cfg)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+
+	exporter := NewExporter(sink,
+		WithRetries(cfg.Usage.Retries),
+		WithBackoff(time.Duration(cfg.Usage.RetryBackoffMs)*time.Millisecond),
+		WithLogger(logger),
+	)
+
+	if cfg.Usage.FlushInterval > 0 {
+		exporter.Start(time.Duration(cfg.Usage.FlushInterval) * time.Second)
+	}
+
+	return exporter, nil
+}
+
+func getSink(cfg config.Config) (Sink, error) {
+	switch cfg.Usage.Sink {
+	case "":
+		return nil, nil
+	case usageConfig.SinkJSONL:
+		return NewJSONLSink(cfg.Usage.File), nil
+	case usageConfig.SinkWebhook:
+		if cfg.Usage.WebhookURL == "" {
+			return nil, errors.New("usage webhook URL is required")
+		}
+
+		return NewWebhookSink(cfg.Usage.WebhookURL, nil), nil
+	case usageConfig.SinkSQL:
+		db, err := database.GetClient(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Database.Migrate {
+			if err := MigrateSQL(context.Background(), db); err != nil {
+				return nil, err
+			}
+		}
+
+		return NewSQLSink(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported usage sink: %q", cfg.Usage.Sink)
+	}
+}
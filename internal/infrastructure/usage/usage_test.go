@@ -0,0 +1,43 @@
+package usage
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	usageConfig "github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/usage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUsageExporter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Should return nil when the export is disabled", func(t *testing.T) {
+		exporter, err := GetUsageExporter(config.Config{}, logger)
+
+		assert.NoError(t, err)
+		assert.Nil(t, exporter)
+	})
+
+	t.Run("Should return the exporter of the sink", func(t *testing.T) {
+		exporter, err := GetUsageExporter(config.Config{Usage: usageConfig.UsageConfig{
+			Sink:          usageConfig.SinkJSONL,
+			File:          filepath.Join(t.TempDir(), "usage.jsonl"),
+			FlushInterval: 60,
+		}}, logger)
+
+		assert.NoError(t, err)
+		assert.IsType(t, &jsonlSink{}, exporter.sink)
+		assert.NoError(t, exporter.Close())
+	})
+
+	t.Run("Should return error when the sink is invalid", func(t *testing.T) {
+		_, err := GetUsageExporter(config.Config{Usage: usageConfig.UsageConfig{Sink: "kafka"}}, logger)
+		assert.EqualError(t, err, `unsupported usage sink: "kafka"`)
+
+		_, err = GetUsageExporter(config.Config{Usage: usageConfig.UsageConfig{Sink: usageConfig.SinkWebhook}}, logger)
+		assert.EqualError(t, err, "usage webhook URL is required")
+	})
+}
@@ -0,0 +1,26 @@
+// Package client checks rate limits in the rate limit decision service,
+// over HTTP or gRPC.
+package client
+
+import "context"
+
+// Request asks if Cost requests of Key are allowed under Policy, the rule
+// configured for the key is used when Policy is empty
+type Request struct {
+	Key    string `json:"key"`
+	Cost   int    `json:"cost,omitempty"`
+	Policy string `json:"policy,omitempty"`
+}
+
+// Decision is the answer of the rate limit service, Headers holds the rate
+// limit headers to be sent to the caller
+type Decision struct {
+	Allowed   bool              `json:"allowed"`
+	Remaining int               `json:"remaining"`
+	Reset     int64             `json:"reset"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+type Client interface {
+	Check(ctx context.Context, request Request) (Decision, error)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+
+	ratelimitv1 "github.com/mrangelba/go-exp-rate-limiter/api/ratelimit/v1"
+	"google.golang.org/grpc"
+)
+
+type grpcClient struct {
+	client ratelimitv1.RateLimitServiceClient
+}
+
+// NewGRPCClient returns a client of the RateLimitService using conn
+func NewGRPCClient(conn grpc.ClientConnInterface) Client {
+	return &grpcClient{
+		client: ratelimitv1.NewRateLimitServiceClient(conn),
+	}
+}
+
+func (c *grpcClient) Check(ctx context.Context, request Request) (Decision, error) {
+	resp, err := c.client.ShouldRateLimit(ctx, &ratelimitv1.ShouldRateLimitRequest{
+		Key:    request.Key,
+		Cost:   int32(request.Cost),
+		Policy: request.Policy,
+	})
+
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Decision{
+		Allowed:   resp.GetAllowed(),
+		Remaining: int(resp.GetRemaining()),
+		Reset:     resp.GetResetAt(),
+		Headers:   resp.GetHeaders(),
+	}, nil
+}
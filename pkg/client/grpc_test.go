@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	ratelimitv1 "github.com/mrangelba/go-exp-rate-limiter/api/ratelimit/v1"
+	limiterGrpc "github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/grpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCClient_Check(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer()
+	ratelimitv1.RegisterRateLimitServiceServer(server, limiterGrpc.NewRateLimitService(newUseCase()))
+
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client := NewGRPCClient(conn)
+
+	t.Run("Should return the decision of the service", func(t *testing.T) {
+		decision, err := client.Check(context.Background(), Request{Key: "token_1"})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 1, decision.Remaining)
+		assert.NotZero(t, decision.Reset)
+		assert.Equal(t, "1", decision.Headers["Ratelimit-Remaining"])
+	})
+
+	t.Run("Should return the error of the service", func(t *testing.T) {
+		_, err := client.Check(context.Background(), Request{})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type httpClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPClient returns a client of the POST /v1/check endpoint of the
+// service at baseURL, http.DefaultClient is used when client is nil
+func NewHTTPClient(baseURL string, client *http.Client) Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &httpClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+	}
+}
+
+func (c *httpClient) Check(ctx context.Context, request Request) (Decision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/check", bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+
+		json.NewDecoder(resp.Body).Decode(&failure)
+
+		return Decision{}, fmt.Errorf("rate limit service returned %d: %s", resp.StatusCode, failure.Error)
+	}
+
+	var decision Decision
+
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, err
+	}
+
+	return decision, nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/handlers"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUseCase() usecases.RateLimitUseCase {
+	return usecases.NewRateLimitUseCase(config.Config{
+		RateLimiter: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Requests: 2,
+				Every:    60,
+			},
+		},
+	}, strategies.NewRateLimitInMemory())
+}
+
+func TestHTTPClient_Check(t *testing.T) {
+	server := httptest.NewServer(handlers.NewCheckHandler(newUseCase()))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL+"/", nil)
+
+	t.Run("Should return the decision of the service", func(t *testing.T) {
+		decision, err := client.Check(context.Background(), Request{Key: "token_1", Cost: 2})
+
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+		assert.NotZero(t, decision.Reset)
+		assert.Equal(t, "0", decision.Headers["Ratelimit-Remaining"])
+
+		decision, err = client.Check(context.Background(), Request{Key: "token_1"})
+
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+	})
+
+	t.Run("Should return the error of the service", func(t *testing.T) {
+		_, err := client.Check(context.Background(), Request{Key: "token_1", Policy: "unknown"})
+
+		assert.EqualError(t, err, "rate limit service returned 404: rate limit policy not found: unknown")
+	})
+
+	t.Run("Should return error when service is unavailable", func(t *testing.T) {
+		_, err := NewHTTPClient("http://127.0.0.1:0", nil).Check(context.Background(), Request{Key: "token_1"})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/events"
+)
+
+// Event reports a key exceeding or nearly exhausting the limit of a rule,
+// or being banned, it is emitted once per type, key and window
+type Event = entities.Event
+
+// Types of the events
+const (
+	EventLimitExceeded        = entities.EventLimitExceeded
+	EventQuotaNearlyExhausted = entities.EventQuotaNearlyExhausted
+	EventKeyBanned            = entities.EventKeyBanned
+)
+
+// EventSink delivers the events of the Limiter, a failed delivery is logged
+// and not retried
+type EventSink = events.Sink
+
+// NewChannelSink returns the sink that sends each event to ch, the delivery
+// to the other sinks waits while the channel is full
+func NewChannelSink(ch chan<- Event) EventSink {
+	return events.NewChannelSink(ch)
+}
+
+// NewWebhookSink returns the sink that posts each event to url as JSON,
+// signed with secret when it is not empty. A client with a timeout is used
+// when client is nil
+func NewWebhookSink(url, secret string, client *http.Client) EventSink {
+	return events.NewWebhookSink(url, secret, client)
+}
+
+// NewLogSink returns the sink that logs each event
+func NewLogSink(logger *slog.Logger) EventSink {
+	return events.NewLogSink(logger)
+}
+
+// WithEventSink delivers the events of the Limiter to the sinks, in
+// background so Check never waits for them. Close waits for the delivery
+// of the queued events
+func WithEventSink(sinks ...EventSink) Option {
+	return func(l *Limiter) {
+		l.sinks = append(l.sinks, sinks...)
+	}
+}
+
+// WithNearlyExhaustedPercent sets the percentage of the limit used in a
+// window that emits EventQuotaNearlyExhausted, 80 when it is not informed.
+// Zero disables the event
+func WithNearlyExhaustedPercent(percent int) Option {
+	return func(l *Limiter) {
+		l.ucOpts = append(l.ucOpts, usecases.WithNearlyExhaustedPercent(percent))
+	}
+}
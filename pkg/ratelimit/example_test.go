@@ -0,0 +1,81 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+func Example() {
+	limiter, err := ratelimit.New(
+		ratelimit.WithDefaultRule(ratelimit.Rule{Requests: 2, Every: time.Minute}),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, Go Expert!"))
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.168.65.1:52000"
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		fmt.Println(rr.Code, rr.Header().Get("Ratelimit-Remaining"))
+	}
+	// Output:
+	// 200 1
+	// 200 0
+	// 429 0
+}
+
+func ExampleLimiter_Check() {
+	limiter, err := ratelimit.New(
+		ratelimit.WithPolicy("export", ratelimit.Rule{Requests: 10, Every: time.Hour}),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	decision, err := limiter.Check(context.Background(), ratelimit.Request{
+		Key:    "token_1",
+		Cost:   4,
+		Policy: "export",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(decision.Allowed, decision.Remaining)
+	// Output:
+	// true 6
+}
+
+func ExampleNewRedisStore() {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{"localhost:6379"},
+	})
+
+	limiter, err := ratelimit.New(
+		ratelimit.WithStore(ratelimit.NewRedisStore(client)),
+		ratelimit.WithTokenRule("token_1", ratelimit.Rule{Requests: 100, Every: time.Minute}),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_ = limiter
+}
@@ -0,0 +1,393 @@
+// Package ratelimit limits the requests of each IP, token or named policy
+// in fixed windows, stored in memory, redis or any Store implementation.
+//
+// The Limiter is configured with options and used directly, with Check, or
+// as net/http middleware:
+//
+//	limiter, err := ratelimit.New(
+//		ratelimit.WithDefaultRule(ratelimit.Rule{Requests: 10, Every: time.Minute}),
+//		ratelimit.WithTokenRule("token_1", ratelimit.Rule{Requests: 100, Every: time.Minute}),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	http.ListenAndServe(":8080", limiter.Middleware(handler))
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/usecases"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/drivers/config/rate_limiter"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/events"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/http/middlewares"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrInvalidKey is returned by Check when the key is empty
+	ErrInvalidKey = usecases.ErrInvalidKey
+	// ErrInvalidCost is returned by Check when the cost is negative
+	ErrInvalidCost = usecases.ErrInvalidCost
+	// ErrPolicyNotFound is returned by Check when the policy is not configured
+	ErrPolicyNotFound = usecases.ErrPolicyNotFound
+)
+
+// Request asks if Cost requests of Key are allowed under Policy, the rule
+// of the key is used when Policy is empty. The global and route rules are
+// checked when Route, the path requested, is informed
+type Request = entities.CheckRequest
+
+// Decision is the result of a check, Headers holds the rate limit headers
+// to be sent to the caller
+type Decision = entities.Decision
+
+// Usage is the consumption of a key in its current window, returned by
+// Limiter.Usage
+type Usage = entities.Usage
+
+// Calendar periods of the quota rules
+const (
+	PeriodDay   = rate_limiter.PeriodDay
+	PeriodWeek  = rate_limiter.PeriodWeek
+	PeriodMonth = rate_limiter.PeriodMonth
+)
+
+// Rule allows Requests in each window of Every, rounded down to seconds.
+// A zero Every does not limit and a rule in DryRun counts the requests but
+// never rejects them. A rule with a Period is a quota: Every is ignored and
+// the window resets at the start of the next day, week or month in TimeZone,
+// an IANA name such as America/Sao_Paulo, or UTC when it is empty
+type Rule struct {
+	Requests int
+	Every    time.Duration
+	DryRun   bool
+	Period   string
+	TimeZone string
+}
+
+// Clock returns the current time of the rate limit windows
+type Clock = domain.Clock
+
+// OrgResolver returns the organization of a key, an empty name when the key
+// belongs to no organization
+type OrgResolver = domain.OrgResolver
+
+// Limiter checks the rate limits of keys, each Limiter has its own rules
+// and store
+type Limiter struct {
+	limits rate_limiter.RateLimiterConfig
+	store  Store
+	clock  Clock
+	logger *slog.Logger
+	sinks  []EventSink
+	bus    *events.Bus
+	ucOpts []usecases.Option
+	mwOpts []middlewares.Option
+	uc     usecases.RateLimitUseCase
+}
+
+// Option configures the Limiter
+type Option func(*Limiter)
+
+// WithDefaultRule sets the rule of the keys without an IP or token rule,
+// 10 requests per minute when it is not informed
+func WithDefaultRule(rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Default = rate_limiter.Default{
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+			Period:   rule.Period,
+			TimeZone: rule.TimeZone,
+		}
+	}
+}
+
+// WithIPRule sets the rule of the IP
+func WithIPRule(ip string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.IP = append(l.limits.IP, rate_limiter.IP{
+			IP:       ip,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+			Period:   rule.Period,
+			TimeZone: rule.TimeZone,
+		})
+	}
+}
+
+// WithTokenRule sets the rule of the token, sent by clients in the API_KEY
+// header, tokens take precedence over IPs
+func WithTokenRule(token string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Token = append(l.limits.Token, rate_limiter.Token{
+			Token:    token,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+			Period:   rule.Period,
+			TimeZone: rule.TimeZone,
+		})
+	}
+}
+
+// WithPolicy sets a rule chosen by name in Check or PolicyMiddleware, each
+// policy counts the requests of a key separately
+func WithPolicy(name string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Policy = append(l.limits.Policy, rate_limiter.Policy{
+			Name:     name,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+			Period:   rule.Period,
+			TimeZone: rule.TimeZone,
+		})
+	}
+}
+
+// WithGlobalRule sets the rule of all the requests with a Route, shared by
+// every key and checked together with the rule of the key. The Period of
+// the rule is ignored
+func WithGlobalRule(rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Global = &rate_limiter.Global{
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+		}
+	}
+}
+
+// WithRouteRule sets the rule of all the requests with a Route starting with
+// path, shared by every key and checked together with the rule of the key.
+// Only the first route rule matching a request is checked and the Period of
+// the rule is ignored
+func WithRouteRule(path string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Route = append(l.limits.Route, rate_limiter.Route{
+			Path:     path,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+		})
+	}
+}
+
+// WithOrgRule sets the rule shared by the keys of the organization, checked
+// together with the rule of each key. The keys informed are the members of
+// the organization unless WithOrgResolver is used
+func WithOrgRule(name string, rule Rule, keys ...string) Option {
+	return func(l *Limiter) {
+		l.limits.Org = append(l.limits.Org, rate_limiter.Org{
+			Name:     name,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+			Period:   rule.Period,
+			TimeZone: rule.TimeZone,
+			Tokens:   keys,
+		})
+	}
+}
+
+// WithOrgResolver sets the resolver of the organizations of the keys, such
+// as a lookup in the accounts database
+func WithOrgResolver(resolver OrgResolver) Option {
+	return func(l *Limiter) {
+		l.ucOpts = append(l.ucOpts, usecases.WithOrgResolver(resolver))
+	}
+}
+
+// WithEndpointRule sets the rule of the requests of each key with a Route
+// starting with path, checked together with the rule of the key. Only the
+// first endpoint rule matching a request is checked and the Period of the
+// rule is ignored
+func WithEndpointRule(path string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.limits.Endpoint = append(l.limits.Endpoint, rate_limiter.Endpoint{
+			Path:     path,
+			Requests: rule.Requests,
+			Every:    seconds(rule.Every),
+			DryRun:   rule.DryRun,
+		})
+	}
+}
+
+// WithBan rejects every request of a key for duration once it was rejected
+// by its own rule after times within window, emitting EventKeyBanned
+func WithBan(after int, window, duration time.Duration) Option {
+	return func(l *Limiter) {
+		l.limits.Ban = &rate_limiter.Ban{
+			After:    after,
+			Window:   seconds(window),
+			Duration: seconds(duration),
+		}
+	}
+}
+
+// WithDelay makes Middleware and PolicyMiddleware hold the requests of the
+// keys over their limit until the window resets, instead of rejecting them,
+// while the wait is at most maxWait and fewer than queueSize requests of the
+// key are waiting. A wait ends when the context of the request is done
+func WithDelay(maxWait time.Duration, queueSize int, keys ...string) Option {
+	return func(l *Limiter) {
+		l.mwOpts = append(l.mwOpts, middlewares.WithDelay(maxWait, queueSize, keys...))
+	}
+}
+
+// WithDryRunHeader adds the Ratelimit-Dry-Run header to the requests that
+// would be limited by a rule in dry run
+func WithDryRunHeader() Option {
+	return func(l *Limiter) {
+		l.limits.DryRunHeader = true
+	}
+}
+
+// WithStore sets the store of the rate limit windows, a memory store is
+// used when it is not informed
+func WithStore(store Store) Option {
+	return func(l *Limiter) {
+		l.store = store
+	}
+}
+
+// WithRedisClient stores the windows in redis with the client informed, it is
+// a shortcut of WithStore(NewRedisStore(client, opts...))
+func WithRedisClient(client redis.UniversalClient, opts ...RedisOption) Option {
+	return WithStore(NewRedisStore(client, opts...))
+}
+
+// WithClock sets the clock of the rate limit windows, of the delay mode and
+// of the default memory store, the system clock is used when it is not informed. Tests
+// can use the fake clock of the ratelimittest package
+func WithClock(clock Clock) Option {
+	return func(l *Limiter) {
+		l.clock = clock
+	}
+}
+
+// WithLogger sets the logger used to report rejections, store errors and
+// failed event deliveries, the default slog logger is used when it is not informed
+func WithLogger(logger *slog.Logger) Option {
+	return func(l *Limiter) {
+		l.logger = logger
+		l.ucOpts = append(l.ucOpts, usecases.WithLogger(logger))
+	}
+}
+
+// WithTracerProvider sets the provider used to trace limit checks,
+// the global provider is used when it is not informed
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(l *Limiter) {
+		l.ucOpts = append(l.ucOpts, usecases.WithTracerProvider(provider))
+		l.mwOpts = append(l.mwOpts, middlewares.WithTracerProvider(provider))
+	}
+}
+
+// New returns a Limiter configured by the options, or an error when the
+// Period of a rule is not PeriodDay, PeriodWeek or PeriodMonth or its
+// TimeZone is unknown
+func New(opts ...Option) (*Limiter, error) {
+	l := &Limiter{
+		limits: rate_limiter.RateLimiterConfig{
+			Default: rate_limiter.Default{
+				Requests: 10,
+				Every:    60,
+			},
+		},
+		clock:  domain.SystemClock,
+		logger: slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := l.limits.Validate(); err != nil {
+		return nil, fmt.Errorf("ratelimit: %w", err)
+	}
+
+	if l.store == nil {
+		l.store = strategies.NewRateLimitInMemoryClock(l.clock)
+	}
+
+	l.mwOpts = append(l.mwOpts, middlewares.WithClock(l.clock))
+
+	if len(l.sinks) > 0 {
+		l.bus = events.NewBus(l.sinks, events.WithLogger(l.logger))
+		l.ucOpts = append(l.ucOpts, usecases.WithEventPublisher(l.bus))
+	}
+
+	// the store is always set, so the use case does not fail
+	l.uc, _ = usecases.New(append([]usecases.Option{
+		usecases.WithLimits(l.limits),
+		usecases.WithCache(l.store),
+		usecases.WithClock(l.clock),
+	}, l.ucOpts...)...)
+
+	return l, nil
+}
+
+// Close waits for the delivery of the queued events, the store is not
+// closed
+func (l *Limiter) Close() error {
+	if l.bus == nil {
+		return nil
+	}
+
+	return l.bus.Close()
+}
+
+// Allow counts one request of the key and reports if it is allowed
+func (l *Limiter) Allow(ctx context.Context, key string) bool {
+	return l.uc.VerifyLimit(ctx, key)
+}
+
+// Check counts the cost of the request under its policy and returns the
+// decision
+func (l *Limiter) Check(ctx context.Context, request Request) (Decision, error) {
+	return l.uc.Check(ctx, request)
+}
+
+// Usage returns the usage of the key under the policy of the request in the
+// current window, without counting a request
+func (l *Limiter) Usage(ctx context.Context, request Request) (Usage, error) {
+	return l.uc.Usage(ctx, request)
+}
+
+// Headers returns the rate limit headers of the current window of the key
+func (l *Limiter) Headers(ctx context.Context, key string) map[string]string {
+	return l.uc.GetHttpHeaders(ctx, key)
+}
+
+// Middleware limits the requests by the API_KEY header token or, without
+// it, by the client IPs, answering 429 when the limit is reached
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return middlewares.NewRateLimiter(l.uc, l.mwOpts...).Handler(next)
+}
+
+// PolicyMiddleware limits the requests like Middleware, with the rule of
+// the named policy
+func (l *Limiter) PolicyMiddleware(name string) func(http.Handler) http.Handler {
+	opts := append([]middlewares.Option{middlewares.WithPolicy(name)}, l.mwOpts...)
+
+	return func(next http.Handler) http.Handler {
+		return middlewares.NewRateLimiter(l.uc, opts...).Handler(next)
+	}
+}
+
+func seconds(every time.Duration) int {
+	return int(every / time.Second)
+}
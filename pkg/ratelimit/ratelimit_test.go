@@ -0,0 +1,305 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mrangelba/go-exp-rate-limiter/pkg/ratelimit/ratelimittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimiter(t *testing.T, opts ...Option) *Limiter {
+	t.Helper()
+
+	limiter, err := New(opts...)
+	require.NoError(t, err)
+
+	return limiter
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should limit the keys with the default rule", func(t *testing.T) {
+		limiter := newLimiter(t)
+
+		for i := 0; i < 10; i++ {
+			assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		}
+
+		assert.False(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.Equal(t, "0", limiter.Headers(ctx, "10.0.0.1")["Ratelimit-Remaining"])
+	})
+
+	t.Run("Should limit the keys with their IP and token rules", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithDefaultRule(Rule{Requests: 5, Every: time.Minute}),
+			WithIPRule("10.0.0.1", Rule{Requests: 1, Every: time.Minute}),
+			WithTokenRule("token_1", Rule{Requests: 2, Every: time.Minute}),
+		)
+
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.False(t, limiter.Allow(ctx, "10.0.0.1"))
+
+		decision, err := limiter.Check(ctx, Request{Key: "token_1", Cost: 2})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+
+		decision, err = limiter.Check(ctx, Request{Key: "10.0.0.2"})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, decision.Remaining)
+	})
+
+	t.Run("Should check the requests with the policy rule", func(t *testing.T) {
+		limiter := newLimiter(t, WithPolicy("search", Rule{Requests: 1, Every: time.Minute}))
+
+		decision, err := limiter.Check(ctx, Request{Key: "token_1", Policy: "search"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = limiter.Check(ctx, Request{Key: "token_1", Policy: "search"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		_, err = limiter.Check(ctx, Request{Key: "token_1", Policy: "unknown"})
+		assert.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+
+	t.Run("Should check the org, token and endpoint rules together", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithTokenRule("token_1", Rule{Requests: 5, Every: time.Minute}),
+			WithOrgRule("acme", Rule{Requests: 2, Every: time.Minute}, "token_1", "token_2"),
+			WithEndpointRule("/export", Rule{Requests: 1, Every: time.Minute}),
+		)
+
+		decision, err := limiter.Check(ctx, Request{Key: "token_1", Route: "/export"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = limiter.Check(ctx, Request{Key: "token_1", Route: "/export"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		decision, err = limiter.Check(ctx, Request{Key: "token_2", Route: "/"})
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+
+		decision, err = limiter.Check(ctx, Request{Key: "token_1", Route: "/"})
+		assert.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, "4", decision.Headers["Ratelimit-Remaining"])
+	})
+
+	t.Run("Should allow the requests of a rule in dry run with the header", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithDefaultRule(Rule{Requests: 1, Every: time.Minute, DryRun: true}),
+			WithDryRunHeader(),
+		)
+
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.Equal(t, "limited", limiter.Headers(ctx, "10.0.0.1")["Ratelimit-Dry-Run"])
+	})
+
+	t.Run("Should use the store informed", func(t *testing.T) {
+		store := NewMemoryStore()
+		limiter := newLimiter(t, WithStore(store))
+
+		limiter.Allow(ctx, "10.0.0.1")
+
+		window, err := store.Get(ctx, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, 9, window.Remaining)
+	})
+
+	t.Run("Should keep limiters with different rules independent", func(t *testing.T) {
+		strict := newLimiter(t, WithDefaultRule(Rule{Requests: 1, Every: time.Minute}))
+		loose := newLimiter(t, WithDefaultRule(Rule{Requests: 2, Every: time.Minute}))
+
+		assert.True(t, strict.Allow(ctx, "10.0.0.1"))
+		assert.False(t, strict.Allow(ctx, "10.0.0.1"))
+		assert.True(t, loose.Allow(ctx, "10.0.0.1"))
+		assert.True(t, loose.Allow(ctx, "10.0.0.1"))
+	})
+
+	t.Run("Should expire the windows with the time of the clock", func(t *testing.T) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		clock := ratelimittest.NewClock(now)
+		limiter := newLimiter(t,
+			WithDefaultRule(Rule{Requests: 1, Every: time.Minute}),
+			WithClock(clock),
+		)
+
+		decision, err := limiter.Check(ctx, Request{Key: "10.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, now.Add(time.Minute).Unix(), decision.Reset)
+		assert.False(t, limiter.Allow(ctx, "10.0.0.1"))
+
+		clock.Advance(time.Minute + time.Second)
+
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+	})
+
+	t.Run("Should report the usage of a monthly quota", func(t *testing.T) {
+		clock := ratelimittest.NewClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		limiter := newLimiter(t,
+			WithTokenRule("token_1", Rule{Requests: 1000, Period: PeriodMonth, TimeZone: "UTC"}),
+			WithClock(clock),
+		)
+
+		_, err := limiter.Check(ctx, Request{Key: "token_1", Cost: 10})
+		assert.NoError(t, err)
+
+		usage, err := limiter.Usage(ctx, Request{Key: "token_1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 10, usage.Used)
+		assert.Equal(t, 990, usage.Remaining)
+		assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix(), usage.Reset)
+	})
+
+	t.Run("Should return error when the period of a rule is unknown", func(t *testing.T) {
+		limiter, err := New(WithTokenRule("token_1", Rule{Requests: 1000, Period: "monthly"}))
+
+		assert.Nil(t, limiter)
+		assert.EqualError(t, err, `ratelimit: invalid period "monthly" of the rate limit rule token_0`)
+	})
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("API_KEY", "token_1")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should return 429 Too Many Requests when the limit is reached", func(t *testing.T) {
+		limiter := newLimiter(t, WithTokenRule("token_1", Rule{Requests: 1, Every: time.Minute}))
+
+		assert.Equal(t, http.StatusOK, serve(limiter.Middleware(handler)).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(limiter.Middleware(handler)).Code)
+	})
+
+	t.Run("Should count the policy separately from the token rule", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithTokenRule("token_1", Rule{Requests: 1, Every: time.Minute}),
+			WithPolicy("search", Rule{Requests: 1, Every: time.Minute}),
+		)
+
+		assert.Equal(t, http.StatusOK, serve(limiter.Middleware(handler)).Code)
+		assert.Equal(t, http.StatusOK, serve(limiter.PolicyMiddleware("search")(handler)).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(limiter.PolicyMiddleware("search")(handler)).Code)
+	})
+
+	t.Run("Should limit the requests of every key with the global and route rules", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithGlobalRule(Rule{Requests: 2, Every: time.Minute}),
+			WithRouteRule("/search", Rule{Requests: 1, Every: time.Minute}),
+		)
+
+		serveIP := func(ip, path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-Real-IP", ip)
+
+			rr := httptest.NewRecorder()
+			limiter.Middleware(handler).ServeHTTP(rr, req)
+
+			return rr
+		}
+
+		rr := serveIP("10.0.0.1", "/search")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Ratelimit-Global-Remaining"))
+		assert.Equal(t, "0", rr.Header().Get("Ratelimit-Route-Remaining"))
+
+		assert.Equal(t, http.StatusTooManyRequests, serveIP("10.0.0.2", "/search").Code)
+		assert.Equal(t, http.StatusOK, serveIP("10.0.0.2", "/").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serveIP("10.0.0.3", "/").Code)
+	})
+}
+
+func TestLimiter_Middleware_Delay(t *testing.T) {
+	t.Run("Should hold the requests of the delayed keys until the window resets", func(t *testing.T) {
+		limiter := newLimiter(t,
+			WithTokenRule("batch", Rule{Requests: 1, Every: time.Second}),
+			WithTokenRule("token_1", Rule{Requests: 1, Every: time.Second}),
+			WithDelay(5*time.Second, 1, "batch"),
+		)
+
+		handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		serve := func(token string) int {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("API_KEY", token)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			return rr.Code
+		}
+
+		assert.Equal(t, http.StatusOK, serve("token_1"))
+		assert.Equal(t, http.StatusTooManyRequests, serve("token_1"))
+
+		assert.Equal(t, http.StatusOK, serve("batch"))
+		assert.Equal(t, http.StatusOK, serve("batch"))
+	})
+}
+
+func TestLimiter_EventSink(t *testing.T) {
+	t.Run("Should deliver the events to the sinks", func(t *testing.T) {
+		ctx := context.Background()
+		events := make(chan Event, 10)
+		limiter := newLimiter(t,
+			WithDefaultRule(Rule{Requests: 1, Every: time.Minute}),
+			WithEventSink(NewChannelSink(events)),
+			WithNearlyExhaustedPercent(0),
+		)
+
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.False(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.NoError(t, limiter.Close())
+
+		event := <-events
+		assert.Equal(t, EventLimitExceeded, event.Type)
+		assert.Equal(t, "10.0.0.1", event.Key)
+	})
+
+	t.Run("Should deliver the ban of a key", func(t *testing.T) {
+		ctx := context.Background()
+		events := make(chan Event, 10)
+		limiter := newLimiter(t,
+			WithDefaultRule(Rule{Requests: 1, Every: time.Minute}),
+			WithBan(1, time.Minute, time.Hour),
+			WithEventSink(NewChannelSink(events)),
+			WithNearlyExhaustedPercent(0),
+		)
+
+		assert.True(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.False(t, limiter.Allow(ctx, "10.0.0.1"))
+		assert.NoError(t, limiter.Close())
+
+		assert.Equal(t, EventLimitExceeded, (<-events).Type)
+		assert.Equal(t, EventKeyBanned, (<-events).Type)
+	})
+
+	t.Run("Should close without event sinks", func(t *testing.T) {
+		assert.NoError(t, newLimiter(t).Close())
+	})
+}
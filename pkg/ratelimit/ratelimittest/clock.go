@@ -0,0 +1,46 @@
+// Package ratelimittest provides utilities to test code that uses the
+// ratelimit package.
+package ratelimittest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock that only moves when it is advanced, so windows can
+// be expired in tests without sleeping. It is safe for concurrent use
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewClock returns a clock stopped at now
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now: now,
+	}
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now
+func (c *Clock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+}
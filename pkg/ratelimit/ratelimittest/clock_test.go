@@ -0,0 +1,35 @@
+package ratelimittest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Should return the time it was created with", func(t *testing.T) {
+		clock := NewClock(now)
+
+		assert.Equal(t, now, clock.Now())
+		assert.Equal(t, now, clock.Now())
+	})
+
+	t.Run("Should move forward when advanced", func(t *testing.T) {
+		clock := NewClock(now)
+
+		clock.Advance(time.Minute)
+
+		assert.Equal(t, now.Add(time.Minute), clock.Now())
+	})
+
+	t.Run("Should move to the time set", func(t *testing.T) {
+		clock := NewClock(now)
+
+		clock.Set(now.Add(-time.Hour))
+
+		assert.Equal(t, now.Add(-time.Hour), clock.Now())
+	})
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/domain/entities"
+	"github.com/mrangelba/go-exp-rate-limiter/internal/infrastructure/strategies"
+	"github.com/redis/go-redis/v9"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store keeps the rate limit windows, Get returns an error wrapping
+// ErrWindowNotFound when the window of the key does not exist or is expired.
+// Any other error is logged as a failure of the store
+type Store = domain.RateLimitCache
+
+// ErrWindowNotFound is returned by the stores when the window of the key does
+// not exist or is expired
+var ErrWindowNotFound = domain.ErrWindowNotFound
+
+// Window is the state of the rate limit of a key, it expires at Reset
+type Window = entities.RateLimiter
+
+// Janitor is implemented by stores that remove expired windows in background
+type Janitor = strategies.Janitor
+
+// NewMemoryStore returns a store that keeps the windows in memory, it
+// implements Janitor and io.Closer
+func NewMemoryStore() Store {
+	return strategies.NewRateLimitInMemory()
+}
+
+// RedisOption configures how the redis store names its keys
+type RedisOption = strategies.RedisOption
+
+// WithRedisKeyPrefix prefixes the redis keys with the prefix and the app
+// namespace, empty parts are skipped
+func WithRedisKeyPrefix(prefix, namespace string) RedisOption {
+	return strategies.WithKeyPrefix(prefix, namespace)
+}
+
+// WithRedisKeyHash stores the SHA-256 of the IPs and tokens instead of the
+// plain values
+func WithRedisKeyHash() RedisOption {
+	return strategies.WithKeyHash()
+}
+
+// WithRedisLegacyKeys migrates the windows stored without prefix and hash to
+// the current key when they are read
+func WithRedisLegacyKeys() RedisOption {
+	return strategies.WithLegacyKeys()
+}
+
+// NewRedisStore returns a store that keeps the windows in redis, closing the
+// store closes the client
+func NewRedisStore(client redis.UniversalClient, opts ...RedisOption) Store {
+	return strategies.NewRateLimitRedisClient(client, opts...)
+}
+
+// NewMemcachedStore returns a store that keeps the windows in memcached, the
+// keys are prefixed with prefix when it is not empty. Closing the store
+// closes the client
+func NewMemcachedStore(client *memcache.Client, prefix string) Store {
+	return strategies.NewRateLimitMemcachedClient(client, prefix)
+}
+
+// NewSQLStore returns a store that keeps the windows in a PostgreSQL or
+// SQLite database, creating its tables when needed. It implements Janitor,
+// closing the store closes the database
+func NewSQLStore(ctx context.Context, db *sql.DB) (Store, error) {
+	if err := strategies.MigrateSQL(ctx, db); err != nil {
+		return nil, err
+	}
+
+	return strategies.NewRateLimitSQLClient(db, domain.SystemClock), nil
+}
+
+// NewBoltStore returns a store that keeps the windows in a bolt file, so they
+// survive a restart of the process. It implements Janitor, closing the store
+// closes the database
+func NewBoltStore(db *bolt.DB) (Store, error) {
+	return strategies.NewRateLimitBoltDB(db, domain.SystemClock)
+}
+
+// NewHybridStore returns a store that decides locally against batches of
+// requests reserved from redis, so most checks make no round trip. Up to
+// batchSize-1 requests per instance may be refused under the limit and the
+// first request of a window per instance admitted over it. It implements
+// Janitor, closing the store closes the client
+func NewHybridStore(client redis.UniversalClient, batchSize int, opts ...RedisOption) Store {
+	remote := strategies.NewRateLimitRedisClient(client, opts...).(strategies.Reserver)
+
+	return strategies.NewRateLimitHybridClient(remote, domain.SystemClock, batchSize)
+}
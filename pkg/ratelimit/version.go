@@ -0,0 +1,5 @@
+package ratelimit
+
+// Version is the version of the ratelimit package API, it follows semantic
+// versioning and is released as the v-prefixed tag of the module
+const Version = "1.1.0"